@@ -0,0 +1,120 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// isArchive reports whether filename is a bundle of fragments readable by
+// readArchive.
+func isArchive(filename string) bool {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(filename), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readArchive returns the regular files of a tar, gzipped tar or zip archive
+// as fragments, unpacked in memory. Hidden files, e.g. __MACOSX metadata, are
+// skipped.
+func readArchive(filename string) ([]sourceFragment, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return readArchiveData(filename, data)
+}
+
+// readArchiveData unpacks the archive data read from filename, whose
+// extension tells the archive format.
+func readArchiveData(filename string, data []byte) ([]sourceFragment, error) {
+	if strings.HasSuffix(strings.ToLower(filename), ".zip") {
+		return readZip(filename, data)
+	}
+	var r io.Reader = bytes.NewReader(data)
+	if !strings.HasSuffix(strings.ToLower(filename), ".tar") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var out []sourceFragment
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || hiddenArchivePath(hdr.Name) {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sourceFragment{Source: filename + "!" + hdr.Name, Data: content})
+	}
+}
+
+func readZip(filename string, data []byte) ([]sourceFragment, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var out []sourceFragment
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || hiddenArchivePath(f.Name) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sourceFragment{Source: filename + "!" + f.Name, Data: content})
+	}
+	return out, nil
+}
+
+func hiddenArchivePath(name string) bool {
+	for _, part := range strings.Split(path.Clean(name), "/") {
+		if strings.HasPrefix(part, ".") && part != "." || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// auditEvent is a line of the audit log. Config identifies the options and
+// the contents of the override and labels files the decision was made with.
+type auditEvent struct {
+	Time    time.Time `json:"time"`
+	Config  string    `json:"config"`
+	Stage   string    `json:"stage"`
+	Event   string    `json:"event"`
+	Project string    `json:"project,omitempty"`
+	Detail  string    `json:"detail"`
+}
+
+// auditf records a decision for the audit log, if one is written.
+func (m *merger) auditf(event, project, format string, args ...interface{}) {
+	if m.opts.AuditLog == "" {
+		return
+	}
+	m.audit = append(m.audit, auditEvent{
+		Time:    time.Now().UTC(),
+		Config:  m.configDigest,
+		Stage:   m.stage,
+		Event:   event,
+		Project: project,
+		Detail:  fmt.Sprintf(format, args...),
+	})
+}
+
+// formatLicenses lists licenses as e.g. "MIT (0.98), Apache-2.0".
+func formatLicenses(licenses []merge.License) string {
+	if len(licenses) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(licenses))
+	for i, lic := range licenses {
+		parts[i] = lic.Type
+		if lic.Exception != "" {
+			parts[i] += " WITH " + lic.Exception
+		}
+		if lic.Confidence > 0 {
+			parts[i] += fmt.Sprintf(" (%v)", lic.Confidence)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// digestConfig hashes the options and the files they refer to that decide
+// license conclusions.
+func (m *merger) digestConfig() (string, error) {
+	h := sha256.New()
+	data, err := json.Marshal(m.opts)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	files, err := overrideFiles(m.opts.OverrideFiles)
+	if err != nil {
+		return "", err
+	}
+	for _, filename := range append(files, m.opts.LabelsFile) {
+		if filename == "" {
+			continue
+		}
+		data, err := readFileOrStdin(filename)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "\n%s\n", filename)
+		h.Write(data)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// appendAuditLog appends the recorded events to filename, one JSON object
+// per line. Existing lines are never rewritten.
+func appendAuditLog(filename string, events []auditEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
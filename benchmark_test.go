@@ -0,0 +1,154 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// The benchmarks run the stages of a merge over a synthetic BOM of
+// benchComponents components, spread over benchFragments fragments that all
+// also list benchShared common dependencies. Compare them between revisions
+// with make bench-compare.
+const (
+	benchComponents = 100000
+	benchFragments  = 100
+	benchShared     = 100
+)
+
+var (
+	benchOnce sync.Once
+	benchData [][]byte
+	benchDocs []*merge.Document
+)
+
+func benchProject(i int) merge.Project {
+	return merge.Project{
+		Project: fmt.Sprintf("github.com/org%d/repo%d", i%1000, i),
+		Version: fmt.Sprintf("v1.%d.%d", i%20, i%7),
+		Licenses: []merge.License{
+			{Type: []string{"MIT", "Apache-2.0", "BSD-3-Clause", "GPL-3.0-only"}[i%4], Confidence: 0.9 + float64(i%10)/100},
+			{Type: "MIT", Confidence: 0.5},
+		},
+	}
+}
+
+func benchSetup(b *testing.B) {
+	benchOnce.Do(func() {
+		for f := 0; f < benchFragments; f++ {
+			doc := &merge.Document{Version: merge.EnvelopeVersion}
+			for i := f; i < benchComponents; i += benchFragments {
+				doc.Projects = append(doc.Projects, benchProject(i))
+			}
+			for i := 0; i < benchShared; i++ {
+				doc.Projects = append(doc.Projects, benchProject(i))
+			}
+			doc.Errors = []merge.Project{{Project: fmt.Sprintf("example.com/broken%d", f%10), Error: "cannot find license"}}
+			data, err := marshalOutput(doc, true)
+			if err != nil {
+				panic(err)
+			}
+			benchData = append(benchData, data)
+			benchDocs = append(benchDocs, doc)
+		}
+	})
+	b.ResetTimer()
+}
+
+func benchMerged(b *testing.B) *merger {
+	m := newMerger(options{}, nil)
+	for f, doc := range benchDocs {
+		if err := m.addBOM(fmt.Sprintf("fragment%d.json", f), doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+	m.lib.Cleanup()
+	return m
+}
+
+func BenchmarkLoad(b *testing.B) {
+	benchSetup(b)
+	var size int64
+	for _, data := range benchData {
+		size += int64(len(data))
+	}
+	b.SetBytes(size)
+	for n := 0; n < b.N; n++ {
+		for f, data := range benchData {
+			if _, err := parseBOM(fmt.Sprintf("fragment%d.json", f), data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	benchSetup(b)
+	for n := 0; n < b.N; n++ {
+		benchMerged(b)
+	}
+}
+
+// mapCache is a vcsCache holding every VCS root, so only the lookups in the
+// process are measured.
+type mapCache map[string]string
+
+func (c mapCache) Get(project string) (string, bool, error) {
+	root, ok := c[project]
+	return root, ok, nil
+}
+
+func (c mapCache) Set(project, root string) error {
+	return nil
+}
+
+func BenchmarkVCSCacheHit(b *testing.B) {
+	benchSetup(b)
+	b.StopTimer()
+	m := benchMerged(b)
+	cache := mapCache{}
+	for _, p := range m.bom.Projects() {
+		cache[p.Project] = p.Project
+	}
+	b.StartTimer()
+	for n := 0; n < b.N; n++ {
+		m.res = &resources{vcs: newVCSResolver(cache, false, 0, 0)}
+		if err := m.discoverVCS(m.bom); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExport(b *testing.B) {
+	benchSetup(b)
+	b.StopTimer()
+	projects := benchMerged(b).bom.Projects()
+	b.StartTimer()
+	for _, format := range []string{formatNative, formatCycloneDX, formatSPDX, formatSPDXTV} {
+		b.Run(format, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := encodeBOM(projects, format, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
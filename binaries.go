@@ -0,0 +1,107 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// goCommand runs the go command in dir and returns its output.
+func goCommand(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go %s in %s: %v: %s", strings.Join(args, " "), dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// goList runs go list in dir and returns the non-empty output lines.
+func goList(dir string, args ...string) ([]string, error) {
+	out, err := goCommand(dir, append([]string{"list"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// binaryModules maps every main package of the Go modules in dirs to the
+// modules it depends on.
+func binaryModules(dirs []string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, dir := range dirs {
+		mains, err := goList(dir, "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`, "./...")
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range mains {
+			modules, err := goList(dir, "-deps", "-f", `{{with .Module}}{{.Path}}{{end}}`, pkg)
+			if err != nil {
+				return nil, err
+			}
+			out[pkg] = modules
+		}
+	}
+	return out, nil
+}
+
+// attributeBinaries records in every entry of regs the main packages that
+// pull it in, so per-binary BOMs can be derived from one merge of a
+// monorepo.
+func (m *merger) attributeBinaries(dirs []string, regs ...*merge.Registry) error {
+	binaries, err := binaryModules(dirs)
+	if err != nil {
+		return err
+	}
+	users := map[string][]string{}
+	for pkg, modules := range binaries {
+		seen := map[string]bool{}
+		for _, module := range modules {
+			if !seen[module] {
+				seen[module] = true
+				users[module] = append(users[module], pkg)
+			}
+		}
+	}
+	for _, reg := range regs {
+		_ = reg.Each(func(p merge.Project) error {
+			p.Binaries = users[p.Project]
+			sort.Strings(p.Binaries)
+			if len(p.Binaries) > 0 {
+				m.tracef(p.Project, "pulled in by %s", strings.Join(p.Binaries, ", "))
+			}
+			reg.Set(p)
+			return nil
+		})
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runCheck merges the inputs with the regular merge flags without writing
+// any output and compares the result with a base BOM, usually the one of the
+// last release. It reports whether a check failed.
+func runCheck(args []string) (bool, error) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.AddFlagSet(flag.CommandLine)
+	base := fs.String("base", "", "Path to the bom.json to compare with, native, CycloneDX or SPDX JSON")
+	failOnNewUnknown := fs.Bool("fail-on-new-unknown", false, "Fail if a project with an unknown or undetected license is not in the base BOM")
+	_ = fs.Parse(args)
+	if *base == "" || len(opts.In) == 0 || !*failOnNewUnknown {
+		return false, errors.New("usage: bom-merger check --base=bom.json --fail-on-new-unknown --in=DIR [merge flags]")
+	}
+
+	known, err := readBaseProjects(*base)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := newResources()
+	if err != nil {
+		return false, err
+	}
+	m := newMerger(opts, res)
+	if err := m.merge(); err != nil {
+		return false, err
+	}
+
+	var unknown []merge.Project
+	for _, p := range m.bom.Projects() {
+		if merge.Categorize(p) == merge.CategoryUnknown {
+			unknown = append(unknown, p)
+		}
+	}
+	unknown = append(unknown, m.errors.Projects()...)
+
+	failed, legacy := 0, 0
+	for _, p := range unknown {
+		if known[p.Project] || p.Waiver != nil {
+			legacy++
+			continue
+		}
+		failed++
+		reason := "unknown license " + formatLicenses(p.Licenses)
+		if len(p.Licenses) == 0 {
+			reason = "no license detected"
+		}
+		fmt.Printf("new unknown: %s (%s)\n", p.Project, reason)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d projects with unknown licenses are not in %s\n", failed, *base)
+	} else {
+		fmt.Fprintf(os.Stderr, "no new projects with unknown licenses (%d already in %s or waived)\n", legacy, *base)
+	}
+	return failed > 0, m.skippedError(nil)
+}
+
+// readBaseProjects returns the project paths of a native, CycloneDX or SPDX
+// JSON BOM.
+// Versions are ignored, so an upgrade is not a new project.
+func readBaseProjects(filename string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	var doc struct {
+		cdxBOM
+		spdxDocument
+	}
+	if err := json.Unmarshal(data, &doc); err == nil {
+		switch {
+		case doc.BOMFormat == "CycloneDX":
+			for _, c := range doc.Components {
+				known[c.Name] = true
+			}
+			return known, nil
+		case doc.SPDXVersion != "":
+			for _, p := range doc.Packages {
+				known[p.Name] = true
+			}
+			return known, nil
+		}
+	}
+	var projects []merge.Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse base BOM %s: %v", filename, err)
+	}
+	for _, p := range projects {
+		known[p.Project] = true
+	}
+	return known, nil
+}
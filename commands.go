@@ -0,0 +1,111 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// command is a subcommand of bom-merger. run reports false if the command
+// ran but found a problem, e.g. drift or a failed check, which exits with
+// status 1.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) (bool, error)
+}
+
+// commandList returns every subcommand in the order they are listed in the
+// usage. Commands without a result of their own always pass.
+func commandList() []command {
+	return []command{
+		{"merge", "Merge BOM fragments (the default command)", func(args []string) (bool, error) {
+			return true, runMerge(args, false)
+		}},
+		{"lock", "Merge and record the result in the lock file", func(args []string) (bool, error) {
+			return true, runMerge(args, true)
+		}},
+		{"scan", "Write a BOM fragment for the dependencies of a Go module", func(args []string) (bool, error) {
+			return true, runScan(args)
+		}},
+		{"convert", "Convert a BOM document to another format", func(args []string) (bool, error) {
+			return true, runConvert(args)
+		}},
+		{"diff", "Compare two BOM documents", runDiff},
+		{"slice", "Extract the projects one binary ships from a BOM", runSlice},
+		{"validate", "Validate BOM fragments and documents", runValidate},
+		{"schema", "Print the JSON Schema of the native output", func(args []string) (bool, error) {
+			fmt.Fprint(os.Stdout, bomSchema)
+			return true, nil
+		}},
+		{"verify", "Check a published bom.json against the inputs", func(args []string) (bool, error) {
+			drift, err := runVerify(args)
+			return !drift, err
+		}},
+		{"check", "Check the inputs against a base BOM", func(args []string) (bool, error) {
+			failed, err := runCheck(args)
+			return !failed, err
+		}},
+		{"explain", "Show how the merge produced an entry", func(args []string) (bool, error) {
+			return true, runExplain(args)
+		}},
+		{"history", "Search or clean up the recorded BOMs", func(args []string) (bool, error) {
+			return true, runHistory(args)
+		}},
+		{"cache", "Remove stale entries from the VCS cache", func(args []string) (bool, error) {
+			return true, runCache(args)
+		}},
+		{"migrate", "Rewrite legacy fragments in the envelope format", func(args []string) (bool, error) {
+			return true, runMigrate(args)
+		}},
+		{"overrides", "Maintain the override file", func(args []string) (bool, error) {
+			return true, runOverrides(args)
+		}},
+		{"doctor", "Check the environment and configuration", func(args []string) (bool, error) {
+			return runDoctor(args), nil
+		}},
+		{"help", "Show this help", func(args []string) (bool, error) {
+			printUsage(os.Stdout)
+			return true, nil
+		}},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commandList() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: bom-merger command [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commandList() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run bom-merger merge --help for the merge flags.")
+}
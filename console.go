@@ -0,0 +1,96 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Console modes for wrapper scripts. Quiet prints errors only. Porcelain
+// prints tab separated records to stdout whose format is kept stable across
+// versions, and nothing else:
+//
+//	output<TAB>FILE<TAB>ENTRIES	for every BOM file written
+//	warning<TAB>MESSAGE		for every warning
+//	deprecated<TAB>USAGE<TAB>REPLACEMENT<TAB>REMOVAL	for every deprecated usage
+//	done<TAB>OUT			when a merge to OUT succeeded
+var (
+	quiet     bool
+	porcelain bool
+
+	consoleMu sync.Mutex
+	stdout    io.Writer = os.Stdout
+)
+
+// setupConsole silences the human oriented stdout output, including the one
+// of libraries, in quiet and porcelain mode, and moves it to stderr when the
+// BOM is written to stdout.
+func setupConsole() error {
+	if quiet && porcelain {
+		return errors.New("--quiet and --porcelain are mutually exclusive")
+	}
+	if porcelain && opts.Out == stdio {
+		return errors.New("--porcelain can not be used with --out=-, which writes the BOM to stdout")
+	}
+	if !quiet && !porcelain {
+		// the BOM is the only output on stdout, anything else goes to stderr
+		if opts.Out == stdio {
+			stdout = os.Stdout
+			os.Stdout = os.Stderr
+		}
+		return nil
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	stdout = os.Stdout
+	os.Stdout = devNull
+	return nil
+}
+
+// warnf reports a problem that does not fail the merge.
+func warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	switch {
+	case quiet:
+	case porcelain:
+		porcelainf("warning", msg)
+	default:
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+	}
+}
+
+// porcelainf prints a porcelain record. Fields must not contain tabs or
+// newlines, which are replaced by spaces.
+func porcelainf(kind string, fields ...interface{}) {
+	if !porcelain {
+		return
+	}
+	parts := []string{kind}
+	for _, f := range fields {
+		parts = append(parts, strings.NewReplacer("\t", " ", "\n", " ").Replace(fmt.Sprint(f)))
+	}
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	fmt.Fprintln(stdout, strings.Join(parts, "\t"))
+}
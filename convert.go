@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runConvert converts a BOM document in any format bom-merger reads to one
+// of the formats it writes.
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := fs.String("format", formatNative, "Format to convert to, native, cyclonedx, spdx, spdx-tv, yaml, csv or markdown")
+	out := fs.String("out", "", "File to write the converted document to (defaults to stdout)")
+	compact := fs.Bool("compact", false, "Write minified JSON instead of indented JSON")
+	addSPDXIDFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: bom-merger convert [--format=FORMAT] [--out=FILE] BOM")
+	}
+	if err := validateFormat(*format, ""); err != nil {
+		return err
+	}
+
+	projects, err := readBOMProjects(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := encodeBOM(projects, *format, *compact)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(*out, data, 0644)
+}
+
+// readBOMProjects returns the projects of a BOM fragment, a native bom.json
+// or a CycloneDX or SPDX document, with their licenses normalized.
+func readBOMProjects(filename string) ([]merge.Project, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseBOM(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]merge.Project, len(doc.Projects))
+	for i, p := range doc.Projects {
+		projects[i] = merge.NormalizeLicenses(p)
+	}
+	return projects, nil
+}
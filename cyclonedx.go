@@ -0,0 +1,240 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+const (
+	formatNative    = "native"
+	formatCycloneDX = "cyclonedx"
+)
+
+// cdxBOM is a CycloneDX 1.5 JSON document. Only the fields bom-merger can
+// fill are declared. Serial number and timestamp are left out so the output
+// only changes with its components.
+type cdxBOM struct {
+	BOMFormat   string          `json:"bomFormat"`
+	SpecVersion string          `json:"specVersion"`
+	Version     int             `json:"version"`
+	Metadata    cdxMetadata     `json:"metadata"`
+	Components  []cdxComponent  `json:"components"`
+	Annotations []cdxAnnotation `json:"annotations,omitempty"`
+}
+
+type cdxMetadata struct {
+	Tools cdxTools `json:"tools"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string           `json:"type"`
+	BOMRef             string           `json:"bom-ref,omitempty"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	Purl               string           `json:"purl,omitempty"`
+	Licenses           []cdxLicense     `json:"licenses,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty    `json:"properties,omitempty"`
+}
+
+// cdxLicense is a license choice: either a license with an SPDX id or a
+// name, or an SPDX expression.
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cdxAnnotation is a comment on the components whose bom-refs are its
+// subjects.
+type cdxAnnotation struct {
+	Subjects  []string     `json:"subjects"`
+	Annotator cdxAnnotator `json:"annotator"`
+	Timestamp string       `json:"timestamp,omitempty"`
+	Text      string       `json:"text"`
+}
+
+// cdxAnnotator is a person, an organization or a tool.
+type cdxAnnotator struct {
+	Individual   *cdxName      `json:"individual,omitempty"`
+	Organization *cdxName      `json:"organization,omitempty"`
+	Component    *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxName struct {
+	Name string `json:"name"`
+}
+
+func validateFormat(format, splitBy string) error {
+	switch format {
+	case "", formatNative:
+		return nil
+	case formatCycloneDX, formatSPDX, formatSPDXTV, formatYAML, formatCSV, formatMarkdown:
+		if splitBy != "" {
+			return fmt.Errorf("--split-by can not be used with --format=%s", format)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid format %q, must be one of %s, %s, %s, %s, %s, %s or %s", format, formatNative, formatCycloneDX, formatSPDX, formatSPDXTV, formatYAML, formatCSV, formatMarkdown)
+	}
+}
+
+// cycloneDX converts projects to a CycloneDX document. Labels, scope, fork
+// and the license category are kept as bom-merger:* properties.
+func cycloneDX(projects []merge.Project) cdxBOM {
+	doc := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools: cdxTools{Components: []cdxComponent{{Type: "application", Name: "bom-merger"}}},
+		},
+		Components: make([]cdxComponent, 0, len(projects)),
+	}
+	for _, p := range projects {
+		purl := merge.PURL(p)
+		c := cdxComponent{
+			Type:    "library",
+			BOMRef:  purl,
+			Name:    p.Project,
+			Version: p.Version,
+			Purl:    purl,
+		}
+		if p.LicenseExpression != "" {
+			c.Licenses = []cdxLicense{{Expression: p.LicenseExpression}}
+		} else {
+			for i, lic := range p.Licenses {
+				cl := cdxLicenseOf(lic)
+				if i == 0 && cl.License != nil {
+					cl.License.URL = p.LicenseURL
+				}
+				c.Licenses = append(c.Licenses, cl)
+			}
+		}
+		if p.VCS != "" {
+			c.ExternalReferences = append(c.ExternalReferences, cdxExternalRef{Type: "vcs", URL: vcsURL(p.VCS)})
+		}
+		if p.Category != "" {
+			c.Properties = append(c.Properties, cdxProperty{"bom-merger:category", string(p.Category)})
+		}
+		if p.Scope != "" {
+			c.Properties = append(c.Properties, cdxProperty{"bom-merger:scope", p.Scope})
+		}
+		if p.Fork != "" {
+			c.Properties = append(c.Properties, cdxProperty{"bom-merger:fork", p.Fork})
+		}
+		keys := make([]string, 0, len(p.Labels))
+		for k := range p.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			c.Properties = append(c.Properties, cdxProperty{"bom-merger:label:" + k, p.Labels[k]})
+		}
+		for _, a := range p.Annotations {
+			doc.Annotations = append(doc.Annotations, cdxAnnotation{
+				Subjects:  []string{c.BOMRef},
+				Annotator: cdxAnnotatorOf(a.Annotator),
+				Timestamp: a.Date,
+				Text:      a.Text,
+			})
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return doc
+}
+
+// cdxLicenseOf uses an SPDX expression for licenses with an exception,
+// since CycloneDX licenses have no exception field.
+func cdxLicenseOf(lic merge.License) cdxLicense {
+	if lic.Exception != "" {
+		return cdxLicense{Expression: lic.Type + " WITH " + lic.Exception}
+	}
+	if merge.IsListed(lic.Type) {
+		return cdxLicense{License: &cdxLicenseID{ID: lic.Type}}
+	}
+	return cdxLicense{License: &cdxLicenseID{Name: lic.Type}}
+}
+
+// cdxAnnotatorOf converts an SPDX annotator like "Person: Jane Doe" to a
+// CycloneDX annotator. Names without a kind are taken as individuals and
+// annotations without an annotator, which CycloneDX requires, are
+// attributed to bom-merger.
+func cdxAnnotatorOf(annotator string) cdxAnnotator {
+	kind, name := "Person", annotator
+	if i := strings.Index(annotator, ":"); i >= 0 {
+		kind, name = strings.TrimSpace(annotator[:i]), strings.TrimSpace(annotator[i+1:])
+	}
+	switch {
+	case name == "":
+		return cdxAnnotator{Component: &cdxComponent{Type: "application", Name: "bom-merger"}}
+	case kind == "Organization":
+		return cdxAnnotator{Organization: &cdxName{name}}
+	case kind == "Tool":
+		return cdxAnnotator{Component: &cdxComponent{Type: "application", Name: name}}
+	default:
+		return cdxAnnotator{Individual: &cdxName{name}}
+	}
+}
+
+// annotatorOfCDX converts a CycloneDX annotator to the SPDX form. bom-merger
+// does not annotate itself, so it stands for an unattributed annotation.
+func annotatorOfCDX(a cdxAnnotator) string {
+	switch {
+	case a.Individual != nil:
+		return "Person: " + a.Individual.Name
+	case a.Organization != nil:
+		return "Organization: " + a.Organization.Name
+	case a.Component != nil && a.Component.Name == "bom-merger":
+		return ""
+	case a.Component != nil:
+		return "Tool: " + a.Component.Name
+	}
+	return ""
+}
+
+// vcsURL turns a VCS root like github.com/spf13/pflag into a URL.
+func vcsURL(vcs string) string {
+	if strings.Contains(vcs, "://") {
+		return vcs
+	}
+	return "https://" + vcs
+}
@@ -0,0 +1,83 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+
+	flag "github.com/spf13/pflag"
+)
+
+// deprecation is an invocation that still works, so existing CI jobs keep
+// running while they migrate, but will be removed by releases after the
+// removal date.
+type deprecation struct {
+	usage       string
+	replacement string
+	removal     string
+}
+
+// report warns about the deprecation, or prints it as a porcelain record
+//
+//	deprecated<TAB>USAGE<TAB>REPLACEMENT<TAB>REMOVAL
+func (d deprecation) report() {
+	if porcelain {
+		porcelainf("deprecated", d.usage, d.replacement, d.removal)
+		return
+	}
+	warnf("%s is deprecated and will be removed after %s, use %s instead", d.usage, d.removal, d.replacement)
+}
+
+var (
+	// implicitMerge is running a merge with flags on the root command, as
+	// before bom-merger had subcommands.
+	implicitMerge = deprecation{"bom-merger without a command", "bom-merger merge", "2027-04-30"}
+
+	// deprecatedFlags are the old flags by name, kept as hidden aliases of
+	// their replacement.
+	deprecatedFlags = map[string]deprecation{
+		"dir": {"--dir", "--in", "2027-04-30"},
+	}
+
+	// commandOmitted is set if bom-merger runs merge because no command
+	// was given.
+	commandOmitted bool
+)
+
+// hideDeprecatedFlags removes the deprecated flags from the usage.
+func hideDeprecatedFlags() {
+	for name := range deprecatedFlags {
+		_ = flag.CommandLine.MarkHidden(name)
+	}
+}
+
+// reportDeprecations reports every deprecated usage of the invocation.
+func reportDeprecations() {
+	if commandOmitted {
+		implicitMerge.report()
+	}
+	names := make([]string, 0, len(deprecatedFlags))
+	for name := range deprecatedFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if f := flag.CommandLine.Lookup(name); f != nil && f.Changed {
+			deprecatedFlags[name].report()
+		}
+	}
+}
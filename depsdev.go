@@ -0,0 +1,170 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// depsdevVersion holds the fields of the deps.dev GetVersion API used for
+// enrichment.
+type depsdevVersion struct {
+	Licenses        []string `json:"licenses"`
+	RelatedProjects []struct {
+		ProjectKey struct {
+			ID string `json:"id"`
+		} `json:"projectKey"`
+		RelationType string `json:"relationType"`
+	} `json:"relatedProjects"`
+}
+
+// sourceRepo returns the deps.dev project key of the source repository.
+func (v *depsdevVersion) sourceRepo() string {
+	for _, p := range v.RelatedProjects {
+		if p.RelationType == "SOURCE_REPO" {
+			return p.ProjectKey.ID
+		}
+	}
+	return ""
+}
+
+// depsdevProject holds the fields of the deps.dev GetProject API used for
+// enrichment.
+type depsdevProject struct {
+	Scorecard *struct {
+		OverallScore float64 `json:"overallScore"`
+	} `json:"scorecard"`
+}
+
+type depsdevDependents struct {
+	DependentCount int `json:"dependentCount"`
+}
+
+// depsdevClient queries the deps.dev API and caches responses for the
+// lifetime of the process. Unknown packages are reported as nil.
+type depsdevClient struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	lookups map[string]*depsdevLookup
+}
+
+type depsdevLookup struct {
+	done chan struct{}
+	v    interface{}
+	err  error
+}
+
+func newDepsDevClient() *depsdevClient {
+	return &depsdevClient{
+		baseURL: "https://api.deps.dev",
+		client:  &http.Client{Timeout: 30 * time.Second},
+		lookups: map[string]*depsdevLookup{},
+	}
+}
+
+// Version returns the deps.dev record of a Go module version.
+func (c *depsdevClient) Version(module, version string) (*depsdevVersion, error) {
+	path := "/v3/systems/go/packages/" + url.PathEscape(module) + "/versions/" + url.PathEscape(version)
+	v, err := c.lookup(path, func() (interface{}, error) {
+		var v depsdevVersion
+		found, err := c.get(path, &v)
+		if err != nil || !found {
+			return (*depsdevVersion)(nil), err
+		}
+		return &v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*depsdevVersion), nil
+}
+
+// Project returns the deps.dev record of a source repository such as
+// github.com/spf13/pflag.
+func (c *depsdevClient) Project(id string) (*depsdevProject, error) {
+	path := "/v3/projects/" + url.PathEscape(id)
+	v, err := c.lookup(path, func() (interface{}, error) {
+		var v depsdevProject
+		found, err := c.get(path, &v)
+		if err != nil || !found {
+			return (*depsdevProject)(nil), err
+		}
+		return &v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*depsdevProject), nil
+}
+
+// Dependents returns the number of packages depending on a Go module
+// version, or -1 if it is unknown.
+func (c *depsdevClient) Dependents(module, version string) (int, error) {
+	path := "/v3alpha/systems/go/packages/" + url.PathEscape(module) + "/versions/" + url.PathEscape(version) + ":dependents"
+	v, err := c.lookup(path, func() (interface{}, error) {
+		var v depsdevDependents
+		found, err := c.get(path, &v)
+		if err != nil || !found {
+			return -1, err
+		}
+		return v.DependentCount, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// lookup runs fetch once per path, concurrent callers wait for its result.
+func (c *depsdevClient) lookup(path string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	l, ok := c.lookups[path]
+	if !ok {
+		l = &depsdevLookup{done: make(chan struct{})}
+		c.lookups[path] = l
+		c.mu.Unlock()
+
+		l.v, l.err = fetch()
+		close(l.done)
+	} else {
+		c.mu.Unlock()
+		<-l.done
+	}
+	return l.v, l.err
+}
+
+func (c *depsdevClient) get(path string, v interface{}) (bool, error) {
+	resp, err := c.client.Get(c.baseURL + path)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("GET %s%s: %s", c.baseURL, path, resp.Status)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(v)
+}
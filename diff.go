@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// bomChange is a difference between two BOM documents, as printed by
+// diff --json.
+type bomChange struct {
+	Change      string `json:"change"`
+	Project     string `json:"project"`
+	OldVersion  string `json:"oldVersion,omitempty"`
+	NewVersion  string `json:"newVersion,omitempty"`
+	OldLicenses string `json:"oldLicenses,omitempty"`
+	NewLicenses string `json:"newLicenses,omitempty"`
+}
+
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// runDiff compares two BOM documents, in any format bom-merger reads, by
+// project path. Like diff(1), it reports false if they differ.
+func runDiff(args []string) (bool, error) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the differences as a JSON list instead of text")
+	licensesOnly := fs.Bool("licenses-only", false, "Ignore projects whose version changed but not their licenses")
+	htmlFile := fs.String("html", "", "Also write the differences to this file as a single HTML page with a collapsible section per change type")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		return false, errors.New("usage: bom-merger diff [--json] [--licenses-only] [--html=FILE] OLD NEW")
+	}
+	oldProjects, err := readBOMProjects(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	newProjects, err := readBOMProjects(fs.Arg(1))
+	if err != nil {
+		return false, err
+	}
+
+	changes := diffProjects(oldProjects, newProjects, *licensesOnly)
+	if *htmlFile != "" {
+		if err := writeHTMLDiff(*htmlFile, fs.Arg(0), fs.Arg(1), changes); err != nil {
+			return false, err
+		}
+	}
+	if *asJSON {
+		data, err := MarshalJson(changes)
+		if err != nil {
+			return false, err
+		}
+		fmt.Print(string(data))
+		return len(changes) == 0, nil
+	}
+	for _, c := range changes {
+		switch c.Change {
+		case changeAdded:
+			fmt.Printf("+ %s (%s)\n", versionedPath(c.Project, c.NewVersion), c.NewLicenses)
+		case changeRemoved:
+			fmt.Printf("- %s (%s)\n", versionedPath(c.Project, c.OldVersion), c.OldLicenses)
+		default:
+			var parts []string
+			if c.OldVersion != c.NewVersion {
+				parts = append(parts, fmt.Sprintf("version %s -> %s", orNone(c.OldVersion), orNone(c.NewVersion)))
+			}
+			if c.OldLicenses != c.NewLicenses {
+				parts = append(parts, fmt.Sprintf("licenses %s -> %s", c.OldLicenses, c.NewLicenses))
+			}
+			fmt.Printf("~ %s: %s\n", c.Project, strings.Join(parts, ", "))
+		}
+	}
+	return len(changes) == 0, nil
+}
+
+// diffProjects returns the projects added, removed or changed in version or
+// license between two BOMs, in order of project path.
+func diffProjects(oldProjects, newProjects []merge.Project, licensesOnly bool) []bomChange {
+	before := map[string]merge.Project{}
+	for _, p := range oldProjects {
+		before[p.Project] = p
+	}
+	after := map[string]merge.Project{}
+	for _, p := range newProjects {
+		after[p.Project] = p
+	}
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []bomChange{}
+	for _, name := range names {
+		o, inOld := before[name]
+		n, inNew := after[name]
+		switch {
+		case !inOld:
+			changes = append(changes, bomChange{Change: changeAdded, Project: name, NewVersion: n.Version, NewLicenses: licenseTypes(n)})
+		case !inNew:
+			changes = append(changes, bomChange{Change: changeRemoved, Project: name, OldVersion: o.Version, OldLicenses: licenseTypes(o)})
+		default:
+			licenseChanged := licenseTypes(o) != licenseTypes(n)
+			if licenseChanged || (o.Version != n.Version && !licensesOnly) {
+				changes = append(changes, bomChange{
+					Change:      changeChanged,
+					Project:     name,
+					OldVersion:  o.Version,
+					NewVersion:  n.Version,
+					OldLicenses: licenseTypes(o),
+					NewLicenses: licenseTypes(n),
+				})
+			}
+		}
+	}
+	return changes
+}
+
+func versionedPath(project, version string) string {
+	if version == "" {
+		return project
+	}
+	return project + "@" + version
+}
+
+// licenseTypes formats the licenses of p without their confidence, which
+// differs between detection runs.
+func licenseTypes(p merge.Project) string {
+	if len(p.Licenses) == 0 {
+		return "none"
+	}
+	types := make([]string, len(p.Licenses))
+	for i, lic := range p.Licenses {
+		types[i] = lic.Type
+		if lic.Exception != "" {
+			types[i] += " WITH " + lic.Exception
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
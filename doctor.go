@@ -0,0 +1,271 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+type doctorCheck struct {
+	name string
+	run  func() error
+	hint string
+}
+
+// runDoctor checks the prerequisites of a merge with the given merge flags
+// and prints remediation hints. It reports whether all checks passed.
+func runDoctor(args []string) bool {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.AddFlagSet(flag.CommandLine)
+	_ = fs.Parse(args)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var checks []doctorCheck
+
+	for _, host := range []string{"github.com", "golang.org", "gopkg.in"} {
+		u := "https://" + host
+		checks = append(checks, doctorCheck{
+			name: "reach " + u,
+			run:  func() error { return reachable(client, u) },
+			hint: "VCS roots are detected from go-import meta tags; allow HTTPS access to module hosts or set HTTPS_PROXY",
+		})
+	}
+
+	goproxy := os.Getenv("GOPROXY")
+	if goproxy == "" {
+		goproxy = "https://proxy.golang.org,direct"
+	}
+	for _, entry := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		if !strings.HasPrefix(entry, "http://") && !strings.HasPrefix(entry, "https://") {
+			continue
+		}
+		u := entry
+		checks = append(checks, doctorCheck{
+			name: "reach GOPROXY " + u,
+			run:  func() error { return reachable(client, u) },
+			hint: "check the GOPROXY environment variable and proxy credentials",
+		})
+	}
+
+	if vcsCacheURL != "" {
+		checks = append(checks, doctorCheck{
+			name: "VCS cache " + vcsCacheURL,
+			run: func() error {
+				cache, err := newVCSCache(vcsCacheURL)
+				if err != nil {
+					return err
+				}
+				// probe without adding an entry to the user's cache
+				if c, ok := cache.(*fileCache); ok {
+					return writable(existingDir(filepath.Dir(c.filename)))
+				}
+				_, _, err = cache.Get("bom-merger.doctor")
+				return err
+			},
+			hint: "make sure the cache service is running and writable, or drop --vcs-cache",
+		})
+	}
+
+	for _, dir := range []struct{ name, path string }{
+		{"output directory", opts.Out},
+		{"history directory", opts.HistoryDir},
+	} {
+		if dir.path == "" || dir.path == stdio {
+			continue
+		}
+		d := dir.path
+		checks = append(checks, doctorCheck{
+			name: dir.name + " " + d + " is writable",
+			run:  func() error { return writable(d) },
+			hint: "create the directory or fix its permissions",
+		})
+	}
+
+	for _, in := range opts.In {
+		in := in
+		if urls, ok := remoteInputs(in); ok {
+			remote := newRemoteClient()
+			for _, u := range urls {
+				u := u
+				checks = append(checks, doctorCheck{
+					name: "input " + u + " is downloadable",
+					run: func() error {
+						_, err := remote.Fragments(u)
+						return err
+					},
+					hint: "check the URL and the AWS_* or GOOGLE_OAUTH_ACCESS_TOKEN credentials of private buckets",
+				})
+			}
+		} else if in != stdio {
+			checks = append(checks, doctorCheck{
+				name: "input " + in + " is readable",
+				run: func() error {
+					if isArchive(in) {
+						_, err := readArchive(in)
+						return err
+					}
+					fi, err := os.Stat(in)
+					if err != nil {
+						return err
+					}
+					if fi.IsDir() {
+						_, err = ioutil.ReadDir(in)
+					} else {
+						_, err = ioutil.ReadFile(in)
+					}
+					return err
+				},
+				hint: "--in must point to a directory, a file or an archive of BOM files",
+			})
+		}
+	}
+	var files []struct{ name, path string }
+	for _, f := range opts.OverrideFiles {
+		files = append(files, struct{ name, path string }{"override file", f})
+	}
+	files = append(files, struct{ name, path string }{"labels file", opts.LabelsFile})
+	for _, file := range files {
+		if file.path == "" || file.path == stdio {
+			continue
+		}
+		f := file.path
+		checks = append(checks, doctorCheck{
+			name: file.name + " " + f + " is valid",
+			run: func() error {
+				// a directory of override files is checked file by file
+				expanded, err := overrideFiles([]string{f})
+				if err != nil {
+					return err
+				}
+				for _, f := range expanded {
+					if err := validJSONC(f); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			hint: "fix the reported syntax error; comments and trailing commas are allowed",
+		})
+	}
+	if opts.PolicyFile != "" {
+		checks = append(checks, doctorCheck{
+			name: "policy file " + opts.PolicyFile + " is valid",
+			run: func() error {
+				_, err := readPolicy(opts.PolicyFile)
+				return err
+			},
+			hint: "the policy needs allow or deny lists of SPDX identifiers; comments and trailing commas are allowed",
+		})
+	}
+	if opts.WaiversFile != "" {
+		checks = append(checks, doctorCheck{
+			name: "waivers file " + opts.WaiversFile + " is valid",
+			run: func() error {
+				_, err := readWaivers(opts.WaiversFile)
+				return err
+			},
+			hint: "the waivers file is a YAML list, where every waiver needs a project, approvedBy and reason, and expires dates are YYYY-MM-DD",
+		})
+	}
+	if manifestFile != "" {
+		checks = append(checks, doctorCheck{
+			name: "manifest " + manifestFile + " is valid",
+			run: func() error {
+				m, err := loadManifest(manifestFile)
+				if err != nil {
+					return err
+				}
+				_, err = m.jobOptions(opts)
+				return err
+			},
+			hint: "every job needs in and out, must refer to a defined profile, and includes and extends must not form cycles",
+		})
+	}
+	if opts.Locked {
+		lockFile := newMerger(opts, nil).lockFile()
+		checks = append(checks, doctorCheck{
+			name: "lock file " + lockFile + " is valid",
+			run: func() error {
+				_, err := readLockFile(lockFile)
+				return err
+			},
+			hint: "run bom-merger lock to create or update the lock file",
+		})
+	}
+
+	ok := true
+	for _, c := range checks {
+		if err := c.run(); err != nil {
+			ok = false
+			fmt.Printf("[fail] %s: %v\n       hint: %s\n", c.name, err, c.hint)
+		} else {
+			fmt.Printf("[ok]   %s\n", c.name)
+		}
+	}
+	return ok
+}
+
+func reachable(client *http.Client, u string) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func writable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".bom-merger-doctor-")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// existingDir returns dir, or its closest ancestor that exists, which is
+// where a missing directory would be created.
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func validJSONC(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	return json.Unmarshal(stripJSONC(data), &v)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of bom-merger. Errors that are not classified otherwise, such
+// as invalid flags, unreadable or malformed inputs and failed writes, exit
+// with exitInput.
+const (
+	exitOK      = 0
+	exitPolicy  = 1
+	exitInput   = 2
+	exitNetwork = 3
+)
+
+// exitError carries the exit code of an error up to main.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return err
+	}
+	return &exitError{code: code, err: err}
+}
+
+// networkError marks err as a failed lookup of a remote service.
+func networkError(err error) error {
+	return withExitCode(exitNetwork, err)
+}
+
+// policyError marks err as a violated policy or guardrail.
+func policyError(err error) error {
+	return withExitCode(exitPolicy, err)
+}
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitInput
+}
+
+// skip reports err as a warning and returns nil with --continue-on-error,
+// so the merge goes on without the failed input or lookup. Otherwise it
+// returns err.
+func (m *merger) skip(err error) error {
+	if err == nil || !m.opts.ContinueOnError {
+		return err
+	}
+	warnf("%v (skipped)", err)
+	m.auditf("skip", "", "%v", err)
+	m.skipped = append(m.skipped, err)
+	return nil
+}
+
+// skippedError summarizes the errors skipped by --continue-on-error, and
+// cause, if the run failed after skipping them. It exits with the highest
+// exit code of all of them.
+func (m *merger) skippedError(cause error) error {
+	if len(m.skipped) == 0 {
+		return cause
+	}
+	code := exitCode(cause)
+	for _, err := range m.skipped {
+		if c := exitCode(err); c > code {
+			code = c
+		}
+	}
+	noun := "errors"
+	if len(m.skipped) == 1 {
+		noun = "error"
+	}
+	msg := fmt.Sprintf("%d %s skipped by --continue-on-error, the first: %v", len(m.skipped), noun, m.skipped[0])
+	if cause != nil {
+		msg = fmt.Sprintf("%v; %s", cause, msg)
+	}
+	return &exitError{code: code, err: errors.New(msg)}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runExplain merges the inputs with the regular merge flags without writing
+// any output and prints every step of the pipeline that touched a project,
+// followed by its final entry.
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.AddFlagSet(flag.CommandLine)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || len(opts.In) == 0 {
+		return errors.New("usage: bom-merger explain --in=DIR [merge flags] PROJECT")
+	}
+
+	res, err := newResources()
+	if err != nil {
+		return err
+	}
+	m := newMerger(opts, res)
+	m.explain = fs.Arg(0)
+	if err := m.merge(); err != nil {
+		return err
+	}
+	if len(m.trace) == 0 {
+		return fmt.Errorf("%s is not found in the inputs", m.explain)
+	}
+	for _, line := range m.trace {
+		fmt.Println(line)
+	}
+
+	outputs := []struct {
+		name string
+		reg  *merge.Registry
+	}{
+		{"bom.json", m.bom},
+		{"bom_error.json", m.errors},
+		{"bom_review.json", m.review},
+		{"bom_filtered.json", m.filtered},
+	}
+	for _, o := range outputs {
+		p, ok := o.reg.Get(m.explain)
+		if !ok {
+			continue
+		}
+		data, err := MarshalJson(p)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nfinal entry in %s:\n%s", o.name, data)
+	}
+	return nil
+}
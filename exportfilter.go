@@ -0,0 +1,109 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// exportFilter selects the entries human oriented exporters include, so
+// they only show what needs attention while machine formats keep
+// everything. Filters look like
+//
+//	category in (copyleft, unknown)
+//	scope not in (tool)
+//	license = MIT
+//
+// and match the license category, the scope or any license type.
+type exportFilter struct {
+	field  string
+	negate bool
+	values map[string]bool
+}
+
+var exportFilterFields = map[string]func(merge.Project) []string{
+	"category": func(p merge.Project) []string { return []string{string(merge.Categorize(p))} },
+	"scope":    func(p merge.Project) []string { return []string{p.Scope} },
+	"license": func(p merge.Project) []string {
+		types := make([]string, len(p.Licenses))
+		for i, lic := range p.Licenses {
+			types[i] = lic.Type
+		}
+		return types
+	},
+}
+
+var exportFilterRE = regexp.MustCompile(`(?i)^\s*(\w+)\s*(not\s+in|in|!=|=)\s*(.*?)\s*$`)
+
+func parseExportFilter(expr string) (*exportFilter, error) {
+	match := exportFilterRE.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("invalid export filter %q, must be FIELD in (VALUE, ...) or FIELD = VALUE", expr)
+	}
+	f := &exportFilter{field: strings.ToLower(match[1]), values: map[string]bool{}}
+	if _, ok := exportFilterFields[f.field]; !ok {
+		return nil, fmt.Errorf("invalid export filter %q, field must be category, scope or license", expr)
+	}
+	op, list := strings.ToLower(strings.Join(strings.Fields(match[2]), " ")), match[3]
+	f.negate = op == "not in" || op == "!="
+	if op == "in" || op == "not in" {
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return nil, fmt.Errorf("invalid export filter %q, values must be in parentheses", expr)
+		}
+		list = list[1 : len(list)-1]
+	}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			f.values[strings.ToLower(v)] = true
+		}
+	}
+	if len(f.values) == 0 {
+		return nil, fmt.Errorf("invalid export filter %q, no values", expr)
+	}
+	return f, nil
+}
+
+// Match reports whether p is included by the filter.
+func (f *exportFilter) Match(p merge.Project) bool {
+	found := false
+	for _, v := range exportFilterFields[f.field](p) {
+		if f.values[strings.ToLower(v)] {
+			found = true
+			break
+		}
+	}
+	return found != f.negate
+}
+
+// Apply returns the projects included by the filter. A nil filter includes
+// everything.
+func (f *exportFilter) Apply(projects []merge.Project) []merge.Project {
+	if f == nil {
+		return projects
+	}
+	var out []merge.Project
+	for _, p := range projects {
+		if f.Match(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
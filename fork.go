@@ -0,0 +1,140 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// replacement is a replace directive of a go.mod file pointing a module to
+// another module, rather than to a local directory.
+type replacement struct {
+	Old, OldVersion string
+	New, NewVersion string
+}
+
+// readReplacements returns the replace directives of a go.mod file that
+// replace a module by another module path. Replacements by the same path,
+// which only pin a version, and by local directories are skipped.
+func readReplacements(filename string) ([]replacement, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []replacement
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case fields[0] == "replace" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == "replace":
+			fields = fields[1:]
+		case !inBlock:
+			continue
+		}
+
+		arrow := -1
+		for i, f := range fields {
+			if f == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 1 || arrow > 2 || len(fields)-arrow-1 < 1 || len(fields)-arrow-1 > 2 {
+			return nil, fmt.Errorf("%s: invalid replace directive %q", filename, strings.TrimSpace(line))
+		}
+		r := replacement{Old: fields[0], New: fields[arrow+1]}
+		if arrow == 2 {
+			r.OldVersion = fields[1]
+		}
+		if len(fields) == arrow+3 {
+			r.NewVersion = fields[arrow+2]
+		}
+		if r.NewVersion == "" || r.New == r.Old {
+			// local directory or version pin
+			continue
+		}
+		out = append(out, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filename, err)
+	}
+	return out, nil
+}
+
+// markForks sets the fork of every entry of regs that one of the go.mod
+// files replaces by another module, and its VCS root to the one of the
+// fork, which is where the code and its license actually come from.
+func (m *merger) markForks(files []string, regs ...*merge.Registry) error {
+	var replacements []replacement
+	for _, filename := range files {
+		r, err := readReplacements(filename)
+		if err != nil {
+			return err
+		}
+		replacements = append(replacements, r...)
+	}
+
+	for _, reg := range regs {
+		err := reg.Each(func(p merge.Project) error {
+			for _, r := range replacements {
+				if r.Old != p.Project || (r.OldVersion != "" && r.OldVersion != p.Version) {
+					continue
+				}
+				p.Fork = r.New + "@" + r.NewVersion
+				if !m.opts.Locked && !p.SkipVCS {
+					vcs, _, err := m.res.vcs.Resolve(r.New)
+					if err != nil {
+						if err := m.skip(networkError(err)); err != nil {
+							return err
+						}
+					} else if vcs != "" {
+						p.VCS = vcs
+					}
+				}
+				m.tracef(p.Project, "replaced by fork %s, VCS root set to %s", p.Fork, p.VCS)
+				m.auditf("fork", p.Project, "replaced by %s", p.Fork)
+				warnf("%s is replaced by the fork %s (%s), whose license may differ", p.Project, p.Fork, p.VCS)
+				reg.Set(p)
+				break
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// parseAge parses ages like 90d, 2w or any duration accepted by
+// time.ParseDuration, e.g. 36h.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for _, u := range []struct {
+		suffix string
+		unit   time.Duration
+	}{{"d", 24 * time.Hour}, {"w", 7 * 24 * time.Hour}} {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, u.suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n) * u.unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// formatSize formats n bytes in the units accepted by parseSize.
+func formatSize(n int64) string {
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if n >= u.size {
+			return fmt.Sprintf("%.1f%s", float64(n)/float64(u.size), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+func fileSize(filename string) (int64, error) {
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// gcFlags parses the flags shared by the gc subcommands and returns the
+// cutoff time.
+func gcFlags(fs *flag.FlagSet, args []string) (time.Time, bool, error) {
+	olderThan := fs.String("older-than", "", "Remove what was recorded longer ago than this, e.g. 90d, 2w or 36h")
+	dryRun := fs.Bool("dry-run", false, "Only report what would be removed")
+	_ = fs.Parse(args)
+	if *olderThan == "" {
+		return time.Time{}, false, errors.New("--older-than is required")
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Now().Add(-age), *dryRun, nil
+}
+
+func removedVerb(dryRun bool) string {
+	if dryRun {
+		return "would remove"
+	}
+	return "removed"
+}
+
+// runHistoryGC removes the history records recorded before the cutoff.
+func runHistoryGC(args []string) error {
+	fs := flag.NewFlagSet("history gc", flag.ExitOnError)
+	dir := fs.String("history-dir", "", "Path to directory where merged BOMs are recorded")
+	cutoff, dryRun, err := gcFlags(fs, args)
+	if err != nil || *dir == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: bom-merger history gc --history-dir=DIR --older-than=AGE [--dry-run]")
+	}
+
+	records, err := loadHistory(*dir)
+	if err != nil {
+		return err
+	}
+	var freed, kept int64
+	removed := 0
+	for _, rec := range records {
+		filename := historyFilename(*dir, rec.Label)
+		size, err := fileSize(filename)
+		if err != nil {
+			return err
+		}
+		if !rec.Recorded.Before(cutoff) {
+			kept += size
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filename); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s %s (%s, recorded %s)\n", removedVerb(dryRun), filename, formatSize(size), rec.Recorded.Format(time.RFC3339))
+		freed += size
+		removed++
+	}
+	fmt.Printf("%s %d of %d records, %s freed, %s left\n", removedVerb(dryRun), removed, len(records), formatSize(freed), formatSize(kept))
+	return nil
+}
+
+// runCache runs the cache subcommands. Only the file backend of the VCS
+// cache can be collected; redis and HTTP caches expire entries themselves.
+func runCache(args []string) error {
+	if len(args) == 0 || args[0] != "gc" {
+		return errors.New("usage: bom-merger cache gc --vcs-cache=FILE --older-than=AGE [--dry-run]")
+	}
+
+	fs := flag.NewFlagSet("cache gc", flag.ExitOnError)
+	location := fs.String("vcs-cache", vcsCacheURL, "Path to the VCS cache file")
+	cutoff, dryRun, err := gcFlags(fs, args[1:])
+	if err != nil || *location == "" || fs.NArg() != 0 {
+		return fmt.Errorf("usage: bom-merger cache gc --vcs-cache=FILE --older-than=AGE [--dry-run]")
+	}
+	if u, err := url.Parse(*location); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		return fmt.Errorf("cache gc only supports file caches, not %s", *location)
+	}
+	cache, err := newVCSCache(*location)
+	if err != nil {
+		return err
+	}
+	fc := cache.(*fileCache)
+
+	before, err := fileSize(fc.filename)
+	if err != nil {
+		return err
+	}
+	removed, kept, after, err := fc.gc(cutoff, dryRun)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		after = before
+	}
+	fmt.Printf("%s %d of %d entries, %s freed, %s left\n", removedVerb(dryRun), removed, removed+kept, formatSize(before-after), formatSize(after))
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errGitHubNotFound is returned for requests GitHub answers with 404.
+var errGitHubNotFound = errors.New("not found")
+
+// githubRepo holds the fields of the GitHub repository API used for
+// enrichment.
+type githubRepo struct {
+	Archived bool      `json:"archived"`
+	PushedAt time.Time `json:"pushed_at"`
+	License  *struct {
+		SPDXID string `json:"spdx_id"`
+	} `json:"license"`
+}
+
+// spdxID returns the SPDX identifier of the license GitHub detected for the
+// repository, or an empty string if it detected none or an unlisted one.
+func (r *githubRepo) spdxID() string {
+	if r.License == nil || r.License.SPDXID == "NOASSERTION" {
+		return ""
+	}
+	return r.License.SPDXID
+}
+
+// githubClient queries the GitHub REST API and caches responses per
+// repository for the lifetime of the process. GITHUB_TOKEN is used for
+// authentication if set.
+type githubClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+
+	mu    sync.Mutex
+	repos map[string]*githubLookup
+}
+
+type githubLookup struct {
+	done chan struct{}
+	repo *githubRepo
+	err  error
+}
+
+func newGitHubClient() *githubClient {
+	return &githubClient{
+		baseURL: "https://api.github.com",
+		token:   os.Getenv("GITHUB_TOKEN"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		repos:   map[string]*githubLookup{},
+	}
+}
+
+// githubRepoPath returns owner/repo for github.com VCS roots.
+func githubRepoPath(vcs string) (string, bool) {
+	parts := strings.Split(vcs, "/")
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", false
+	}
+	return parts[1] + "/" + parts[2], true
+}
+
+func (c *githubClient) Repo(ownerRepo string) (*githubRepo, error) {
+	c.mu.Lock()
+	l, ok := c.repos[ownerRepo]
+	if !ok {
+		l = &githubLookup{done: make(chan struct{})}
+		c.repos[ownerRepo] = l
+		c.mu.Unlock()
+
+		l.repo, l.err = c.fetchRepo(ownerRepo)
+		close(l.done)
+	} else {
+		c.mu.Unlock()
+		<-l.done
+	}
+	return l.repo, l.err
+}
+
+func (c *githubClient) fetchRepo(ownerRepo string) (*githubRepo, error) {
+	var repo githubRepo
+	if err := c.get("/repos/"+ownerRepo, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+func (c *githubClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("GET %s%s: %w", c.baseURL, path, errGitHubNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s%s: %s", c.baseURL, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// validateGuardrails checks the guardrail options before a merge starts.
+func (m *merger) validateGuardrails() error {
+	switch m.opts.GuardrailAction {
+	case "", "fail", "warn":
+	default:
+		return fmt.Errorf("invalid guardrail action %q, must be fail or warn", m.opts.GuardrailAction)
+	}
+	if m.opts.MaxOutputSize != "" {
+		if _, err := parseSize(m.opts.MaxOutputSize); err != nil {
+			return fmt.Errorf("invalid max output size %q: %v", m.opts.MaxOutputSize, err)
+		}
+	}
+	return nil
+}
+
+// checkGuardrails catches merges that produce far more than expected, e.g.
+// because --in points at the wrong directory, before bom.json is written.
+func (m *merger) checkGuardrails() error {
+	var violations []string
+	if m.opts.MaxComponents > 0 && m.bom.Len() > m.opts.MaxComponents {
+		violations = append(violations, fmt.Sprintf("%d components exceed --max-components=%d", m.bom.Len(), m.opts.MaxComponents))
+	}
+	if m.opts.MaxOutputSize != "" {
+		limit, _ := parseSize(m.opts.MaxOutputSize)
+		reg, err := m.outputRegistry(m.bom, merge.HighestConfidence)
+		if err != nil {
+			return err
+		}
+		data, err := marshalOutput(sortedProjects(reg, m.opts.SortBy), m.opts.Compact)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > limit {
+			violations = append(violations, fmt.Sprintf("bom.json of %d bytes exceeds --max-output-size=%s", len(data), m.opts.MaxOutputSize))
+		}
+	}
+
+	for _, v := range violations {
+		m.auditf("policy", "", "guardrail violated: %s", v)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	if m.opts.GuardrailAction == "warn" {
+		for _, v := range violations {
+			warnf("%s", v)
+		}
+		return nil
+	}
+	return fmt.Errorf("guardrails violated, not writing outputs: %s", strings.Join(violations, "; "))
+}
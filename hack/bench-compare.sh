@@ -0,0 +1,69 @@
+#!/usr/bin/env bash
+
+# Copyright AppsCode Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -eou pipefail
+
+# Runs the benchmarks on BASE and on the working tree and fails if any
+# benchmark got slower by more than THRESHOLD percent in ns/op. The results
+# are shown with benchstat if it is installed.
+
+export GO111MODULE=on
+export GOFLAGS="-mod=vendor"
+
+BASE=${BASE:-master}
+THRESHOLD=${THRESHOLD:-10}
+BENCH=${BENCH:-.}
+COUNT=${COUNT:-5}
+BENCHTIME=${BENCHTIME:-1x}
+
+out=$(mktemp -d)
+trap 'git worktree remove --force "$out/base" >/dev/null 2>&1 || true' EXIT
+
+run() {
+    go test -run '^$' -bench "$BENCH" -benchmem -count "$COUNT" -benchtime "$BENCHTIME" . | tee "$1"
+}
+
+echo "Running benchmarks of $BASE:"
+git worktree add --detach "$out/base" "$BASE" >/dev/null
+(cd "$out/base" && run "$out/old.txt")
+echo
+echo "Running benchmarks of the working tree:"
+run "$out/new.txt"
+echo
+
+if command -v benchstat >/dev/null; then
+    benchstat "$out/old.txt" "$out/new.txt"
+    echo
+fi
+
+# average ns/op per benchmark, name without the GOMAXPROCS suffix
+averages() {
+    awk '/^Benchmark/ { sub(/-[0-9]+$/, "", $1); sum[$1] += $3; n[$1]++ }
+        END { for (b in sum) printf "%s %f\n", b, sum[b] / n[b] }' "$1" | sort
+}
+
+join <(averages "$out/old.txt") <(averages "$out/new.txt") | awk -v threshold="$THRESHOLD" '
+    {
+        delta = ($3 - $2) / $2 * 100
+        printf "%-40s %14.0f %14.0f %+7.1f%%\n", $1, $2, $3, delta
+        if (delta > threshold) { failed = failed " " $1 }
+    }
+    END {
+        if (failed != "") {
+            printf "\nslower by more than %s%%:%s\n", threshold, failed
+            exit 1
+        }
+    }'
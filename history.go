@@ -0,0 +1,197 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// historyRecord is a merged BOM stored in the history directory. Each record
+// usually corresponds to one release of the product.
+type historyRecord struct {
+	Label    string          `json:"label"`
+	Recorded time.Time       `json:"recorded"`
+	Projects []merge.Project `json:"projects"`
+}
+
+func historyFilename(dir, label string) string {
+	return filepath.Join(dir, strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(label)+".json")
+}
+
+// historyLabel returns the label the merge is recorded under, the start of
+// the run unless --history-label is set.
+func (m *merger) historyLabel() string {
+	if m.opts.HistoryLabel != "" {
+		return m.opts.HistoryLabel
+	}
+	return m.started.UTC().Format("20060102T150405Z")
+}
+
+func (m *merger) recordHistory(reg *merge.Registry) error {
+	dir := m.opts.HistoryDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	label := m.historyLabel()
+	data, err := MarshalJson(historyRecord{
+		Label:    label,
+		Recorded: m.started.UTC(),
+		Projects: reg.Projects(),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(historyFilename(dir, label), data, 0644)
+}
+
+// loadHistory returns all records in dir, oldest first.
+func loadHistory(dir string) ([]historyRecord, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var records []historyRecord
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		filename := filepath.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var rec historyRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse history record %s: %v", filename, err)
+		}
+		records = append(records, rec)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Recorded.Before(records[j].Recorded)
+	})
+	return records, nil
+}
+
+// markIntroduced sets the label and date of the first recorded BOM that
+// contained each project of reg, or of this run for projects the history
+// has not seen yet. A missing history directory has no records.
+func (m *merger) markIntroduced(reg *merge.Registry) error {
+	records, err := loadHistory(m.opts.HistoryDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	first := map[string]*historyRecord{}
+	for i := range records {
+		for _, p := range records[i].Projects {
+			if _, ok := first[p.Project]; !ok {
+				first[p.Project] = &records[i]
+			}
+		}
+	}
+	return reg.Each(func(p merge.Project) error {
+		if rec, ok := first[p.Project]; ok {
+			p.IntroducedIn, p.IntroducedAt = rec.Label, rec.Recorded.UTC().Format("2006-01-02")
+		} else {
+			p.IntroducedIn, p.IntroducedAt = m.historyLabel(), m.started.UTC().Format("2006-01-02")
+		}
+		m.tracef(p.Project, "introduced in %s on %s", p.IntroducedIn, p.IntroducedAt)
+		reg.Set(p)
+		return nil
+	})
+}
+
+// newProjects returns the projects of the BOM first recorded by this run.
+func (m *merger) newProjects() []string {
+	if m.opts.HistoryDir == "" {
+		return nil
+	}
+	var out []string
+	label := m.historyLabel()
+	_ = m.bom.Each(func(p merge.Project) error {
+		if p.IntroducedIn == label {
+			out = append(out, versionedPath(p.Project, p.Version))
+		}
+		return nil
+	})
+	return out
+}
+
+// matchesModule reports whether project is module or a package path below it.
+func matchesModule(project, module string) bool {
+	return project == module || strings.HasPrefix(project, module+"/")
+}
+
+func runHistory(args []string) error {
+	if len(args) > 0 && args[0] == "gc" {
+		return runHistoryGC(args[1:])
+	}
+	if len(args) == 0 || args[0] != "search" {
+		return errors.New("usage: bom-merger history search|gc [--history-dir=DIR] ...")
+	}
+
+	fs := flag.NewFlagSet("history search", flag.ExitOnError)
+	dir := fs.String("history-dir", "", "Path to directory where merged BOMs are recorded")
+	_ = fs.Parse(args[1:])
+	if *dir == "" || fs.NArg() != 1 {
+		return errors.New("usage: bom-merger history search --history-dir=DIR MODULE[@VERSION]")
+	}
+	module, version := fs.Arg(0), ""
+	if i := strings.LastIndex(module, "@"); i >= 0 {
+		module, version = module[:i], module[i+1:]
+	}
+
+	records, err := loadHistory(*dir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	found := false
+	for _, rec := range records {
+		for _, p := range rec.Projects {
+			if !matchesModule(p.Project, module) || version != "" && p.Version != version {
+				continue
+			}
+			var licenses []string
+			for _, lic := range p.Licenses {
+				licenses = append(licenses, lic.Type)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", rec.Label, rec.Recorded.Format(time.RFC3339), p.Project, p.Version, strings.Join(licenses, ","))
+			found = true
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "%s not found in %d recorded BOMs\n", fs.Arg(0), len(records))
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// htmlLicense is a row of the license summary of the HTML report.
+type htmlLicense struct {
+	License  string
+	Category merge.LicenseCategory
+	Count    int
+}
+
+// htmlData is the data the HTML report is rendered from.
+type htmlData struct {
+	Licenses []htmlLicense
+	Header   []string
+	Rows     [][]string
+	Errors   int
+	Review   int
+}
+
+// htmlReport is a single page without external resources, so it can be
+// attached to a release as is. The table is filtered in the browser by
+// the search box or by clicking a license in the summary.
+var htmlReport = template.Must(template.New("bom_report.html").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Third-party licenses</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f4f4f4; }
+#licenses tbody tr { cursor: pointer; }
+#licenses tbody tr:hover { background: #eef; }
+#search { width: 30em; padding: 0.3em; margin-bottom: 1em; }
+</style>
+</head>
+<body>
+<h1>Third-party licenses</h1>
+<p>{{len .Rows}} projects under {{len .Licenses}} licenses.{{if .Errors}} {{.Errors}} projects could not be resolved.{{end}}{{if .Review}} {{.Review}} projects need review.{{end}}</p>
+<h2>Licenses</h2>
+<table id="licenses">
+<thead><tr><th>License</th><th>Category</th><th>Projects</th></tr></thead>
+<tbody>
+{{- range .Licenses}}
+<tr data-license="{{.License}}"><td>{{.License}}</td><td>{{.Category}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<h2>Projects</h2>
+<input id="search" type="search" placeholder="Filter by project, version, license or VCS">
+<table id="projects">
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+var search = document.getElementById("search");
+var rows = document.querySelectorAll("#projects tbody tr");
+function filter() {
+  var q = search.value.toLowerCase();
+  rows.forEach(function (tr) {
+    tr.style.display = tr.textContent.toLowerCase().indexOf(q) >= 0 ? "" : "none";
+  });
+}
+search.addEventListener("input", filter);
+document.querySelectorAll("#licenses tbody tr").forEach(function (tr) {
+  tr.addEventListener("click", function () {
+    search.value = tr.dataset.license;
+    filter();
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// writeHTMLReport renders the merged BOM to bom_report.html in dir, with
+// the number of projects per license and a table of the projects with the
+// columns of --format=csv, and the release they were introduced in with
+// --history-dir.
+func (m *merger) writeHTMLReport(dir string) error {
+	var filter *exportFilter
+	if m.opts.ExportFilter != "" {
+		var err error
+		if filter, err = parseExportFilter(m.opts.ExportFilter); err != nil {
+			return err
+		}
+	}
+	data := htmlData{
+		Header: tableHeader,
+		Errors: m.errors.Len(),
+		Review: m.review.Len(),
+	}
+	if m.opts.HistoryDir != "" {
+		data.Header = append(append([]string(nil), tableHeader...), "Introduced")
+	}
+	licenses := map[string]*htmlLicense{}
+	for _, p := range filter.Apply(sortedProjects(m.bom, reportOrder(m.opts.SortBy))) {
+		row := tableRow(p)
+		if m.opts.HistoryDir != "" {
+			row = append(row, strings.TrimSpace(p.IntroducedIn+" "+p.IntroducedAt))
+		}
+		data.Rows = append(data.Rows, row)
+		license := row[2]
+		if license == "" {
+			license = "unknown"
+		}
+		if licenses[license] == nil {
+			licenses[license] = &htmlLicense{License: license, Category: p.Category}
+		}
+		licenses[license].Count++
+	}
+	for _, l := range licenses {
+		data.Licenses = append(data.Licenses, *l)
+	}
+	sort.Slice(data.Licenses, func(i, j int) bool {
+		a, b := data.Licenses[i], data.Licenses[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.License < b.License
+	})
+
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "bom_report.html"), buf.Bytes(), 0644)
+}
+
+// htmlDiffSection lists the changes of one type in the HTML diff report.
+type htmlDiffSection struct {
+	Title   string
+	Open    bool
+	Changes []bomChange
+}
+
+// htmlDiffData is the data the HTML diff report is rendered from.
+type htmlDiffData struct {
+	Old, New string
+	Total    int
+	Sections []htmlDiffSection
+}
+
+// htmlDiffReport shows the changes between two BOMs in a collapsible
+// section per change type. License changes come first and are expanded,
+// since they are what legal review is after.
+var htmlDiffReport = template.Must(template.New("bom_diff.html").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Third-party license changes</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f4f4f4; }
+summary { cursor: pointer; font-size: 1.2em; font-weight: bold; margin: 0.5em 0; }
+del { color: #a00; }
+ins { color: #070; text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>Third-party license changes</h1>
+<p>{{.Total}} changes from {{.Old}} to {{.New}}.</p>
+{{- range .Sections}}
+<details{{if .Open}} open{{end}}>
+<summary>{{.Title}} ({{len .Changes}})</summary>
+{{- if .Changes}}
+<table>
+<thead><tr><th>Project</th><th>Version</th><th>Licenses</th></tr></thead>
+<tbody>
+{{- range .Changes}}
+<tr><td>{{.Project}}</td>
+{{- if eq .Change "added"}}<td><ins>{{.NewVersion}}</ins></td><td><ins>{{.NewLicenses}}</ins></td>
+{{- else if eq .Change "removed"}}<td><del>{{.OldVersion}}</del></td><td><del>{{.OldLicenses}}</del></td>
+{{- else}}<td>{{if ne .OldVersion .NewVersion}}<del>{{.OldVersion}}</del> <ins>{{.NewVersion}}</ins>{{else}}{{.NewVersion}}{{end}}</td><td>{{if ne .OldLicenses .NewLicenses}}<del>{{.OldLicenses}}</del> <ins>{{.NewLicenses}}</ins>{{else}}{{.NewLicenses}}{{end}}</td>
+{{- end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- else}}
+<p>None.</p>
+{{- end}}
+</details>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeHTMLDiff renders the changes between the BOMs oldName and newName to
+// filename, grouped into license changes, additions, removals and version
+// changes that keep the licenses.
+func writeHTMLDiff(filename, oldName, newName string, changes []bomChange) error {
+	sections := []htmlDiffSection{
+		{Title: "License changes", Open: true},
+		{Title: "Added", Open: true},
+		{Title: "Removed"},
+		{Title: "Version changes"},
+	}
+	for _, c := range changes {
+		i := 0
+		switch {
+		case c.Change == changeAdded:
+			i = 1
+		case c.Change == changeRemoved:
+			i = 2
+		case c.OldLicenses == c.NewLicenses:
+			i = 3
+		}
+		sections[i].Changes = append(sections[i].Changes, c)
+	}
+
+	var buf bytes.Buffer
+	err := htmlDiffReport.Execute(&buf, htmlDiffData{
+		Old:      oldName,
+		New:      newName,
+		Total:    len(changes),
+		Sections: sections,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+func TestWriteHTMLDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mit := []merge.License{{Type: "MIT"}}
+	oldProjects := []merge.Project{
+		{Project: "example.com/relicensed", Version: "v1.0.0", Licenses: mit},
+		{Project: "example.com/bumped", Version: "v1.0.0", Licenses: mit},
+		{Project: "example.com/removed", Licenses: mit},
+	}
+	newProjects := []merge.Project{
+		{Project: "example.com/relicensed", Version: "v1.1.0", Licenses: []merge.License{{Type: "GPL-3.0"}}},
+		{Project: "example.com/bumped", Version: "v1.2.0", Licenses: mit},
+		{Project: "example.com/<added>", Licenses: mit},
+	}
+	filename := filepath.Join(dir, "diff.html")
+	if err := writeHTMLDiff(filename, "old.json", "new.json", diffProjects(oldProjects, newProjects, false)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+
+	// every project is listed in the section of its change type
+	sections := strings.Split(page, "<details")[1:]
+	want := []struct{ summary, project string }{
+		{"License changes (1)", "example.com/relicensed"},
+		{"Added (1)", "example.com/&lt;added&gt;"},
+		{"Removed (1)", "example.com/removed"},
+		{"Version changes (1)", "example.com/bumped"},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("found %d sections, want %d:\n%s", len(sections), len(want), page)
+	}
+	for i, w := range want {
+		if !strings.Contains(sections[i], "<summary>"+w.summary+"</summary>") || !strings.Contains(sections[i], "<td>"+w.project+"</td>") {
+			t.Errorf("section %d does not list %s under %q:\n%s", i, w.project, w.summary, sections[i])
+		}
+	}
+	if !strings.Contains(page, "<del>MIT</del> <ins>GPL-3.0</ins>") {
+		t.Errorf("license change not highlighted:\n%s", page)
+	}
+}
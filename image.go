@@ -0,0 +1,389 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// imageFragmentKey is the image label or manifest annotation holding a
+	// BOM fragment, either as JSON or base64 encoded JSON.
+	imageFragmentKey = "com.appscode.bom-merger.fragment"
+	// imageFragmentType is the artifact type of referrers whose first
+	// layer is a BOM fragment.
+	imageFragmentType = "application/vnd.appscode.bom-merger.fragment+json"
+
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerImage = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// imageRef is a parsed image reference such as ghcr.io/org/app:v1.0.0.
+type imageRef struct {
+	Registry   string
+	Repository string
+	Reference  string // tag or digest
+}
+
+func parseImageRef(s string) (imageRef, error) {
+	ref := imageRef{Registry: "registry-1.docker.io", Reference: "latest"}
+	name := s
+	digest := ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+		ref.Reference = digest
+	}
+	// the tag of name@digest is informational, the digest is pulled
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+		if digest == "" {
+			ref.Reference = s[i+1:]
+		}
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if parts[0] != "docker.io" {
+			ref.Registry = parts[0]
+		}
+		name = parts[1]
+	}
+	if name == "" || ref.Reference == "" {
+		return imageRef{}, fmt.Errorf("invalid image reference %q", s)
+	}
+	if !strings.Contains(name, "/") && ref.Registry == "registry-1.docker.io" {
+		name = "library/" + name
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+type ociDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Config       ociDescriptor     `json:"config"`
+	Layers       []ociDescriptor   `json:"layers"`
+	Manifests    []ociDescriptor   `json:"manifests"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type imageConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// registryClient reads BOM fragments from container registries with the
+// distribution API. Anonymous bearer tokens are requested as needed.
+type registryClient struct {
+	client *http.Client
+}
+
+func newRegistryClient() *registryClient {
+	return &registryClient{
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Fragments returns the BOM fragments stored in the labels of the image
+// config, in the manifest annotations and in referrers of the image.
+func (c *registryClient) Fragments(image string) ([]sourceFragment, error) {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return nil, err
+	}
+	s := &registrySession{c: c, ref: ref}
+
+	var out []sourceFragment
+	add := func(source, value string) error {
+		data, err := decodeFragmentValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", source, err)
+		}
+		out = append(out, sourceFragment{Source: source, Data: data})
+		return nil
+	}
+
+	m, digest, err := s.manifest(ref.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := m.Annotations[imageFragmentKey]; ok {
+		if err := add(image+"#annotation", v); err != nil {
+			return nil, err
+		}
+	}
+	referrersOf := digest
+	if m.MediaType == mediaTypeOCIIndex || m.MediaType == mediaTypeDockerList {
+		if len(m.Manifests) == 0 {
+			return nil, fmt.Errorf("image index %s has no manifests", image)
+		}
+		// platform images are built from the same sources, so the first
+		// one stands for all of them
+		if m, _, err = s.manifest(m.Manifests[0].Digest); err != nil {
+			return nil, err
+		}
+	}
+	if m.Config.Digest != "" {
+		data, err := s.blob(m.Config.Digest)
+		if err != nil {
+			return nil, err
+		}
+		var cfg imageConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config of %s: %v", image, err)
+		}
+		if v, ok := cfg.Config.Labels[imageFragmentKey]; ok {
+			if err := add(image+"#label", v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	referrers, err := s.referrers(referrersOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range referrers {
+		am, _, err := s.manifest(r.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if len(am.Layers) == 0 {
+			continue
+		}
+		data, err := s.blob(am.Layers[0].Digest)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sourceFragment{Source: image + "#referrer=" + r.Digest, Data: data})
+	}
+	return out, nil
+}
+
+func decodeFragmentValue(v string) ([]byte, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "{") || strings.HasPrefix(v, "[") {
+		return []byte(v), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("fragment is neither JSON nor base64 encoded JSON")
+	}
+	return data, nil
+}
+
+// registrySession talks to the repository of one image and keeps the
+// bearer token obtained for it.
+type registrySession struct {
+	c     *registryClient
+	ref   imageRef
+	token string
+}
+
+func (s *registrySession) url(path string) string {
+	scheme := "https"
+	// like docker, talk plain HTTP to registries on the local host only
+	if host := strings.Split(s.ref.Registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, s.ref.Registry, s.ref.Repository, path)
+}
+
+func (s *registrySession) manifest(reference string) (*ociManifest, string, error) {
+	accept := strings.Join([]string{mediaTypeOCIIndex, mediaTypeDockerList, mediaTypeOCIManifest, mediaTypeDockerImage}, ", ")
+	data, header, err := s.get(s.url("manifests/"+reference), accept)
+	if err != nil {
+		return nil, "", err
+	}
+	var m ociManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest %s of %s: %v", reference, s.ref.Repository, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = header.Get("Content-Type")
+	}
+	digest := header.Get("Docker-Content-Digest")
+	if digest == "" && strings.HasPrefix(reference, "sha256:") {
+		digest = reference
+	}
+	return &m, digest, nil
+}
+
+func (s *registrySession) blob(digest string) ([]byte, error) {
+	data, _, err := s.get(s.url("blobs/"+digest), "")
+	return data, err
+}
+
+// referrers lists the BOM fragment artifacts attached to digest. Registries
+// without the referrers API report none.
+func (s *registrySession) referrers(digest string) ([]ociDescriptor, error) {
+	if digest == "" {
+		return nil, nil
+	}
+	data, _, err := s.get(s.url("referrers/"+digest+"?artifactType="+url.QueryEscape(imageFragmentType)), mediaTypeOCIIndex)
+	if err != nil {
+		if e, ok := err.(*registryError); ok && e.status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var index ociManifest
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse referrers of %s: %v", s.ref.Repository, err)
+	}
+	var out []ociDescriptor
+	for _, d := range index.Manifests {
+		if d.ArtifactType == imageFragmentType {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+type registryError struct {
+	url    string
+	status int
+	msg    string
+}
+
+func (e *registryError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.url, e.msg)
+}
+
+func (s *registrySession) get(u, accept string) ([]byte, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if s.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		}
+		resp, err := s.c.client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := s.authorize(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, nil, &registryError{url: u, status: resp.StatusCode, msg: resp.Status}
+		}
+		return data, resp.Header, nil
+	}
+}
+
+// authorize requests an anonymous token from the realm named in a
+// WWW-Authenticate: Bearer challenge.
+func (s *registrySession) authorize(challenge string) error {
+	if len(challenge) < len("Bearer ") || !strings.EqualFold(challenge[:len("Bearer ")], "Bearer ") {
+		return fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	params := parseChallengeParams(challenge[len("Bearer "):])
+	q := url.Values{}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		q.Set("scope", params["scope"])
+	}
+	resp, err := s.c.client.Get(params["realm"] + "?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token request to %s: %s", params["realm"], resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	s.token = token.Token
+	if s.token == "" {
+		s.token = token.AccessToken
+	}
+	return nil
+}
+
+// parseChallengeParams parses the comma separated key=value parameters of a
+// WWW-Authenticate challenge. Values may be quoted strings, which can hold
+// commas, e.g. scope="repository:org/app:pull,push", and backslash escapes.
+func parseChallengeParams(s string) map[string]string {
+	params := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[key] = value.String()
+	}
+}
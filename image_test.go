@@ -0,0 +1,92 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImageRef(t *testing.T) {
+	const digest = "sha256:2d3b4e52c1a3f1e8c0d2b1a9f6e7d8c9b0a1f2e3d4c5b6a7980f1e2d3c4b5a69"
+	cases := []struct {
+		in   string
+		want imageRef
+	}{
+		{"alpine", imageRef{"registry-1.docker.io", "library/alpine", "latest"}},
+		{"alpine:3.18", imageRef{"registry-1.docker.io", "library/alpine", "3.18"}},
+		{"docker.io/alpine:3.18", imageRef{"registry-1.docker.io", "library/alpine", "3.18"}},
+		{"docker.io/library/alpine", imageRef{"registry-1.docker.io", "library/alpine", "latest"}},
+		{"bitnami/redis:7.2", imageRef{"registry-1.docker.io", "bitnami/redis", "7.2"}},
+		{"ghcr.io/appscode/app:v1.0.0", imageRef{"ghcr.io", "appscode/app", "v1.0.0"}},
+		{"localhost/app", imageRef{"localhost", "app", "latest"}},
+		{"localhost:5000/app:dev", imageRef{"localhost:5000", "app", "dev"}},
+		{"registry.example.com:5000/team/app", imageRef{"registry.example.com:5000", "team/app", "latest"}},
+		{"alpine@" + digest, imageRef{"registry-1.docker.io", "library/alpine", digest}},
+		{"ghcr.io/appscode/app:v1.0.0@" + digest, imageRef{"ghcr.io", "appscode/app", digest}},
+		{"localhost:5000/app@" + digest, imageRef{"localhost:5000", "app", digest}},
+	}
+	for _, c := range cases {
+		got, err := parseImageRef(c.in)
+		if err != nil {
+			t.Errorf("parseImageRef(%q): %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseImageRef(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseImageRefErrors(t *testing.T) {
+	for _, in := range []string{"", "alpine:", "alpine@", "ghcr.io/"} {
+		if ref, err := parseImageRef(in); err == nil {
+			t.Errorf("parseImageRef(%q) = %+v, want error", in, ref)
+		}
+	}
+}
+
+func TestParseChallengeParams(t *testing.T) {
+	cases := []struct {
+		in   string
+		want map[string]string
+	}{
+		{
+			`realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`,
+			map[string]string{"realm": "https://auth.docker.io/token", "service": "registry.docker.io", "scope": "repository:library/alpine:pull"},
+		},
+		{
+			`realm="https://ghcr.io/token", service="ghcr.io", scope="repository:org/app:pull,push"`,
+			map[string]string{"realm": "https://ghcr.io/token", "service": "ghcr.io", "scope": "repository:org/app:pull,push"},
+		},
+		{
+			`Realm=https://example.com/token,service=example.com`,
+			map[string]string{"realm": "https://example.com/token", "service": "example.com"},
+		},
+		{
+			`realm="https://example.com/token",error="insufficient_scope",error_description="say \"hi\", please"`,
+			map[string]string{"realm": "https://example.com/token", "error": "insufficient_scope", "error_description": `say "hi", please`},
+		},
+		{`realm="unterminated`, map[string]string{"realm": "unterminated"}},
+		{``, map[string]string{}},
+	}
+	for _, c := range cases {
+		if got := parseChallengeParams(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseChallengeParams(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
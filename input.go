@@ -0,0 +1,185 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// loadInput loads the fragments of one --in: a comma separated list of
+// URLs, stdin, an archive, a directory or a single file. A file named
+// explicitly is loaded even if --include or --exclude would skip it.
+func (m *merger) loadInput(in string, filter inputFilter) error {
+	if urls, ok := remoteInputs(in); ok {
+		return m.loadRemote(urls, filter)
+	}
+	if in == stdio {
+		fragments, err := readStdinFragments(m.opts.InputFormat == formatYAML)
+		if err != nil {
+			return err
+		}
+		return m.loadSourceFragments(fragments)
+	}
+	if isArchive(in) {
+		fragments, err := readArchive(in)
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %v", in, err)
+		}
+		// like the files of a directory, load entries in order of name
+		sort.Slice(fragments, func(i, j int) bool { return fragments[i].Source < fragments[j].Source })
+		var matched []sourceFragment
+		for _, f := range fragments {
+			if filter.Match(strings.TrimPrefix(f.Source, in+"!")) {
+				matched = append(matched, f)
+			}
+		}
+		return m.loadSourceFragments(matched)
+	}
+
+	files := []string{in}
+	if fi, err := os.Stat(in); err != nil {
+		return err
+	} else if fi.IsDir() {
+		if files, err = inputFiles(in, m.opts.Recursive, filter); err != nil {
+			return err
+		}
+	}
+	return m.loadFragments(files, func(i int) ([]byte, error) {
+		return ioutil.ReadFile(files[i])
+	})
+}
+
+// inputFilter selects the files of the input directory or archive to load
+// with --include and --exclude glob patterns. Patterns containing a slash
+// match the path relative to the input, others the file name.
+type inputFilter struct {
+	include []string
+	exclude []string
+}
+
+func newInputFilter(include, exclude []string) (inputFilter, error) {
+	for _, pattern := range append(append([]string(nil), include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return inputFilter{}, fmt.Errorf("invalid input pattern %q: %v", pattern, err)
+		}
+	}
+	return inputFilter{include: include, exclude: exclude}, nil
+}
+
+// Match reports whether the file at the slash separated path rel is
+// included and not excluded.
+func (f inputFilter) Match(rel string) bool {
+	rel = strings.TrimPrefix(rel, "./")
+	return (len(f.include) == 0 || matchAny(f.include, rel)) && !matchAny(f.exclude, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inputFiles returns the files of dir matching filter, in order of name.
+// With recursive set, subdirectories are walked too, except hidden ones
+// like .git.
+func inputFiles(dir string, recursive bool, filter inputFilter) ([]string, error) {
+	if !recursive {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		for _, f := range files {
+			if !f.IsDir() && filter.Match(f.Name()) {
+				out = append(out, filepath.Join(dir, f.Name()))
+			}
+		}
+		return out, nil
+	}
+
+	var out []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if p != dir && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if filter.Match(filepath.ToSlash(rel)) {
+			out = append(out, p)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// sourceFragment is the content of a BOM fragment that is not read from a
+// file of its own, e.g. from an image or an archive. Source names it in
+// errors and traces.
+type sourceFragment struct {
+	Source string
+	Data   []byte
+}
+
+// parseBOM decodes a BOM fragment in either the envelope or the legacy
+// two-array format, or converts a CycloneDX or SPDX document. Files named
+// .yaml or .yml are read as YAML.
+func parseBOM(filename string, data []byte) (*merge.Document, error) {
+	return parseBOMAs(filename, data, isYAMLFile(filename))
+}
+
+// parseBOMAs is parseBOM with the choice of reading data as YAML.
+func parseBOMAs(filename string, data []byte, yaml bool) (*merge.Document, error) {
+	data, err := merge.ToUTF8(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if yaml {
+		if data, err = yamlToJSON(filename, data); err != nil {
+			return nil, err
+		}
+	}
+	if isSPDXTagValue(data) {
+		return parseSPDXTagValue(filename, data)
+	}
+	if doc, ok, err := parseSBOM(filename, data); ok {
+		return doc, err
+	}
+	return merge.ParseDocument(filename, data)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+)
+
+// stripJSONC converts a hand-edited JSONC document into strict JSON by
+// removing // and /* */ comments and trailing commas before ] and }.
+// Content of string literals is left untouched.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			// copy the string literal verbatim, honoring escapes
+			j := i + 1
+			for ; j < len(data); j++ {
+				if data[j] == '\\' {
+					j++
+					continue
+				}
+				if data[j] == '"' {
+					break
+				}
+			}
+			if j >= len(data) {
+				j = len(data) - 1
+			}
+			out = append(out, data[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				if data[i] == '\n' {
+					out = append(out, '\n')
+				}
+				i++
+			}
+			i++
+		case c == ']' || c == '}':
+			// drop a trailing comma, keeping any whitespace after it
+			for k := len(out) - 1; k >= 0; k-- {
+				if isJSONSpace(out[k]) {
+					continue
+				}
+				if out[k] == ',' {
+					out = append(out[:k], out[k+1:]...)
+				}
+				break
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// jsoncArrayElements returns the byte ranges of the elements of the
+// top-level array of a JSONC document, excluding surrounding whitespace,
+// comments and commas, so elements can be edited in place.
+func jsoncArrayElements(data []byte) ([][2]int, error) {
+	var elems [][2]int
+	depth, start, end := 0, -1, 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case isJSONSpace(c):
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+			continue
+		}
+
+		if depth == 0 {
+			if c != '[' {
+				return nil, errors.New("document is not an array")
+			}
+			depth++
+			continue
+		}
+		if depth == 1 && (c == ',' || c == ']') {
+			if start >= 0 {
+				elems = append(elems, [2]int{start, end})
+				start = -1
+			}
+			if c == ']' {
+				return elems, nil
+			}
+			continue
+		}
+		if depth == 1 && start < 0 {
+			start = i
+		}
+		switch c {
+		case '"':
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+		end = i + 1
+	}
+	return nil, errors.New("unterminated array")
+}
@@ -0,0 +1,73 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain JSON", `{"a": [1, 2]}`, `{"a": [1, 2]}`},
+		{"line comment", "{\n  // comment\n  \"a\": 1 // trailing\n}", "{\n  \n  \"a\": 1 \n}"},
+		{"line comment at end", "[1] // end", "[1] "},
+		{"block comment", "[1, /* two\nlines */ 2]", "[1, \n 2]"},
+		{"line comment in string", `{"url": "https://example.com"}`, `{"url": "https://example.com"}`},
+		{"block comment in string", `{"a": "/* not a comment */"}`, `{"a": "/* not a comment */"}`},
+		{"escaped quote in string", `{"a": "say \"// hi\""} // c`, `{"a": "say \"// hi\""} `},
+		{"escaped backslash before quote", `{"a": "dir\\"} // c`, `{"a": "dir\\"} `},
+		{"trailing comma before }", `{"a": 1,}`, `{"a": 1}`},
+		{"trailing comma before ]", `[1, 2, ]`, `[1, 2 ]`},
+		{"trailing comma before newline", "[\n  1,\n]", "[\n  1\n]"},
+		{"trailing comma before comment", "[\n  1, // last\n]", "[\n  1 \n]"},
+		{"nested trailing commas", `{"a": [1,], "b": {"c": 2,},}`, `{"a": [1], "b": {"c": 2}}`},
+		{"comma and bracket in string", `["a,]", "b,}",]`, `["a,]", "b,}"]`},
+		{"empty", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := string(stripJSONC([]byte(c.in))); got != c.want {
+				t.Errorf("stripJSONC(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestStripJSONCIsValidJSON(t *testing.T) {
+	in := `// overrides
+[
+  {
+    "project": "github.com/foo/bar", // vendored
+    "licenses": [{"type": "MIT", "confidence": 1,},],
+    /* "vcs": "github.com/foo/bar", */
+    "note": "see https://example.com/*path*/ \"quoted\"",
+  },
+]
+`
+	var v []map[string]interface{}
+	if err := json.Unmarshal(stripJSONC([]byte(in)), &v); err != nil {
+		t.Fatalf("stripJSONC output is not valid JSON: %v\n%s", err, stripJSONC([]byte(in)))
+	}
+	if len(v) != 1 || v[0]["note"] != `see https://example.com/*path*/ "quoted"` || v[0]["vcs"] != nil {
+		t.Errorf("stripJSONC changed the content: %v", v)
+	}
+}
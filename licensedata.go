@@ -0,0 +1,67 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// loadLicenseData reads the licenses.json and exceptions.json files of the
+// SPDX license-list-data repository from dir, so identifiers of a newer
+// license list are normalized without a new release.
+func loadLicenseData(dir string) error {
+	found := false
+	for _, name := range []string{"licenses.json", "exceptions.json"} {
+		filename := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := merge.LoadLicenseList(data); err != nil {
+			return fmt.Errorf("failed to parse license data %s: %v", filename, err)
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no licenses.json or exceptions.json found in license data directory %s", dir)
+	}
+	return nil
+}
+
+// loadLicenseAliases reads a JSON object mapping license names found in
+// inputs to SPDX identifiers, e.g. {"Apache Software License": "Apache-2.0"}.
+func loadLicenseAliases(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	aliases := map[string]string{}
+	if err := json.Unmarshal(stripJSONC(data), &aliases); err != nil {
+		return fmt.Errorf("failed to parse license aliases %s: %v", filename, err)
+	}
+	merge.AddLicenseAliases(aliases)
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// spdxTextClient fetches the canonical texts of licenses from the SPDX
+// license list and keeps them for the lifetime of the process.
+type spdxTextClient struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	lookups map[string]*spdxTextLookup
+}
+
+type spdxTextLookup struct {
+	done chan struct{}
+	text string
+	err  error
+}
+
+func newSPDXTextClient() *spdxTextClient {
+	return &spdxTextClient{
+		baseURL: "https://spdx.org/licenses",
+		client:  &http.Client{Timeout: 30 * time.Second},
+		lookups: map[string]*spdxTextLookup{},
+	}
+}
+
+// Text returns the canonical text of the listed license id.
+func (c *spdxTextClient) Text(id string) (string, error) {
+	c.mu.Lock()
+	l, ok := c.lookups[id]
+	if !ok {
+		l = &spdxTextLookup{done: make(chan struct{})}
+		c.lookups[id] = l
+		c.mu.Unlock()
+
+		l.text, l.err = c.fetch(id)
+		close(l.done)
+	} else {
+		c.mu.Unlock()
+		<-l.done
+	}
+	return l.text, l.err
+}
+
+func (c *spdxTextClient) fetch(id string) (string, error) {
+	url := c.baseURL + "/" + id + ".json"
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	var license struct {
+		LicenseText string `json:"licenseText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&license); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %v", url, err)
+	}
+	return license.LicenseText, nil
+}
+
+// licenseTrigrams returns the word trigrams of a license text, ignoring
+// case, punctuation and copyright lines, which differ between projects
+// using the same license.
+func licenseTrigrams(text string) []string {
+	var words []string
+	for _, line := range strings.Split(strings.ToLower(text), "\n") {
+		if strings.Contains(line, "copyright") && !strings.Contains(line, "copyright holder") {
+			continue
+		}
+		words = append(words, strings.FieldsFunc(line, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	var out []string
+	for i := 0; i+2 < len(words); i++ {
+		out = append(out, words[i]+" "+words[i+1]+" "+words[i+2])
+	}
+	return out
+}
+
+// licenseDeviation returns the share of the word trigrams of text that do
+// not occur in the canonical text, e.g. because clauses were added.
+func licenseDeviation(text, canonical string) float64 {
+	known := map[string]bool{}
+	for _, t := range licenseTrigrams(canonical) {
+		known[t] = true
+	}
+	trigrams := licenseTrigrams(text)
+	if len(trigrams) == 0 {
+		return 0
+	}
+	added := 0
+	for _, t := range trigrams {
+		if !known[t] {
+			added++
+		}
+	}
+	return math.Round(float64(added)/float64(len(trigrams))*100) / 100
+}
+
+// detectModifiedLicenses compares the license text of every entry with the
+// canonical texts of its listed licenses and flags entries deviating from
+// all of them by more than the threshold.
+func (m *merger) detectModifiedLicenses(reg *merge.Registry) error {
+	threshold := m.opts.ModifiedLicenseThreshold
+	if threshold <= 0 {
+		threshold = 0.1
+	}
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment || p.LicenseText == "" {
+			return nil
+		}
+		deviation, compared := 1.0, ""
+		for _, lic := range p.Licenses {
+			if !merge.IsListed(lic.Type) {
+				continue
+			}
+			canonical, err := m.res.spdx.Text(merge.CanonicalID(lic.Type))
+			if err != nil {
+				return m.skip(networkError(err))
+			}
+			if d := licenseDeviation(p.LicenseText, canonical); d < deviation {
+				deviation, compared = d, lic.Type
+			}
+		}
+		if compared == "" {
+			return nil
+		}
+		p.LicenseDeviation = deviation
+		p.LicenseModified = deviation > threshold
+		if p.LicenseModified {
+			warnf("license text of %s deviates from %s by %.0f%%", p.Project, compared, deviation*100)
+			m.modifiedLicenses = append(m.modifiedLicenses, p.Project)
+		}
+		m.tracef(p.Project, "license text deviates from %s by %.0f%%", compared, deviation*100)
+		reg.Set(p)
+		return nil
+	})
+}
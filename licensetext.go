@@ -0,0 +1,271 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+const (
+	licenseTextsInline = "inline"
+	licenseTextsDir    = "dir"
+
+	// licenseTextsSubdir is the directory of the output directory the
+	// license files are written to with --license-texts=dir.
+	licenseTextsSubdir = "licenses"
+)
+
+func validateLicenseTexts(mode string) error {
+	switch mode {
+	case "", licenseTextsInline, licenseTextsDir:
+		return nil
+	}
+	return fmt.Errorf("invalid license texts mode %q, must be inline or dir", mode)
+}
+
+// licenseFile is the license file of a repository at one ref.
+type licenseFile struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// licenseTextFetcher downloads the license files of GitHub repositories and
+// keeps them for the lifetime of the process. Files of pinned refs are also
+// kept in dir across runs, if set.
+type licenseTextFetcher struct {
+	github *githubClient
+	dir    string
+
+	mu      sync.Mutex
+	lookups map[string]*licenseTextLookup
+}
+
+type licenseTextLookup struct {
+	done chan struct{}
+	file *licenseFile
+	err  error
+}
+
+func newLicenseTextFetcher(github *githubClient, dir string) *licenseTextFetcher {
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	return &licenseTextFetcher{
+		github:  github,
+		dir:     dir,
+		lookups: map[string]*licenseTextLookup{},
+	}
+}
+
+// File returns the license file of the repository owner/repo at ref, or
+// of its default branch if ref is empty or unknown to GitHub. It returns
+// nil if the repository has no license file.
+func (f *licenseTextFetcher) File(ownerRepo, ref string) (*licenseFile, error) {
+	key := ownerRepo + "@" + ref
+	f.mu.Lock()
+	l, ok := f.lookups[key]
+	if !ok {
+		l = &licenseTextLookup{done: make(chan struct{})}
+		f.lookups[key] = l
+		f.mu.Unlock()
+
+		l.file, l.err = f.lookup(ownerRepo, ref)
+		close(l.done)
+	} else {
+		f.mu.Unlock()
+		<-l.done
+	}
+	return l.file, l.err
+}
+
+func (f *licenseTextFetcher) lookup(ownerRepo, ref string) (*licenseFile, error) {
+	cached := ""
+	if f.dir != "" && ref != "" {
+		cached = filepath.Join(f.dir, ownerRepo, url.PathEscape(ref)+".json")
+		if data, err := ioutil.ReadFile(cached); err == nil {
+			var file licenseFile
+			if err := json.Unmarshal(data, &file); err == nil {
+				return &file, nil
+			}
+		}
+	}
+
+	file, err := f.fetch(ownerRepo, ref)
+	if errors.Is(err, errGitHubNotFound) && ref != "" {
+		// the version is not tagged in the repository, e.g. for modules in
+		// a subdirectory, so fall back to the default branch uncached
+		cached = ""
+		file, err = f.fetch(ownerRepo, "")
+	}
+	if errors.Is(err, errGitHubNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if cached != "" {
+		data, err := json.Marshal(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(cached, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (f *licenseTextFetcher) fetch(ownerRepo, ref string) (*licenseFile, error) {
+	path := "/repos/" + ownerRepo + "/license"
+	if ref != "" {
+		path += "?ref=" + url.QueryEscape(ref)
+	}
+	var resp struct {
+		Name     string `json:"name"`
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := f.github.get(path, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Encoding != "base64" {
+		return nil, fmt.Errorf("license file of %s has unsupported encoding %q", ownerRepo, resp.Encoding)
+	}
+	// GitHub wraps the encoded content at 60 characters
+	text, err := base64.StdEncoding.DecodeString(strings.Replace(resp.Content, "\n", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode license file of %s: %v", ownerRepo, err)
+	}
+	return &licenseFile{Name: resp.Name, Text: string(text)}, nil
+}
+
+// licenseRef returns the git ref of the version of p: the commit of a
+// pseudo-version or the tag of a release.
+func licenseRef(p merge.Project) string {
+	if p.PseudoVersion {
+		return p.Revision
+	}
+	return strings.TrimSuffix(p.Version, "+incompatible")
+}
+
+// licenseURL returns the page of the first license of p on the SPDX
+// license list, or the license file of its GitHub repository at its version
+// if the license is not listed. For other repositories it falls back to the
+// repository itself.
+func licenseURL(p merge.Project) string {
+	if len(p.Licenses) == 0 {
+		return ""
+	}
+	if merge.IsListed(p.Licenses[0].Type) {
+		return spdxURL(p.Licenses[0].Type)
+	}
+	ownerRepo, ok := githubRepoPath(p.VCS)
+	if !ok {
+		if p.VCS == "" {
+			return ""
+		}
+		return vcsURL(p.VCS)
+	}
+	ref := licenseRef(p)
+	if subdir := strings.TrimPrefix(p.Project, p.VCS+"/"); !p.PseudoVersion && ref != "" && subdir != p.Project {
+		// modules in a subdirectory are tagged with it as prefix
+		ref = subdir + "/" + ref
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	file := "LICENSE"
+	if p.LicenseFile != "" {
+		file = path.Base(p.LicenseFile)
+	}
+	return "https://github.com/" + ownerRepo + "/blob/" + ref + "/" + file
+}
+
+// addLicenseURLs sets the license URL of every entry of reg.
+func addLicenseURLs(reg *merge.Registry) {
+	_ = reg.Each(func(p merge.Project) error {
+		p.LicenseURL = licenseURL(p)
+		reg.Set(p)
+		return nil
+	})
+}
+
+// addLicenseTexts attaches the license file of the GitHub repository of
+// every project to its entry.
+func (m *merger) addLicenseTexts(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		ownerRepo, ok := githubRepoPath(p.VCS)
+		if !ok {
+			m.tracef(p.Project, "no license text: VCS root %q is not on GitHub", p.VCS)
+			return nil
+		}
+		file, err := m.res.texts.File(ownerRepo, licenseRef(p))
+		if err != nil {
+			return m.skip(networkError(err))
+		}
+		if file == nil {
+			warnf("%s has no license file in %s", p.Project, ownerRepo)
+			return nil
+		}
+		m.tracef(p.Project, "license text from %s of %s", file.Name, ownerRepo)
+		p.LicenseText = file.Text
+		p.LicenseFile = file.Name
+		reg.Set(p)
+		return nil
+	})
+}
+
+// writeLicenseTexts moves the license texts of the entries of reg to
+// licenses/<project>/<file name> in dir, leaving the path in the entries.
+func writeLicenseTexts(dir string, reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.LicenseText == "" {
+			return nil
+		}
+		name := filepath.Join(licenseTextsSubdir, filepath.FromSlash(p.Project), filepath.Base(p.LicenseFile))
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(p.LicenseText), 0644); err != nil {
+			return err
+		}
+		p.LicenseFile = filepath.ToSlash(name)
+		p.LicenseText = ""
+		reg.Set(p)
+		return nil
+	})
+}
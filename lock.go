@@ -0,0 +1,154 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// lockFile pins the resolution results of a merge, similar to go.sum for
+// modules. A later run with --locked reuses these decisions and refuses
+// inputs that are not covered by the lock. Entries are keyed by
+// module@version, so the versions kept by --version-conflict=keep-all are
+// pinned separately.
+type lockFile struct {
+	Version  int                      `json:"version"`
+	Projects map[string]lockedProject `json:"projects"`
+}
+
+type lockedProject struct {
+	VCS               string          `json:"vcs,omitempty"`
+	Licenses          []merge.License `json:"licenses,omitempty"`
+	LicenseExpression string          `json:"licenseExpression,omitempty"`
+	// Evidence is the hash of the input entry the decision was based on.
+	Evidence string `json:"evidence"`
+}
+
+// lockVersion 1 keyed entries by module path only. Such files are still
+// read, but a later write upgrades them.
+const lockVersion = 2
+
+func lockKey(p merge.Project) string {
+	return merge.KeyByPathVersion(p)
+}
+
+// lookup returns the locked decision of p.
+func (l *lockFile) lookup(p merge.Project) (lockedProject, bool) {
+	if l.Version == 1 {
+		locked, ok := l.Projects[p.Project]
+		return locked, ok
+	}
+	locked, ok := l.Projects[lockKey(p)]
+	return locked, ok
+}
+
+// evidenceHashes returns the hash of every input entry as loaded, before
+// cleanup and overrides are applied.
+func evidenceHashes(reg *merge.Registry) (map[string]string, error) {
+	hashes := make(map[string]string, reg.Len())
+	err := reg.Each(func(p merge.Project) error {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hashes[lockKey(p)] = "sha256:" + hex.EncodeToString(sum[:])
+		return nil
+	})
+	return hashes, err
+}
+
+func writeLockFile(filename string, reg *merge.Registry, evidence map[string]string) error {
+	lock := lockFile{
+		Version:  lockVersion,
+		Projects: make(map[string]lockedProject, reg.Len()),
+	}
+	_ = reg.Each(func(p merge.Project) error {
+		lock.Projects[lockKey(p)] = lockedProject{
+			VCS:               p.VCS,
+			Licenses:          p.Licenses,
+			LicenseExpression: p.LicenseExpression,
+			Evidence:          evidence[lockKey(p)],
+		}
+		return nil
+	})
+	data, err := MarshalJson(lock)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func readLockFile(filename string) (*lockFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %v", filename, err)
+	}
+	if lock.Version != 1 && lock.Version != lockVersion {
+		return nil, fmt.Errorf("lock file %s has unsupported version %d", filename, lock.Version)
+	}
+	return &lock, nil
+}
+
+// applyLock replaces the VCS root and licenses of every project with the
+// locked decision. It fails if a project is missing from the lock or its
+// input entry changed since the lock was written.
+func applyLock(reg *merge.Registry, lock *lockFile, evidence map[string]string) error {
+	var missing, changed []string
+	_ = reg.Each(func(p merge.Project) error {
+		locked, ok := lock.lookup(p)
+		if !ok {
+			missing = append(missing, lockKey(p))
+			return nil
+		}
+		if locked.Evidence != evidence[lockKey(p)] {
+			changed = append(changed, lockKey(p))
+			return nil
+		}
+		p.VCS = locked.VCS
+		p.Licenses = locked.Licenses
+		p.LicenseExpression = locked.LicenseExpression
+		reg.Set(p)
+		return nil
+	})
+
+	var msgs []string
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		msgs = append(msgs, fmt.Sprintf("projects not in lock: %s", strings.Join(missing, ", ")))
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		msgs = append(msgs, fmt.Sprintf("projects whose input changed since lock: %s", strings.Join(changed, ", ")))
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("inputs do not match lock file, run bom-merger lock to update it; %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
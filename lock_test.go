@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+func TestLockKeepAllVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "bom.lock.json")
+
+	reg := merge.NewRegistryKeyedBy(merge.KeyByPathVersion)
+	reg.Set(merge.Project{Project: "example.com/x", Version: "v1.0.0", LicenseExpression: "MIT"})
+	reg.Set(merge.Project{Project: "example.com/x", Version: "v2.0.0", LicenseExpression: "Apache-2.0"})
+	evidence, err := evidenceHashes(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeLockFile(filename, reg, evidence); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := readLockFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Projects) != 2 {
+		t.Fatalf("lock has %d entries, want 2: %v", len(lock.Projects), lock.Projects)
+	}
+
+	// the decisions of both versions are restored
+	locked := merge.NewRegistryKeyedBy(merge.KeyByPathVersion)
+	_ = reg.Each(func(p merge.Project) error {
+		p.LicenseExpression = ""
+		locked.Set(p)
+		return nil
+	})
+	if err := applyLock(locked, lock, evidence); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"example.com/x@v1.0.0": "MIT", "example.com/x@v2.0.0": "Apache-2.0"} {
+		if p, _ := locked.Get(key); p.LicenseExpression != want {
+			t.Errorf("%s: license = %q, want %q", key, p.LicenseExpression, want)
+		}
+	}
+
+	// a version not in the lock is reported
+	locked.Set(merge.Project{Project: "example.com/x", Version: "v3.0.0"})
+	evidence, _ = evidenceHashes(locked)
+	if err := applyLock(locked, lock, evidence); err == nil {
+		t.Error("applyLock accepted a version missing from the lock")
+	}
+}
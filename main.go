@@ -19,108 +19,698 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
 
 	flag "github.com/spf13/pflag"
-	"gomodules.xyz/mod"
 )
 
+// options configure a single merge. Profiles in a manifest use the same
+// fields, so the JSON names match the flag names.
+type options struct {
+	In            fileList `json:"in,omitempty"`
+	Images        []string `json:"images,omitempty"`
+	Out           string   `json:"out,omitempty"`
+	OverrideFiles fileList `json:"overrideFile,omitempty"`
+	LabelsFile    string   `json:"labelsFile,omitempty"`
+	WaiversFile   string   `json:"waiversFile,omitempty"`
+	PolicyFile    string   `json:"policyFile,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Recursive     bool     `json:"recursive,omitempty"`
+	InputFormat   string   `json:"inputFormat,omitempty"`
+	FilterModules []string `json:"filterModules,omitempty"`
+	ToolsFrom     []string `json:"toolsFrom,omitempty"`
+	BinariesFrom  []string `json:"binariesFrom,omitempty"`
+	ReplacesFrom  []string `json:"replacesFrom,omitempty"`
+	FilterScopes  []string `json:"filterScopes,omitempty"`
+	Locked        bool     `json:"locked,omitempty"`
+	LockFile      string   `json:"lockFile,omitempty"`
+	HistoryDir    string   `json:"historyDir,omitempty"`
+	HistoryLabel  string   `json:"historyLabel,omitempty"`
+	SortBy        string   `json:"sortBy,omitempty"`
+	KeyBy         string   `json:"keyBy,omitempty"`
+	SplitBy       string   `json:"splitBy,omitempty"`
+	PerSourceOut  string   `json:"perSourceOut,omitempty"`
+	AuditLog      string   `json:"auditLog,omitempty"`
+	Template      string   `json:"template,omitempty"`
+	Format        string   `json:"format,omitempty"`
+	ExportFilter  string   `json:"exportFilter,omitempty"`
+	Compact       bool     `json:"compact,omitempty"`
+
+	Notice             string `json:"notice,omitempty"`
+	NoticeLicenseTexts string `json:"noticeLicenseTexts,omitempty"`
+
+	FilterModulesRegex []string `json:"filterModulesRegex,omitempty"`
+	OnlyModules        []string `json:"onlyModules,omitempty"`
+
+	MaxComponents   int    `json:"maxComponents,omitempty"`
+	MaxOutputSize   string `json:"maxOutputSize,omitempty"`
+	GuardrailAction string `json:"guardrailAction,omitempty"`
+
+	VersionConflict string `json:"versionConflict,omitempty"`
+
+	KeepMultiLicenses      bool    `json:"keepMultiLicenses,omitempty"`
+	MultiLicenseConfidence float64 `json:"multiLicenseConfidence,omitempty"`
+
+	RequireConfidence bool `json:"requireConfidence,omitempty"`
+	WriteFiltered     bool `json:"writeFiltered,omitempty"`
+	WriteReport       bool `json:"writeReport,omitempty"`
+	HTMLReport        bool `json:"htmlReport,omitempty"`
+	ValidateOutput    bool `json:"validateOutput,omitempty"`
+	StrictOverrides   bool `json:"strictOverrides,omitempty"`
+	ContinueOnError   bool `json:"continueOnError,omitempty"`
+
+	DetectInactive bool `json:"detectInactive,omitempty"`
+	InactiveYears  int  `json:"inactiveYears,omitempty"`
+	FailOnInactive bool `json:"failOnInactive,omitempty"`
+
+	MinConfidence        float64 `json:"minConfidence,omitempty"`
+	MinLicenseCoverage   float64 `json:"minLicenseCoverage,omitempty"`
+	MinLicenseConfidence float64 `json:"minLicenseConfidence,omitempty"`
+
+	AllowVCSRedirects []string `json:"allowVCSRedirects,omitempty"`
+	FailOnVCSRedirect bool     `json:"failOnVCSRedirect,omitempty"`
+
+	VerifyChecksums    bool    `json:"verifyChecksums,omitempty"`
+	CheckRetractions   bool    `json:"checkRetractions,omitempty"`
+	FailOnRetracted    bool    `json:"failOnRetracted,omitempty"`
+	DeclaredLicenses   bool    `json:"declaredLicenses,omitempty"`
+	DepsDevInsights    bool    `json:"depsDevInsights,omitempty"`
+	VerifyRepoLicenses bool    `json:"verifyRepoLicenses,omitempty"`
+	LicenseTexts       string  `json:"licenseTexts,omitempty"`
+	LicenseURLs        bool    `json:"licenseURLs,omitempty"`
+	MinScorecard       float64 `json:"minScorecard,omitempty"`
+
+	DetectModifiedLicenses   bool    `json:"detectModifiedLicenses,omitempty"`
+	ModifiedLicenseThreshold float64 `json:"modifiedLicenseThreshold,omitempty"`
+
+	// writeLock is set by the lock command to write bom.lock.json
+	writeLock bool
+}
+
 var (
-	dirIn         string
-	dirOut        string
-	overrideFile  string
-	filterModules []string
+	opts           options
+	manifestFile   string
+	vcsCacheURL    string
+	licenseTextDir string
+	refreshVCS     bool
+	vcsWorkers     int
+	loadWorkers    int
+	exportWorkers  int
+	vcsTimeout     time.Duration
+	traceEndpoint  string
+	licenseDataDir string
+	licenseAliases string
 )
 
 func init() {
-	flag.StringVar(&dirIn, "in", "", "Path to directory where BOM json files are stored")
-	flag.StringVar(&dirOut, "out", "", "Path to directory where output files are stored")
-	flag.StringVar(&overrideFile, "override-file", "", "Path to override file")
-	flag.StringSliceVar(&filterModules, "filter-modules", nil, "Filter go modules with prefix")
+	flag.Var(&opts.In, "in", "Path to directory where BOM json files are stored, or to a BOM file, or to a .tar.gz, .tgz, .tar or .zip archive of them, or - to read them from stdin, or a comma separated list of https://, s3:// or gs:// URLs of fragments and archives. Repeatable, inputs are merged in the order given")
+	flag.Var(&opts.In, "dir", "Deprecated alias of --in")
+	flag.StringSliceVar(&opts.Include, "include", nil, "Only load the input files matching these glob patterns, matched against the file name or, if the pattern has a slash, the path relative to --in (e.g. '*.bom.json')")
+	flag.StringSliceVar(&opts.Exclude, "exclude", nil, "Skip the input files matching these glob patterns, matched like --include")
+	flag.BoolVar(&opts.Recursive, "recursive", false, "Also load the files in subdirectories of --in, except hidden ones")
+	flag.StringSliceVar(&opts.Images, "image", nil, "Container images to read BOM fragments from, stored in labels, manifest annotations or referrers")
+	flag.StringVar(&opts.Out, "out", "", "Path to directory where output files are stored, or - to write the BOM to stdout")
+	flag.Var(&opts.OverrideFiles, "override-file", "Path to override file, a directory of them, or - to read it from stdin (comments and trailing commas are allowed); may be repeated, later files win")
+	flag.StringVar(&opts.LabelsFile, "labels-file", "", "Path to a file mapping projects to key/value labels (comments and trailing commas are allowed)")
+	flag.StringVar(&opts.WaiversFile, "waivers-file", "", "Path to a file of approved exceptions exempting projects from the policy checks without changing their detected license, as YAML, or as JSON with comments if named .json")
+	flag.StringVar(&opts.PolicyFile, "policy-file", "", "Path to a file of allowed and denied licenses; the merge fails after writing the outputs and bom_policy.json if a merged project violates it (comments and trailing commas are allowed)")
+	flag.StringSliceVar(&opts.FilterModules, "filter-modules", nil, "Filter go modules with prefix")
+	flag.StringArrayVar(&opts.FilterModulesRegex, "filter-modules-regex", nil, "Filter go modules whose path matches this regular expression; may be repeated")
+	flag.StringArrayVar(&opts.OnlyModules, "only-modules", nil, "Keep go modules whose path matches this regular expression even if --filter-modules or --filter-modules-regex match them, or without those filter every other module; may be repeated")
+	flag.StringSliceVar(&opts.ToolsFrom, "tools-from", nil, "Mark projects providing the tool directives of these go.mod files or the imports of these tools.go files with scope tool")
+	flag.StringSliceVar(&opts.BinariesFrom, "binaries-from", nil, "Record in every entry the main packages of the Go modules in these directories that depend on it, found with go list -deps")
+	flag.StringSliceVar(&opts.ReplacesFrom, "replaces-from", nil, "Flag projects that the replace directives of these go.mod files replace by a fork, and take their VCS root from the fork")
+	flag.StringSliceVar(&opts.FilterScopes, "filter-scopes", nil, "Filter projects with these scopes, e.g. tool")
+	flag.BoolVar(&opts.Locked, "locked", false, "Reuse VCS roots and licenses from the lock file and fail on projects not covered by it")
+	flag.StringVar(&opts.LockFile, "lock-file", "", "Path to lock file (defaults to bom.lock.json in the output directory)")
+	flag.StringVar(&opts.HistoryDir, "history-dir", "", "If set, record the merged BOM in this directory for later history searches")
+	flag.StringVar(&opts.HistoryLabel, "history-label", "", "Label of the recorded BOM, usually the release version (defaults to a timestamp)")
+	flag.StringVar(&opts.SortBy, "sort-by", "", "Order of entries in bom.json and the reports, one of project or risk; by default bom.json is ordered by project and the HTML report and --template document by risk")
+	flag.StringVar(&opts.KeyBy, "key-by", "module", "Key used to deduplicate entries in the outputs, one of module, vcs, purl or path+version")
+	flag.StringVar(&opts.VersionConflict, "version-conflict", "", "What to do when fragments supply a module in different versions, keep the highest version, keep-all versions as separate entries or error; by default the later fragment wins")
+	flag.StringVar(&opts.SplitBy, "split-by", "", "Split bom.json into multiple files listed in bom.index.json, by license-category or size=<limit> (e.g. size=10MB)")
+	flag.StringVar(&opts.PerSourceOut, "per-source-out", "", "If set, also write every input fragment with its merged entries, overrides and enrichment applied, to this directory")
+	flag.StringVar(&opts.AuditLog, "audit-log", "", "If set, append every override, conflict resolution and policy decision of the merge to this JSON lines file")
+	flag.StringVar(&opts.Notice, "notice", "", "Also write a plain text attribution file with this name, e.g. THIRD_PARTY_LICENSES, to the output directory")
+	flag.StringVar(&opts.NoticeLicenseTexts, "notice-license-texts", "", "Directory with the full license texts as <SPDX id>.txt, e.g. the text directory of the SPDX license-list-data, to append to the --notice file")
+	flag.StringVar(&opts.Template, "template", "", "Also render this Go template file to the output directory, named like the template without its .tmpl extension")
+	flag.StringVar(&opts.ExportFilter, "export-filter", "", "Only include matching entries in the --template document, e.g. 'category in (copyleft, unknown)'")
+	flag.StringVar(&opts.Format, "format", formatNative, "Format of bom.json, native, cyclonedx (CycloneDX 1.5 JSON), spdx (SPDX 2.3 JSON), spdx-tv (SPDX 2.3 tag-value, written to bom.spdx), yaml (native schema as YAML, written to bom.yaml), csv (written to bom.csv) or markdown (a table written to bom.md)")
+	addSPDXIDFlags(flag.CommandLine)
+	flag.StringVar(&opts.Format, "output-format", formatNative, "Alias of --format")
+	flag.StringVar(&opts.InputFormat, "input-format", inputFormatAuto, "Format of the input files, json, yaml, or auto to read .yaml and .yml files as YAML and all others as JSON")
+	flag.BoolVar(&opts.Compact, "compact", false, "Write the BOM outputs as minified JSON instead of indented JSON")
+	flag.IntVar(&opts.MaxComponents, "max-components", 0, "Guardrail on the number of entries in bom.json, 0 for no limit")
+	flag.StringVar(&opts.MaxOutputSize, "max-output-size", "", "Guardrail on the size of bom.json (e.g. 200MB)")
+	flag.StringVar(&opts.GuardrailAction, "guardrail-action", "fail", "What to do when a guardrail is exceeded, fail before writing the outputs or warn")
+	flag.BoolVar(&opts.KeepMultiLicenses, "keep-multi-licenses", false, "Keep every license detected with at least --multi-license-confidence instead of only the best one, as a choice between them (e.g. MIT OR Apache-2.0)")
+	flag.Float64Var(&opts.MultiLicenseConfidence, "multi-license-confidence", 0.9, "Detection confidence a license needs to be kept by --keep-multi-licenses")
+	flag.BoolVar(&opts.RequireConfidence, "require-confidence", false, "Move projects whose licenses have no detection confidence to bom_review.json")
+	flag.Float64Var(&opts.MinConfidence, "min-confidence", 0, "Move projects whose best license is detected with less confidence than this, e.g. 0.9, to bom_review.json for manual review")
+	flag.BoolVar(&opts.WriteFiltered, "write-filtered", false, "Record projects removed by --filter-modules, --filter-modules-regex, --only-modules or --filter-scopes in bom_filtered.json with the matching rule")
+	flag.BoolVar(&opts.WriteReport, "write-report", false, "Write a summary of the run, including VCS resolution statistics per host, to bom_report.json")
+	flag.BoolVar(&opts.StrictOverrides, "strict-overrides", false, "Fail the merge after writing the outputs if an override matched no project in the BOM, instead of only warning about it")
+	flag.BoolVar(&opts.ValidateOutput, "validate-output", false, "Check bom.json in the native or yaml format and the other entry outputs, such as bom_error.json, against the schema printed by bom-merger schema before writing any output")
+	flag.BoolVar(&opts.HTMLReport, "html-report", false, "Write a self-contained HTML page with the number of projects per license and a filterable table of the merged BOM to bom_report.html, e.g. to attach to a release")
+	flag.BoolVar(&opts.ContinueOnError, "continue-on-error", false, "Skip unreadable inputs and failed lookups with a warning, write the outputs and exit with the code of the skipped errors at the end")
+	flag.BoolVar(&opts.DetectInactive, "detect-inactive", false, "Mark GitHub hosted projects whose repository is archived or has no recent commits as inactive")
+	flag.IntVar(&opts.InactiveYears, "inactive-years", 2, "Years without commits after which a repository is considered inactive")
+	flag.BoolVar(&opts.FailOnInactive, "fail-on-inactive", false, "Fail the merge after writing the outputs if any project is inactive")
+	flag.Float64Var(&opts.MinLicenseCoverage, "min-license-coverage", 0, "Fail the merge after writing the outputs if less than this percentage of entries has a license with at least --min-license-confidence")
+	flag.Float64Var(&opts.MinLicenseConfidence, "min-license-confidence", 0, "Detection confidence a license needs to count towards --min-license-coverage")
+	flag.StringSliceVar(&opts.AllowVCSRedirects, "allow-vcs-redirects", nil, "Module hosts allowed to have their VCS root on another host, as module-host=vcs-host (e.g. k8s.io=github.com)")
+	flag.BoolVar(&opts.FailOnVCSRedirect, "fail-on-vcs-redirect", false, "Fail the merge after writing the outputs if the VCS root of any project is on another host than its module path")
+	flag.BoolVar(&opts.DeclaredLicenses, "declared-licenses", false, "Add the license declared on deps.dev for each module version and flag entries whose detected license differs")
+	flag.BoolVar(&opts.VerifyRepoLicenses, "verify-repo-licenses", false, "Warn about GitHub hosted projects whose detected license differs from the license GitHub shows for the repository")
+	flag.BoolVar(&opts.DetectModifiedLicenses, "detect-modified-licenses", false, "Compare the license texts downloaded with --license-texts with the canonical texts of the SPDX license list and flag entries that deviate")
+	flag.Float64Var(&opts.ModifiedLicenseThreshold, "modified-license-threshold", 0.1, "Share of a license text not found in the canonical text above which --detect-modified-licenses flags the entry")
+	flag.BoolVar(&opts.LicenseURLs, "license-urls", false, "Add the URL of the license text to each entry, on the SPDX license list or, for other licenses, in the repository of the project")
+	flag.StringVar(&opts.LicenseTexts, "license-texts", "", "Download the license file of GitHub hosted projects at their version and embed it in the entries (inline) or write it to the licenses directory of the output (dir)")
+	flag.BoolVar(&opts.DepsDevInsights, "depsdev-insights", false, "Add the OpenSSF scorecard score and the dependent count from deps.dev to each module version")
+	flag.Float64Var(&opts.MinScorecard, "min-scorecard", 0, "Fail the merge after writing the outputs if an entry has a scorecard score below this; implies --depsdev-insights")
+	flag.BoolVar(&opts.VerifyChecksums, "verify-checksums", false, "Download every module version from GOPROXY and verify it against the checksum database (GOSUMDB)")
+	flag.BoolVar(&opts.CheckRetractions, "check-retractions", false, "Flag projects pinned to a version retracted by the retract directives of the module, looked up on GOPROXY")
+	flag.BoolVar(&opts.FailOnRetracted, "fail-on-retracted", false, "Fail the merge after writing the outputs if any project is pinned to a retracted version; implies --check-retractions")
+	flag.StringVar(&manifestFile, "manifest", "", "Path to a manifest listing multiple merges to run concurrently; --in and --out are ignored")
+	flag.StringVar(&licenseTextDir, "license-text-cache", "", "Keep downloaded license files of tagged versions and commits in this directory across runs (e.g. ~/.cache/bom-merger/licenses)")
+	flag.StringVar(&vcsCacheURL, "vcs-cache", "", "Share VCS lookups through a redis:// or http(s):// cache, or keep them in a local JSON file (e.g. ~/.cache/bom-merger/vcs.json)")
+	flag.BoolVar(&refreshVCS, "refresh-vcs", false, "Detect every VCS root again instead of taking it from --vcs-cache, and update the cache")
+	flag.IntVar(&vcsWorkers, "vcs-workers", 0, "Number of VCS roots detected concurrently, 0 to start with GOMAXPROCS and add workers while lookups keep up")
+	flag.IntVar(&loadWorkers, "load-workers", 0, "Number of input files read and parsed concurrently, 0 to tune it like --vcs-workers")
+	flag.IntVar(&exportWorkers, "export-workers", 0, "Number of output files encoded and written concurrently, 0 to tune it like --vcs-workers")
+	flag.DurationVar(&vcsTimeout, "vcs-timeout", 30*time.Second, "Time after which detecting a VCS root fails, 0 for no limit")
+	flag.StringVar(&traceEndpoint, "otlp-endpoint", "", "Send OpenTelemetry spans of the merge stages and VCS lookups to this OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces; defaults to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT")
+	flag.BoolVar(&quiet, "quiet", false, "Print errors only")
+	flag.BoolVar(&porcelain, "porcelain", false, "Print only stable, tab separated records of the written files, warnings and completed merges to stdout")
+	flag.StringVar(&licenseAliases, "license-aliases", "", "Path to a file mapping license names found in the inputs to SPDX identifiers, in addition to the built-in aliases (comments and trailing commas are allowed)")
+	flag.StringVar(&licenseDataDir, "license-data-dir", "", "Directory with licenses.json and exceptions.json of the SPDX license list to use instead of the built-in identifiers")
+	hideDeprecatedFlags()
 }
 
-type projectAndLicenses struct {
-	Project  string    `json:"project"`
-	Licenses []license `json:"licenses,omitempty"`
-	Error    string    `json:"error,omitempty"`
-	VCS      string    `json:"vcs,omitempty"`
+// resources are shared by all mergers of a process, so identical lookups
+// of concurrent merges are done only once.
+type resources struct {
+	vcs      *vcsResolver
+	github   *githubClient
+	texts    *licenseTextFetcher
+	spdx     *spdxTextClient
+	sums     *checksumVerifier
+	registry *registryClient
+	remote   *remoteClient
+	depsdev  *depsdevClient
+	retracts *retractionChecker
+	tracer   *tracer
 }
 
-type license struct {
-	Type       string  `json:"type,omitempty"`
-	Confidence float64 `json:"confidence,omitempty"`
+func newResources() (*resources, error) {
+	if licenseDataDir != "" {
+		if err := loadLicenseData(licenseDataDir); err != nil {
+			return nil, err
+		}
+	}
+	if licenseAliases != "" {
+		if err := loadLicenseAliases(licenseAliases); err != nil {
+			return nil, err
+		}
+	}
+	var cache vcsCache
+	if vcsCacheURL != "" {
+		var err error
+		cache, err = newVCSCache(vcsCacheURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	github := newGitHubClient()
+	return &resources{
+		vcs:      newVCSResolver(cache, refreshVCS, vcsWorkers, vcsTimeout),
+		github:   github,
+		texts:    newLicenseTextFetcher(github, licenseTextDir),
+		spdx:     newSPDXTextClient(),
+		sums:     newChecksumVerifier(),
+		registry: newRegistryClient(),
+		remote:   newRemoteClient(),
+		depsdev:  newDepsDevClient(),
+		retracts: newRetractionChecker(),
+	}, nil
 }
 
-var regBOM = map[string]projectAndLicenses{}
-var regErrors = map[string]projectAndLicenses{}
-var regOverride = map[string]projectAndLicenses{}
+// merger holds the state of a single merge.
+type merger struct {
+	opts      options
+	res       *resources
+	lib       *merge.Merger
+	bom       *merge.Registry
+	errors    *merge.Registry
+	review    *merge.Registry
+	filtered  *merge.Registry
+	overrides *merge.Overrides
 
-func cleanupLicense(reg map[string]projectAndLicenses) {
-	for project, info := range reg {
-		if len(info.Licenses) > 1 {
-			var score float64 = 0
-			var idx int
+	// overrideSources maps the project or pattern of every override to
+	// the file that supplied it, and overridden counts the entries each
+	// file replaced.
+	overrideSources map[string]string
+	overridden      map[string]int
 
-			for i, lic := range info.Licenses {
-				if lic.Confidence > score {
-					score = lic.Confidence
-					idx = i
-				}
+	// unusedOverrides are the overrides that matched no project of the
+	// BOM, as "project (file)".
+	unusedOverrides []string
+
+	stage    string
+	keyBy    merge.KeyFunc
+	evidence map[string]string
+
+	// explain is the project whose processing is recorded in trace.
+	explain string
+	trace   []string
+
+	started  time.Time
+	vcsStats map[string]*hostStats
+	workers  map[string]int
+
+	// span is the span of the merge and stageSpan the one of its current
+	// stage, which started at stageStarted; stages adds up the time spent
+	// in every stage.
+	span         *span
+	stageSpan    *span
+	stageStarted time.Time
+	stages       map[string]time.Duration
+
+	configDigest string
+	audit        []auditEvent
+
+	declaredMismatches int
+	repoMismatches     []string
+	modifiedLicenses   []string
+
+	waived         int
+	expiredWaivers int
+
+	// policyViolations is nil unless a policy file is used.
+	policyViolations []policyViolation
+
+	// skipped are the errors ignored by --continue-on-error.
+	skipped []error
+}
+
+func newMerger(opts options, res *resources) *merger {
+	lib := merge.NewMerger()
+	lib.VersionConflict = merge.VersionStrategy(opts.VersionConflict)
+	review := merge.NewRegistry()
+	if lib.VersionConflict == merge.KeepAllVersions {
+		lib.BOM = merge.NewRegistryKeyedBy(merge.KeyByPathVersion)
+		lib.Filtered = merge.NewRegistryKeyedBy(merge.KeyByPathVersion)
+		review = merge.NewRegistryKeyedBy(merge.KeyByPathVersion)
+	}
+	return &merger{
+		opts:      opts,
+		res:       res,
+		lib:       lib,
+		bom:       lib.BOM,
+		errors:    lib.Errors,
+		review:    review,
+		filtered:  lib.Filtered,
+		overrides: &merge.Overrides{},
+		keyBy:     merge.KeyByProject,
+	}
+}
+
+// tracef records a step of the pipeline that touched project, if it is the
+// project being explained.
+func (m *merger) tracef(project, format string, args ...interface{}) {
+	if m.explain == "" || project != m.explain {
+		return
+	}
+	m.trace = append(m.trace, fmt.Sprintf("[%s] ", m.stage)+fmt.Sprintf(format, args...))
+}
+
+func annotateVersions(reg *merge.Registry) {
+	_ = reg.Each(func(info merge.Project) error {
+		reg.Set(merge.AnnotateVersion(info))
+		return nil
+	})
+}
+
+func annotateRisk(reg *merge.Registry) {
+	_ = reg.Each(func(info merge.Project) error {
+		reg.Set(merge.AnnotateRisk(merge.AnnotateLicenseStatus(info)))
+		return nil
+	})
+}
+
+// routeToReview moves every project for which reason returns a non-empty
+// string from the BOM to the review registry. Overridden projects are kept,
+// as overrides are already reviewed decisions.
+func (m *merger) routeToReview(reason func(p merge.Project) string) {
+	_ = m.bom.Each(func(p merge.Project) error {
+		if _, _, ok := m.overrides.Lookup(p); ok {
+			return nil
+		}
+		if r := reason(p); r != "" {
+			m.tracef(p.Project, "moved to review: %s", r)
+			m.auditf("review", p.Project, "%s", r)
+			p.ReviewReason = r
+			m.review.Set(p)
+			m.bom.Remove(p)
+		}
+		return nil
+	})
+}
+
+// detectInactive marks projects whose GitHub repository is archived or had no
+// commits within the configured number of years.
+func (m *merger) detectInactive(reg *merge.Registry) error {
+	years := m.opts.InactiveYears
+	if years <= 0 {
+		years = 2
+	}
+	cutoff := time.Now().AddDate(-years, 0, 0)
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		ownerRepo, ok := githubRepoPath(p.VCS)
+		if !ok {
+			return nil
+		}
+		repo, err := m.res.github.Repo(ownerRepo)
+		switch {
+		case errors.Is(err, errGitHubNotFound):
+			// a deleted or renamed repository is as unmaintained as it gets
+			p.Inactive = "repository not found"
+		case err != nil:
+			return m.skip(networkError(err))
+		case repo.Archived:
+			p.Inactive = "archived"
+		case !repo.PushedAt.IsZero() && repo.PushedAt.Before(cutoff):
+			p.Inactive = "no commits since " + repo.PushedAt.Format("2006-01-02")
+		default:
+			p.Inactive = ""
+		}
+		if p.Inactive != "" {
+			m.tracef(p.Project, "GitHub repository %s is inactive: %s", ownerRepo, p.Inactive)
+		}
+		reg.Set(p)
+		return nil
+	})
+}
+
+// verifyRepoLicenses compares the licenses of GitHub hosted projects with the
+// license GitHub shows for their repository and warns about every mismatch.
+func (m *merger) verifyRepoLicenses(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		ownerRepo, ok := githubRepoPath(p.VCS)
+		if !ok || len(p.Licenses) == 0 {
+			return nil
+		}
+		repo, err := m.res.github.Repo(ownerRepo)
+		if errors.Is(err, errGitHubNotFound) {
+			warnf("can not verify the license of %s, GitHub repository %s not found", p.Project, ownerRepo)
+			return nil
+		}
+		if err != nil {
+			return m.skip(networkError(err))
+		}
+		p.RepoLicense = ""
+		if id := repo.spdxID(); id != "" && !declaredLicenseMatches(id, p.Licenses) {
+			p.RepoLicense = id
+			mismatch := fmt.Sprintf("%s: detected %s, repository %s", p.Project, formatLicenses(p.Licenses), id)
+			warnf("license mismatch of %s", mismatch)
+			m.tracef(p.Project, "GitHub shows license %s for repository %s", id, ownerRepo)
+			m.repoMismatches = append(m.repoMismatches, mismatch)
+		}
+		reg.Set(p)
+		return nil
+	})
+}
+
+// addDeclaredLicenses records the license deps.dev declares for each
+// versioned project and whether it disagrees with the detected licenses.
+func (m *merger) addDeclaredLicenses(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		if p.Version == "" {
+			return nil
+		}
+		v, err := m.res.depsdev.Version(p.Project, p.Version)
+		if err != nil {
+			return m.skip(networkError(err))
+		}
+		if v == nil || len(v.Licenses) == 0 {
+			return nil
+		}
+		p.DeclaredLicense = strings.Join(v.Licenses, " AND ")
+		p.LicenseMismatch = !declaredLicenseMatches(p.DeclaredLicense, p.Licenses)
+		if p.LicenseMismatch {
+			m.tracef(p.Project, "declared license %s differs from detected %s", p.DeclaredLicense, formatLicenses(p.Licenses))
+			m.declaredMismatches++
+		}
+		reg.Set(p)
+		return nil
+	})
+}
+
+// addDepsDevInsights records the scorecard score of the source repository and
+// the dependent count of each versioned project.
+func (m *merger) addDepsDevInsights(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		if p.Version == "" {
+			return nil
+		}
+		v, err := m.res.depsdev.Version(p.Project, p.Version)
+		if err != nil {
+			return m.skip(networkError(err))
+		}
+		repo := ""
+		if v != nil {
+			repo = v.sourceRepo()
+		}
+		if ownerRepo, ok := githubRepoPath(p.VCS); repo == "" && ok {
+			repo = "github.com/" + ownerRepo
+		}
+		if repo != "" {
+			project, err := m.res.depsdev.Project(repo)
+			if err != nil {
+				return m.skip(networkError(err))
 			}
-			info.Licenses = []license{info.Licenses[idx]}
+			if project != nil && project.Scorecard != nil {
+				p.Scorecard = project.Scorecard.OverallScore
+				m.tracef(p.Project, "scorecard of %s is %v", repo, p.Scorecard)
+			}
+		}
+		if p.Dependents, err = m.res.depsdev.Dependents(p.Project, p.Version); err != nil {
+			return m.skip(networkError(err))
+		}
+		if p.Dependents < 0 {
+			p.Dependents = 0
+		}
+		reg.Set(p)
+		return nil
+	})
+}
+
+// declaredLicenseMatches reports whether any detected license is named in
+// the declared license expression.
+func declaredLicenseMatches(declared string, detected []merge.License) bool {
+	ids := map[string]bool{}
+	for _, id := range strings.FieldsFunc(declared, func(r rune) bool { return r == ' ' || r == '(' || r == ')' }) {
+		ids[strings.ToLower(id)] = true
+	}
+	for _, lic := range detected {
+		if ids[strings.ToLower(lic.Type)] {
+			return true
 		}
-		reg[project] = info
 	}
+	return false
 }
 
-func discoverVCS(reg map[string]projectAndLicenses) error {
-	for project, info := range reg {
-		vcs, err := mod.DetectVCSRoot(info.Project)
+// verifyChecksums records whether each versioned project served by the
+// module proxy matches the checksum database.
+func (m *merger) verifyChecksums(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment {
+			return nil
+		}
+		if p.Version == "" {
+			return nil
+		}
+		sum, status, err := m.res.sums.Verify(p.Project, p.Version)
 		if err != nil {
-			return err
+			return m.skip(networkError(err))
+		}
+		if status == integrityMismatch {
+			warnf("%s@%s does not match the checksum database", p.Project, p.Version)
 		}
+		m.tracef(p.Project, "checksum of %s %s", p.Version, status)
+		p.Checksum = sum
+		p.Integrity = status
+		reg.Set(p)
+		return nil
+	})
+}
+
+// checkRetractions flags every versioned project whose version the module
+// author retracted.
+func (m *merger) checkRetractions(reg *merge.Registry) error {
+	return reg.Each(func(p merge.Project) error {
+		if p.SkipEnrichment || p.Version == "" {
+			return nil
+		}
+		rationale, err := m.res.retracts.Retracted(p.Project, p.Version)
+		if err != nil {
+			return m.skip(networkError(err))
+		}
+		if rationale != "" {
+			warnf("%s@%s is retracted: %s", p.Project, p.Version, rationale)
+			m.tracef(p.Project, "version %s is retracted: %s", p.Version, rationale)
+		}
+		p.Retracted = rationale
+		reg.Set(p)
+		return nil
+	})
+}
+
+// flagVCSRedirects marks projects whose VCS root is on another host than
+// their module path, unless the pair of hosts is allowed.
+func (m *merger) flagVCSRedirects(reg *merge.Registry) {
+	allowed := map[string]bool{}
+	for _, pair := range m.opts.AllowVCSRedirects {
+		allowed[pair] = true
+	}
+	_ = reg.Each(func(p merge.Project) error {
+		p.VCSRedirect = ""
+		from, to := projectHost(p.Project), projectHost(p.VCS)
+		if p.VCS != "" && !strings.EqualFold(from, to) && !allowed[from+"="+to] {
+			m.tracef(p.Project, "VCS root %s is hosted on %s instead of %s", p.VCS, to, from)
+			p.VCSRedirect = from + " -> " + to
+			m.hostStats(p.Project).VCSRedirects++
+		}
+		reg.Set(p)
+		return nil
+	})
+}
+
+func (m *merger) discoverVCS(reg *merge.Registry) error {
+	var projects []string
+	_ = reg.Each(func(p merge.Project) error {
+		if p.SkipVCS {
+			m.tracef(p.Project, "VCS detection skipped by the skipVcs hint, keeping %q", p.VCS)
+			return nil
+		}
+		projects = append(projects, p.Project)
+		return nil
+	})
+	var started, ended []time.Time
+	if m.tracer() != nil {
+		started, ended = make([]time.Time, len(projects)), make([]time.Time, len(projects))
+	}
+	m.recordWorkers("vcs", m.res.vcs.Prefetch(projects, started, ended))
+	m.traceVCSLookups(projects, started, ended)
+
+	return merge.SetVCS(reg, func(project string) (string, error) {
+		vcs, source, err := m.res.vcs.Resolve(project)
+		if err != nil {
+			return "", m.skip(networkError(err))
+		}
+		m.recordVCSResolution(project, vcs != "")
 		if vcs != "" {
-			info.VCS = vcs
-		} else if strings.HasPrefix(project, "github.com/") {
-			// for github projects keep first 3 parts
-			info.VCS = strings.Join(strings.Split(project, "/")[:3], "/")
+			m.tracef(project, "VCS root %s resolved from %s", vcs, source)
+		} else if p, ok := reg.Get(project); ok {
+			m.tracef(project, "no VCS root found, keeping %q", p.VCS)
 		}
-		reg[project] = info
+		return vcs, nil
+	})
+}
+
+func sortedProjects(reg *merge.Registry, sortBy string) []merge.Project {
+	if sortBy == "risk" {
+		return reg.ProjectsByRisk()
 	}
-	return nil
+	return reg.Projects()
 }
 
-func writeBOM(filename string, reg map[string]projectAndLicenses) error {
-	bom := make([]projectAndLicenses, 0, len(reg))
-	for _, key := range Keys(reg) {
-		bom = append(bom, reg[key])
+// reportOrder is the order of entries in reports, such as the HTML report
+// and template documents, which start with the riskiest entries unless
+// --sort-by says otherwise.
+func reportOrder(sortBy string) string {
+	if sortBy == "" {
+		return "risk"
 	}
-	data, err := MarshalJson(bom)
+	return sortBy
+}
+
+// writeBOM writes the entries of reg in the native format or as a CycloneDX
+// or SPDX document.
+func writeBOM(filename string, reg *merge.Registry, sortBy, format string, compact bool) error {
+	data, err := encodeBOM(sortedProjects(reg, sortBy), format, compact)
 	if err != nil {
 		return err
 	}
 	return ioutil.WriteFile(filename, data, 0644)
 }
 
-func Keys(m map[string]projectAndLicenses) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+// encodeBOM encodes projects in one of the formats accepted by --format.
+func encodeBOM(projects []merge.Project, format string, compact bool) ([]byte, error) {
+	switch format {
+	case formatCycloneDX:
+		return marshalOutput(cycloneDX(projects), compact)
+	case formatSPDX:
+		doc, err := spdxDoc(projects)
+		if err != nil {
+			return nil, err
+		}
+		return marshalOutput(doc, compact)
+	case formatSPDXTV:
+		doc, err := spdxDoc(projects)
+		if err != nil {
+			return nil, err
+		}
+		return marshalSPDXTagValue(doc), nil
+	case formatYAML:
+		data, err := marshalOutput(projects, true)
+		if err != nil {
+			return nil, err
+		}
+		return jsonToYAML(data)
+	case formatCSV:
+		return marshalCSV(projects)
+	case formatMarkdown:
+		return marshalMarkdown(projects), nil
+	default:
+		return marshalOutput(projects, compact)
 	}
-	sort.Strings(keys)
-	return keys
 }
 
 func MarshalJson(v interface{}) ([]byte, error) {
+	return marshalOutput(v, false)
+}
+
+// marshalOutput encodes v indented, or minified if compact is set.
+func marshalOutput(v interface{}, compact bool) ([]byte, error) {
 	var buf bytes.Buffer
 	encoder := json.NewEncoder(&buf)
 	encoder.SetEscapeHTML(false)
-	encoder.SetIndent("", "  ")
+	if !compact {
+		encoder.SetIndent("", "  ")
+	}
 	err := encoder.Encode(v)
 	if err != nil {
 		return nil, err
@@ -128,107 +718,966 @@ func MarshalJson(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func loadBOM(filename string) error {
-	f, err := os.Open(filename)
+// loadFragments reads and parses the fragments named by sources with a
+// pool of --load-workers workers, and merges them in order, so the result
+// does not depend on which one is parsed first.
+func (m *merger) loadFragments(sources []string, read func(i int) ([]byte, error)) error {
+	docs := make([]*merge.Document, len(sources))
+	errs := make([]error, len(sources))
+	m.recordWorkers("load", runPool(len(sources), loadWorkers, func(i int) {
+		data, err := read(i)
+		if err == nil {
+			docs[i], err = parseBOMAs(sources[i], data, m.yamlInput(sources[i]))
+		}
+		errs[i] = err
+	}))
+	for i, source := range sources {
+		err := errs[i]
+		if err == nil {
+			err = m.addBOM(source, docs[i])
+		}
+		if err := m.skip(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSourceFragments loads fragments that were already read.
+func (m *merger) loadSourceFragments(fragments []sourceFragment) error {
+	sources := make([]string, len(fragments))
+	for i, f := range fragments {
+		sources[i] = f.Source
+	}
+	return m.loadFragments(sources, func(i int) ([]byte, error) {
+		return fragments[i].Data, nil
+	})
+}
+
+// addBOM merges the fragment doc read from filename, which names the source
+// of the fragment.
+func (m *merger) addBOM(filename string, doc *merge.Document) error {
+	if doc.Legacy {
+		warnf("%s uses the deprecated two-document format, convert it with bom-merger migrate", filename)
+	}
+
+	for _, project := range doc.Projects {
+		earlier, ok := m.bom.Get(m.bom.KeyOf(project))
+		switch {
+		case !ok:
+			m.tracef(project.Project, "supplied by %s", filename)
+		case m.lib.VersionConflict == merge.HighestVersion && earlier.Version != "" && project.Version != "" &&
+			merge.CompareVersions(project.Version, earlier.Version) < 0:
+			m.tracef(project.Project, "supplied by %s in version %s, keeping the higher version %s of an earlier fragment", filename, project.Version, earlier.Version)
+			m.auditf("conflict", project.Project, "kept version %s of an earlier fragment over version %s of %s", earlier.Version, project.Version, filename)
+		default:
+			m.tracef(project.Project, "supplied by %s, replacing the entry of an earlier fragment", filename)
+			m.auditf("conflict", project.Project, "entry of %s replaced the entry of an earlier fragment", filename)
+		}
+	}
+	for _, project := range doc.Errors {
+		m.tracef(project.Project, "error reported by %s: %s", filename, project.Error)
+	}
+	return m.lib.Add(filename, doc)
+}
+
+// partialBOM is written to bom.partial.json when the pipeline fails after
+// some inputs were merged, so long runs leave an artifact to inspect. The
+// next successful run removes it.
+type partialBOM struct {
+	Partial  bool            `json:"partial"`
+	Stage    string          `json:"stage"`
+	Error    string          `json:"error"`
+	Projects []merge.Project `json:"projects"`
+	Errors   []merge.Project `json:"errors"`
+	Review   []merge.Project `json:"review,omitempty"`
+}
+
+const partialFileName = "bom.partial.json"
+
+func (m *merger) writePartialBOM(filename, stage string, cause error) error {
+	data, err := marshalOutput(partialBOM{
+		Partial:  true,
+		Stage:    stage,
+		Error:    cause.Error(),
+		Projects: m.bom.Projects(),
+		Errors:   m.errors.Projects(),
+		Review:   m.review.Projects(),
+	}, m.opts.Compact)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func main() {
+	args := os.Args[1:]
+	name := "merge"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else {
+		commandOmitted = len(args) > 0
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(exitInput)
+	}
+	passed, err := cmd.run(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if !passed {
+		os.Exit(exitPolicy)
+	}
+}
+
+// runMerge runs the merge, or all merges of --manifest, configured by the
+// global flags.
+func runMerge(args []string, writeLock bool) error {
+	opts.writeLock = writeLock
+	_ = flag.CommandLine.Parse(args)
+	if err := setupConsole(); err != nil {
+		return err
+	}
+	reportDeprecations()
+
+	res, err := newResources()
+	if err != nil {
+		return err
+	}
+	res.tracer = newTracer(otlpEndpoint(traceEndpoint))
+	defer res.vcs.Flush()
+	defer func() {
+		if err := res.tracer.Flush(); err != nil {
+			warnf("failed to export traces: %v", err)
+		}
+	}()
+	if manifestFile != "" {
+		return runManifest(manifestFile, opts.writeLock, res)
+	}
+	return newMerger(opts, res).run()
+}
+
+func (m *merger) run() (err error) {
+	m.span = m.tracer().Start("merge", nil, attr("in", m.opts.In.String()), attr("out", m.opts.Out))
+	defer func() {
+		m.endStage(err)
+		m.span.End(err)
+	}()
+	defer func() {
+		if err == nil || m.stage == "done" || m.opts.Out == "" || m.opts.Out == stdio || m.bom.Len()+m.errors.Len()+m.review.Len() == 0 {
+			return
+		}
+		if perr := m.writePartialBOM(filepath.Join(m.opts.Out, partialFileName), m.stage, err); perr != nil {
+			err = fmt.Errorf("%v (failed to write partial output: %v)", err, perr)
+		}
+	}()
+
+	m.started = time.Now()
+	if m.opts.AuditLog != "" {
+		if m.configDigest, err = m.digestConfig(); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				m.auditf("run", "", "failed: %v", err)
+			} else {
+				m.auditf("run", "", "succeeded")
+			}
+			if aerr := appendAuditLog(m.opts.AuditLog, m.audit); aerr != nil && err == nil {
+				err = aerr
+			}
+		}()
+	}
+	if err = m.merge(); err != nil {
+		return err
+	}
+	m.setStage("guard")
+	if err = m.checkGuardrails(); err != nil {
+		return policyError(err)
+	}
+	m.setStage("write")
+	if err = m.write(); err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			porcelainf("done", m.opts.Out)
+		}
+	}()
+	m.setStage("done")
+	return m.skippedError(policyError(m.check()))
+}
+
+// merge runs every stage of the pipeline up to writing the outputs.
+func (m *merger) merge() (err error) {
+	m.setStage("load")
+	if m.started.IsZero() {
+		m.started = time.Now()
+	}
+
+	if m.opts.SortBy != "" && m.opts.SortBy != "project" && m.opts.SortBy != "risk" {
+		return fmt.Errorf("invalid sort order %q, must be project or risk", m.opts.SortBy)
+	}
+
+	if _, err = parseSplitBy(m.opts.SplitBy); err != nil {
+		return err
+	}
+
+	if err = validateFormat(m.opts.Format, m.opts.SplitBy); err != nil {
+		return err
+	}
+
+	if err = validateInputFormat(m.opts.InputFormat); err != nil {
+		return err
 	}
-	defer f.Close()
 
-	decoder := json.NewDecoder(f)
+	if err = validateStdio(m.opts); err != nil {
+		return err
+	}
+
+	if err = validateModuleFilters(m.opts); err != nil {
+		return err
+	}
 
-	gooddoc := true
-	for {
-		var info []projectAndLicenses
-		err = decoder.Decode(&info)
-		if err == io.EOF {
-			break
+	if err = validateLicenseTexts(m.opts.LicenseTexts); err != nil {
+		return err
+	}
+	if m.opts.DetectModifiedLicenses && m.opts.LicenseTexts == "" {
+		return fmt.Errorf("--detect-modified-licenses requires --license-texts")
+	}
+
+	if _, err = merge.ParseVersionStrategy(m.opts.VersionConflict); err != nil {
+		return err
+	}
+
+	if m.opts.ExportFilter != "" {
+		if _, err = parseExportFilter(m.opts.ExportFilter); err != nil {
+			return err
 		}
+	}
+
+	if err = m.validateGuardrails(); err != nil {
+		return err
+	}
+
+	if m.opts.KeyBy != "" {
+		m.keyBy, err = merge.KeyFuncFor(m.opts.KeyBy)
 		if err != nil {
 			return err
 		}
-		if gooddoc {
-			for _, project := range info {
-				if project.Project == "" {
-					continue
-				}
-				regBOM[project.Project] = project
+	}
+
+	if len(m.opts.OverrideFiles) > 0 {
+		files, err := overrideFiles(m.opts.OverrideFiles)
+		if err != nil {
+			return err
+		}
+		// later files win: an entry for the same project or pattern
+		// replaces the one of an earlier file
+		var overrides []merge.Project
+		m.overrideSources = map[string]string{}
+		for _, filename := range files {
+			data, err := readFileOrStdin(filename)
+			if err != nil {
+				return err
 			}
-			gooddoc = false
-		} else {
-			for _, project := range info {
-				if project.Project == "" {
-					continue
+			var entries []merge.Project
+			if err = json.Unmarshal(stripJSONC(data), &entries); err != nil {
+				return fmt.Errorf("failed to parse override file %s: %v", filename, err)
+			}
+			for _, o := range entries {
+				if _, err := merge.NewOverrides([]merge.Project{o}); err != nil {
+					return fmt.Errorf("failed to parse override file %s: %v", filename, err)
 				}
-				regErrors[project.Project] = project
+				overrides = append(overrides, merge.NormalizeLicenses(o))
+				m.overrideSources[o.Project] = filename
 			}
 		}
+		if m.overrides, err = merge.NewOverrides(overrides); err != nil {
+			return err
+		}
 	}
-	return nil
-}
-
-func main() {
-	flag.Parse()
 
-	if overrideFile != "" {
-		data, err := ioutil.ReadFile(overrideFile)
+	var labels map[string]map[string]string
+	if m.opts.LabelsFile != "" {
+		data, err := ioutil.ReadFile(m.opts.LabelsFile)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		var overrides []projectAndLicenses
-		err = json.Unmarshal(data, &overrides)
+		err = json.Unmarshal(stripJSONC(data), &labels)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to parse labels file %s: %v", m.opts.LabelsFile, err)
+		}
+	}
+
+	var waivers []waiverEntry
+	if m.opts.WaiversFile != "" {
+		if waivers, err = readWaivers(m.opts.WaiversFile); err != nil {
+			return err
 		}
-		for _, project := range overrides {
-			regOverride[project.Project] = project
+	}
+
+	var policy *licensePolicy
+	if m.opts.PolicyFile != "" {
+		if policy, err = readPolicy(m.opts.PolicyFile); err != nil {
+			return err
 		}
 	}
 
-	files, err := ioutil.ReadDir(dirIn)
+	filter, err := newInputFilter(m.opts.Include, m.opts.Exclude)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	for _, f := range files {
-		if !f.IsDir() {
-			err = loadBOM(filepath.Join(dirIn, f.Name()))
-			if err != nil {
-				panic(err)
+	if len(m.opts.In) == 0 && len(m.opts.Images) == 0 {
+		return fmt.Errorf("--in or --image is required")
+	}
+	for _, in := range m.opts.In {
+		if err := m.loadInput(in, filter); err != nil {
+			return err
+		}
+	}
+	for _, image := range m.opts.Images {
+		fragments, err := m.res.registry.Fragments(image)
+		if err != nil {
+			if err := m.skip(networkError(err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(fragments) == 0 {
+			if err := m.skip(fmt.Errorf("image %s has no BOM fragment", image)); err != nil {
+				return err
 			}
+			continue
 		}
+		if err := m.loadSourceFragments(fragments); err != nil {
+			return err
+		}
+	}
+
+	m.evidence, err = evidenceHashes(m.bom)
+	if err != nil {
+		return err
+	}
+
+	m.setStage("cleanup")
+	detected, _ := m.bom.Get(m.explain)
+	if m.opts.KeepMultiLicenses {
+		m.lib.MultiLicenseThreshold = m.opts.MultiLicenseConfidence
+	}
+	m.lib.Cleanup()
+	if p, ok := m.bom.Get(m.explain); ok && p.LicenseExpression != "" {
+		m.tracef(p.Project, "kept licenses %s of %d detected", p.LicenseExpression, len(detected.Licenses))
+	} else if ok && len(detected.Licenses) > 1 {
+		m.tracef(p.Project, "kept license %s (confidence %v) of %d detected", p.Licenses[0].Type, p.Licenses[0].Confidence, len(detected.Licenses))
 	}
 
-	cleanupLicense(regBOM)
+	m.setStage("filter")
+	if len(m.opts.ToolsFrom) > 0 {
+		if err = m.markToolScope(m.opts.ToolsFrom); err != nil {
+			return err
+		}
+	}
+	if len(m.opts.BinariesFrom) > 0 {
+		if err = m.attributeBinaries(m.opts.BinariesFrom, m.bom, m.errors); err != nil {
+			return err
+		}
+	}
+	if err = m.filterModules(); err != nil {
+		return err
+	}
+	for _, scope := range m.opts.FilterScopes {
+		scope := scope
+		removed := m.lib.Filter("filter-scopes: "+scope, func(p merge.Project) bool {
+			return p.Scope == scope
+		})
+		for _, p := range removed {
+			m.tracef(p.Project, "removed by --filter-scopes %s", scope)
+			m.auditf("filter", p.Project, "filter-scopes: %s", scope)
+		}
+	}
 
-	for _, module := range filterModules {
-		for project := range regBOM {
-			if strings.HasPrefix(project, module) {
-				delete(regBOM, project)
+	if m.opts.RequireConfidence || m.opts.MinConfidence > 0 {
+		m.routeToReview(func(p merge.Project) string {
+			switch {
+			case len(p.Licenses) == 0:
+				return ""
+			case m.opts.RequireConfidence && p.BestConfidence() == 0:
+				return "license detected without confidence"
+			case p.BestConfidence() < m.opts.MinConfidence:
+				return fmt.Sprintf("best license confidence %v is below --min-confidence %v", p.BestConfidence(), m.opts.MinConfidence)
 			}
+			return ""
+		})
+	}
+
+	m.setStage("override")
+	if _, key, ok := m.overrides.Lookup(merge.Project{Project: m.explain}); ok {
+		entry := "the entry"
+		if key != m.explain {
+			entry = "the entry for " + key
+		}
+		if _, ok := m.bom.Get(m.explain); ok {
+			m.tracef(m.explain, "replaced by %s in %s", entry, m.overrideSources[key])
+		} else {
+			m.tracef(m.explain, "%s in %s not applied, project is not in the BOM", entry, m.overrideSources[key])
 		}
 	}
+	used := map[string]bool{}
+	_ = m.bom.Each(func(p merge.Project) error {
+		o, key, ok := m.overrides.Lookup(p)
+		if !ok {
+			return nil
+		}
+		used[key] = true
+		source := m.overrideSources[key]
+		if m.overridden == nil {
+			m.overridden = map[string]int{}
+		}
+		m.overridden[source]++
+		if key != p.Project {
+			m.auditf("override", p.Project, "licenses %s replaced by %s from %s, matching %s", formatLicenses(p.Licenses), formatLicenses(o.Licenses), source, key)
+		} else {
+			m.auditf("override", p.Project, "licenses %s replaced by %s from %s", formatLicenses(p.Licenses), formatLicenses(o.Licenses), source)
+		}
+		return nil
+	})
+	for _, key := range m.overrides.Keys() {
+		if !used[key] {
+			warnf("override for %s in %s matched no project in the BOM", key, m.overrideSources[key])
+			m.unusedOverrides = append(m.unusedOverrides, fmt.Sprintf("%s (%s)", key, m.overrideSources[key]))
+		}
+	}
+	m.lib.ApplyOverrideSet(m.overrides)
+	if kv, ok := labels[m.explain]; ok {
+		m.tracef(m.explain, "labeled %v by %s", kv, m.opts.LabelsFile)
+	}
+	m.bom.ApplyLabels(labels)
+	m.errors.ApplyLabels(labels)
+	annotateVersions(m.bom)
+	annotateVersions(m.errors)
+	annotateVersions(m.review)
+	if p, ok := m.bom.Get(m.explain); ok && p.PseudoVersion {
+		m.tracef(p.Project, "version %s is a pseudo-version of revision %s", p.Version, p.Revision)
+	}
 
-	for project := range regBOM {
-		if override, ok := regOverride[project]; ok {
-			regBOM[project] = override
+	if m.opts.Locked {
+		m.setStage("lock")
+		lock, err := readLockFile(m.lockFile())
+		if err != nil {
+			return err
+		}
+		if err = applyLock(m.bom, lock, m.evidence); err != nil {
+			return err
 		}
+		if _, ok := m.bom.Get(m.explain); ok {
+			m.tracef(m.explain, "VCS root and licenses taken from %s", m.lockFile())
+		}
+		m.auditf("lock", "", "VCS roots and licenses of %d projects taken from %s", m.bom.Len(), m.lockFile())
 	}
 
-	err = discoverVCS(regBOM)
+	m.setStage("vcs")
+	if !m.opts.Locked {
+		err = m.discoverVCS(m.bom)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.discoverVCS(m.errors)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	err = discoverVCS(regErrors)
+	err = m.discoverVCS(m.review)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = writeBOM(filepath.Join(dirOut, "bom.json"), regBOM)
+	m.flagVCSRedirects(m.bom)
+	m.flagVCSRedirects(m.review)
+
+	if len(m.opts.ReplacesFrom) > 0 {
+		if err = m.markForks(m.opts.ReplacesFrom, m.bom, m.errors, m.review); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.DetectInactive {
+		m.setStage("enrich")
+		if err = m.detectInactive(m.bom); err != nil {
+			return err
+		}
+	}
+
+	m.setStage("enrich")
+	err = merge.Enrich(m.bom, func(err error) {
+		warnf("%v", err)
+	})
 	if err != nil {
-		panic(err)
+		return err
 	}
-	err = writeBOM(filepath.Join(dirOut, "bom_error.json"), regErrors)
+
+	if m.opts.VerifyRepoLicenses {
+		m.setStage("enrich")
+		if err = m.verifyRepoLicenses(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.LicenseTexts != "" {
+		m.setStage("enrich")
+		if err = m.addLicenseTexts(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.DetectModifiedLicenses {
+		m.setStage("enrich")
+		if err = m.detectModifiedLicenses(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.DeclaredLicenses {
+		m.setStage("enrich")
+		if err = m.addDeclaredLicenses(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.DepsDevInsights || m.opts.MinScorecard > 0 {
+		m.setStage("enrich")
+		if err = m.addDepsDevInsights(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.CheckRetractions || m.opts.FailOnRetracted {
+		m.setStage("enrich")
+		if err = m.checkRetractions(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.HistoryDir != "" {
+		m.setStage("enrich")
+		if err = m.markIntroduced(m.bom); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.VerifyChecksums {
+		m.setStage("integrity")
+		if err = m.verifyChecksums(m.bom); err != nil {
+			return err
+		}
+	}
+
+	m.setStage("risk")
+	annotateRisk(m.bom)
+	annotateRisk(m.review)
+	if m.opts.LicenseURLs {
+		addLicenseURLs(m.bom)
+		addLicenseURLs(m.review)
+	}
+	_ = m.errors.Each(func(p merge.Project) error {
+		m.errors.Set(merge.AnnotateLicenseStatus(p))
+		return nil
+	})
+	if len(waivers) > 0 {
+		for _, reg := range []*merge.Registry{m.bom, m.errors, m.review} {
+			m.applyWaivers(reg, waivers)
+		}
+	}
+	if policy != nil {
+		m.evaluatePolicy(policy)
+	}
+	for _, reg := range []*merge.Registry{m.bom, m.review} {
+		if p, ok := reg.Get(m.explain); ok {
+			m.tracef(p.Project, "license category %s, risk %d", p.Category, p.Risk)
+		}
+	}
+	return nil
+}
+
+func (m *merger) lockFile() string {
+	if m.opts.LockFile != "" {
+		return m.opts.LockFile
+	}
+	return filepath.Join(m.opts.Out, "bom.lock.json")
+}
+
+// outputRegistry returns reg keyed as requested by --key-by.
+func (m *merger) outputRegistry(reg *merge.Registry, strategy merge.ConflictStrategy) (*merge.Registry, error) {
+	if m.opts.KeyBy == "" || m.opts.KeyBy == "module" {
+		return reg, nil
+	}
+	out, err := reg.Rekey(m.keyBy, strategy)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	_ = out.Each(func(p merge.Project) error {
+		if len(p.Aliases) > 1 {
+			m.auditf("conflict", outputKey(p), "entries of %s combined by %s", strings.Join(p.Aliases, ", "), strategy)
+		}
+		return nil
+	})
+	return out, nil
+}
+
+// write writes all outputs to a staging directory in the output directory
+// and only moves them into place once every exporter succeeded, so
+// consumers never see a half-updated set of files.
+func (m *merger) write() error {
+	if m.opts.Out == stdio {
+		return m.writeStdout()
+	}
+	staging, err := ioutil.TempDir(m.opts.Out, ".staging-"+m.started.UTC().Format("20060102T150405Z")+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	files, err := m.writeOutputs(staging)
+	if err != nil {
+		return err
+	}
+	if err := writeChecksums(staging); err != nil {
+		return err
 	}
+	if m.opts.PerSourceOut != "" {
+		if err := m.writePerSource(m.opts.PerSourceOut); err != nil {
+			return err
+		}
+	}
+	if err := commitOutputs(staging, m.opts.Out, m.started); err != nil {
+		return err
+	}
+	for _, f := range files {
+		porcelainf("output", filepath.Join(m.opts.Out, f.name), f.entries)
+	}
+
+	if m.opts.writeLock && m.opts.LockFile != "" {
+		err := writeLockFile(m.opts.LockFile, m.bom, m.evidence)
+		if err != nil {
+			return err
+		}
+	}
+	if m.opts.HistoryDir != "" {
+		return m.recordHistory(m.bom)
+	}
+	return nil
+}
+
+type writtenFile struct {
+	name    string
+	entries int
+}
+
+// writeOutputs writes every output that belongs to the output directory to
+// dir and returns the BOM files written.
+func (m *merger) writeOutputs(dir string) ([]writtenFile, error) {
+	type output struct {
+		name     string
+		reg      *merge.Registry
+		strategy merge.ConflictStrategy
+	}
+	outputs := []output{
+		{"bom.json", m.bom, merge.HighestConfidence},
+		{"bom_error.json", m.errors, merge.CombineErrors},
+	}
+	if m.opts.RequireConfidence || m.opts.MinConfidence > 0 {
+		outputs = append(outputs, output{"bom_review.json", m.review, merge.HighestConfidence})
+	}
+	if m.opts.WriteFiltered {
+		outputs = append(outputs, output{"bom_filtered.json", m.filtered, merge.HighestConfidence})
+	}
+
+	// the outputs are independent of each other and encoded and written
+	// by a pool of --export-workers workers; only the report, which counts
+	// their entries, comes last
+	var exports []func() error
+	if m.opts.LicenseTexts == licenseTextsDir {
+		exports = append(exports, func() error { return writeLicenseTexts(dir, m.bom) })
+	}
+	var files []writtenFile
+	written := map[string]int{}
+	for _, o := range outputs {
+		reg, err := m.outputRegistry(o.reg, o.strategy)
+		if err != nil {
+			return nil, err
+		}
+		written[o.name] = reg.Len()
+		if m.opts.ValidateOutput && (o.name != "bom.json" || m.opts.Format == formatNative || m.opts.Format == formatYAML) {
+			if err := validateOutput(o.name, sortedProjects(reg, m.opts.SortBy)); err != nil {
+				return nil, err
+			}
+		}
+		name := o.name
+		if o.name == "bom.json" && m.opts.SplitBy != "" {
+			name = "bom.index.json"
+			exports = append(exports, func() error {
+				return writeSplitBOM(dir, sortedProjects(reg, m.opts.SortBy), m.opts.SplitBy, m.opts.Compact)
+			})
+		} else {
+			format := formatNative
+			if o.name == "bom.json" {
+				format = m.opts.Format
+				name = bomFileName(format)
+			}
+			filename := filepath.Join(dir, name)
+			exports = append(exports, func() error {
+				return writeBOM(filename, reg, m.opts.SortBy, format, m.opts.Compact)
+			})
+		}
+		files = append(files, writtenFile{name, reg.Len()})
+	}
+	if m.opts.writeLock && m.opts.LockFile == "" {
+		exports = append(exports, func() error {
+			return writeLockFile(filepath.Join(dir, "bom.lock.json"), m.bom, m.evidence)
+		})
+	}
+	if m.opts.Template != "" {
+		exports = append(exports, func() error { return m.writeTemplate(m.opts.Template, dir) })
+	}
+	if m.opts.Notice != "" {
+		exports = append(exports, func() error { return m.writeNotice(m.opts.Notice, m.opts.NoticeLicenseTexts, dir) })
+	}
+	if m.opts.HTMLReport {
+		exports = append(exports, func() error { return m.writeHTMLReport(dir) })
+	}
+	if m.policyViolations != nil {
+		exports = append(exports, func() error { return m.writePolicyReport(filepath.Join(dir, "bom_policy.json")) })
+	}
+	errs := make([]error, len(exports))
+	m.recordWorkers("export", runPool(len(exports), exportWorkers, func(i int) {
+		errs[i] = exports[i]()
+	}))
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m.opts.WriteReport {
+		err := m.writeReport(filepath.Join(dir, "bom_report.json"), written)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// commitOutputs moves the files of staging into out, stamped with the start
+// of the run. Each file is replaced atomically; bom.json and bom.index.json
+// go last, so a consumer that sees a new BOM also sees the files belonging
+// to it. Afterwards the files an earlier run listed in SHA256SUMS but this
+// run did not write are removed, as is a bom.partial.json of a failed run.
+func commitOutputs(staging, out string, started time.Time) error {
+	entries, err := ioutil.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	stale, err := staleOutputs(out, entries)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return !isBOMIndex(entries[i].Name()) && isBOMIndex(entries[j].Name())
+	})
+	for _, e := range entries {
+		src := filepath.Join(staging, e.Name())
+		dst := filepath.Join(out, e.Name())
+		if err := os.Chtimes(src, started, started); err != nil {
+			return err
+		}
+		// directories, like licenses, replace the previous one as a whole
+		if e.IsDir() {
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	for _, name := range stale {
+		if err := os.RemoveAll(filepath.Join(out, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleOutputs returns the top-level files and directories of out written
+// by an earlier run that are not among the new entries. The lock file is
+// kept, since later --locked runs read it.
+func staleOutputs(out string, entries []os.FileInfo) ([]string, error) {
+	current := map[string]bool{"bom.lock.json": true}
+	for _, e := range entries {
+		current[e.Name()] = true
+	}
+	stale := []string{}
+	if !current[partialFileName] {
+		stale = append(stale, partialFileName)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(out, checksumsFile))
+	if os.IsNotExist(err) {
+		return stale, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.SplitN(fields[1], "/", 2)[0]
+		if name == "" || name == "." || name == ".." || current[name] {
+			continue
+		}
+		current[name] = true
+		stale = append(stale, name)
+	}
+	return stale, nil
+}
+
+func isBOMIndex(name string) bool {
+	for _, format := range []string{formatNative, formatSPDXTV, formatYAML, formatCSV, formatMarkdown} {
+		if name == bomFileName(format) {
+			return true
+		}
+	}
+	return name == "bom.index.json"
+}
+
+// bomFileName returns the name of the BOM written in format.
+func bomFileName(format string) string {
+	switch format {
+	case formatSPDXTV:
+		return "bom.spdx"
+	case formatYAML:
+		return "bom.yaml"
+	case formatCSV:
+		return "bom.csv"
+	case formatMarkdown:
+		return "bom.md"
+	}
+	return "bom.json"
+}
+
+// licenseCoverage returns the percentage of entries, including error and
+// review entries, that have a license detected with at least the minimum
+// confidence. Overridden and waived entries count as covered.
+func (m *merger) licenseCoverage() float64 {
+	total := m.bom.Len() + m.errors.Len() + m.review.Len()
+	if total == 0 {
+		return 100
+	}
+	covered := 0
+	for _, reg := range []*merge.Registry{m.errors, m.review} {
+		_ = reg.Each(func(p merge.Project) error {
+			if p.Waiver != nil {
+				covered++
+			}
+			return nil
+		})
+	}
+	_ = m.bom.Each(func(p merge.Project) error {
+		if p.Waiver != nil {
+			covered++
+		} else if _, _, ok := m.overrides.Lookup(p); ok && len(p.Licenses) > 0 {
+			covered++
+		} else if len(p.Licenses) > 0 && p.BestConfidence() >= m.opts.MinLicenseConfidence {
+			covered++
+		}
+		return nil
+	})
+	return float64(covered) * 100 / float64(total)
+}
+
+// check enforces the rules that fail a merge after its outputs were written.
+func (m *merger) check() error {
+	if m.opts.StrictOverrides {
+		if len(m.unusedOverrides) > 0 {
+			err := fmt.Errorf("overrides matching no project found: %s", strings.Join(m.unusedOverrides, ", "))
+			m.auditf("policy", "", "strict-overrides failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "strict-overrides passed")
+	}
+	if m.opts.MinLicenseCoverage > 0 {
+		c := m.licenseCoverage()
+		if c < m.opts.MinLicenseCoverage {
+			err := fmt.Errorf("license coverage %.1f%% is below the required %.1f%%", c, m.opts.MinLicenseCoverage)
+			m.auditf("policy", "", "min-license-coverage failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "min-license-coverage passed: %.1f%% of entries covered", c)
+	}
+	if m.opts.FailOnInactive {
+		var inactive []string
+		for _, p := range m.bom.Projects() {
+			if p.Inactive != "" && p.Waiver == nil {
+				inactive = append(inactive, p.Project)
+			}
+		}
+		if len(inactive) > 0 {
+			err := fmt.Errorf("inactive projects found: %s", strings.Join(inactive, ", "))
+			m.auditf("policy", "", "fail-on-inactive failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "fail-on-inactive passed")
+	}
+	if m.opts.MinScorecard > 0 {
+		var low []string
+		for _, p := range m.bom.Projects() {
+			if p.Scorecard > 0 && p.Scorecard < m.opts.MinScorecard && p.Waiver == nil {
+				low = append(low, fmt.Sprintf("%s (%v)", p.Project, p.Scorecard))
+			}
+		}
+		if len(low) > 0 {
+			err := fmt.Errorf("projects with scorecard below %v found: %s", m.opts.MinScorecard, strings.Join(low, ", "))
+			m.auditf("policy", "", "min-scorecard failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "min-scorecard passed")
+	}
+	if m.opts.FailOnRetracted {
+		var retracted []string
+		for _, p := range m.bom.Projects() {
+			if p.Retracted != "" && p.Waiver == nil {
+				retracted = append(retracted, fmt.Sprintf("%s@%s (%s)", p.Project, p.Version, p.Retracted))
+			}
+		}
+		if len(retracted) > 0 {
+			err := fmt.Errorf("projects pinned to retracted versions found: %s", strings.Join(retracted, ", "))
+			m.auditf("policy", "", "fail-on-retracted failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "fail-on-retracted passed")
+	}
+	if m.opts.FailOnVCSRedirect {
+		var redirected []string
+		for _, p := range m.bom.Projects() {
+			if p.VCSRedirect != "" && p.Waiver == nil {
+				redirected = append(redirected, fmt.Sprintf("%s (%s)", p.Project, p.VCSRedirect))
+			}
+		}
+		if len(redirected) > 0 {
+			err := fmt.Errorf("projects with VCS root on another host found: %s", strings.Join(redirected, ", "))
+			m.auditf("policy", "", "fail-on-vcs-redirect failed: %v", err)
+			return err
+		}
+		m.auditf("policy", "", "fail-on-vcs-redirect passed")
+	}
+	if m.policyViolations != nil {
+		if err := m.checkPolicy(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
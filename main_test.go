@@ -0,0 +1,130 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailedRunThenSuccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-outputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "in"), filepath.Join(dir, "out")
+	for _, d := range []string{in, out} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fragment := `{"version": 1, "projects": [{"project": "example.com/x", "vcs": "github.com/example/x", "licenses": [{"type": "MIT", "confidence": 1}], "skipVcs": true}]}`
+	if err := ioutil.WriteFile(filepath.Join(in, "a.json"), []byte(fragment), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := newResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge := func(configure func(o *options)) error {
+		o := opts
+		o.In = fileList{in}
+		o.Out = out
+		configure(&o)
+		return newMerger(o, res).run()
+	}
+
+	// a successful run with a report, then a failed one
+	if err := merge(func(o *options) { o.WriteReport = true }); err != nil {
+		t.Fatal(err)
+	}
+	err = merge(func(o *options) { o.Template = filepath.Join(dir, "missing.tmpl") })
+	if err == nil {
+		t.Fatal("merge with a missing template succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(out, partialFileName)); err != nil {
+		t.Fatalf("failed run left no partial output: %v", err)
+	}
+
+	// the next successful run removes the partial output and the report it
+	// no longer writes
+	if err := merge(func(o *options) {}); err != nil {
+		t.Fatal(err)
+	}
+	got := listDir(t, out)
+	want := []string{"SHA256SUMS", "bom.json", "bom_error.json"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("outputs = %q, want %q", got, want)
+	}
+	sums, err := ioutil.ReadFile(filepath.Join(out, checksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range want[1:] {
+		if !strings.Contains(string(sums), "  "+name+"\n") {
+			t.Errorf("%s missing from %s:\n%s", name, checksumsFile, sums)
+		}
+	}
+}
+
+func TestStaleOutputsKeepsLockFile(t *testing.T) {
+	out, err := ioutil.TempDir("", "bom-outputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	sums := "00  bom.json\n00  bom.lock.json\n00  bom_report.json\n00  licenses/MIT.txt\n00  licenses/BSD-3-Clause.txt\n"
+	if err := ioutil.WriteFile(filepath.Join(out, checksumsFile), []byte(sums), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := staleOutputs(out, []os.FileInfo{fakeFile("bom.json"), fakeFile("SHA256SUMS")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{partialFileName, "bom_report.json", "licenses"}
+	if strings.Join(stale, ",") != strings.Join(want, ",") {
+		t.Errorf("stale = %q, want %q", stale, want)
+	}
+}
+
+type fakeFile string
+
+func (f fakeFile) Name() string       { return string(f) }
+func (f fakeFile) Size() int64        { return 0 }
+func (f fakeFile) Mode() os.FileMode  { return 0644 }
+func (f fakeFile) ModTime() time.Time { return time.Time{} }
+func (f fakeFile) IsDir() bool        { return false }
+func (f fakeFile) Sys() interface{}   { return nil }
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
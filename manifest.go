@@ -0,0 +1,246 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// manifest lists several merges run concurrently by one process, e.g. one
+// per product of a release. Jobs refer to a named profile for their options.
+// Shared profiles can be pulled in from other manifests with include.
+type manifest struct {
+	Include  []string           `json:"include,omitempty"`
+	Profiles map[string]profile `json:"profiles,omitempty"`
+	Jobs     []manifestJob      `json:"jobs,omitempty"`
+}
+
+// profile is a named set of options. A profile extending another one
+// inherits every option it does not set itself.
+type profile struct {
+	Extends string `json:"extends,omitempty"`
+	options
+}
+
+type manifestJob struct {
+	In      fileList `json:"in"`
+	Out     string   `json:"out"`
+	Profile string   `json:"profile,omitempty"`
+}
+
+// loadManifest reads a manifest and everything it includes. Relative paths
+// are resolved against the directory of the file that contains them.
+func loadManifest(filename string) (*manifest, error) {
+	return loadManifestFile(filename, nil)
+}
+
+func loadManifestFile(filename string, stack []string) (*manifest, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stack {
+		if f == abs {
+			return nil, fmt.Errorf("manifest %s includes itself", filename)
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var own manifest
+	if err := json.Unmarshal(stripJSONC(data), &own); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", filename, err)
+	}
+
+	dir := filepath.Dir(filename)
+	for name, p := range own.Profiles {
+		p.resolvePaths(dir)
+		own.Profiles[name] = p
+	}
+	for i := range own.Jobs {
+		for j, in := range own.Jobs[i].In {
+			if _, ok := remoteInputs(in); !ok {
+				own.Jobs[i].In[j] = resolvePath(dir, in)
+			}
+		}
+		own.Jobs[i].Out = resolvePath(dir, own.Jobs[i].Out)
+	}
+
+	// included files come first, so the including file wins on conflicts
+	m := &manifest{Profiles: map[string]profile{}}
+	for _, inc := range own.Include {
+		im, err := loadManifestFile(resolvePath(dir, inc), stack)
+		if err != nil {
+			return nil, err
+		}
+		for name, p := range im.Profiles {
+			m.Profiles[name] = p
+		}
+		m.Jobs = append(m.Jobs, im.Jobs...)
+	}
+	for name, p := range own.Profiles {
+		m.Profiles[name] = p
+	}
+	m.Jobs = append(m.Jobs, own.Jobs...)
+	return m, nil
+}
+
+func (p *profile) resolvePaths(dir string) {
+	for i, f := range p.OverrideFiles {
+		p.OverrideFiles[i] = resolvePath(dir, f)
+	}
+	p.LabelsFile = resolvePath(dir, p.LabelsFile)
+	p.WaiversFile = resolvePath(dir, p.WaiversFile)
+	p.PolicyFile = resolvePath(dir, p.PolicyFile)
+	p.LockFile = resolvePath(dir, p.LockFile)
+	p.HistoryDir = resolvePath(dir, p.HistoryDir)
+	p.PerSourceOut = resolvePath(dir, p.PerSourceOut)
+	p.AuditLog = resolvePath(dir, p.AuditLog)
+	p.Template = resolvePath(dir, p.Template)
+	p.NoticeLicenseTexts = resolvePath(dir, p.NoticeLicenseTexts)
+	for i, f := range p.ToolsFrom {
+		p.ToolsFrom[i] = resolvePath(dir, f)
+	}
+	for i, f := range p.BinariesFrom {
+		p.BinariesFrom[i] = resolvePath(dir, f)
+	}
+	for i, f := range p.ReplacesFrom {
+		p.ReplacesFrom[i] = resolvePath(dir, f)
+	}
+}
+
+// profileOptions returns the options of the named profile with everything
+// inherited through extends.
+func (m *manifest) profileOptions(name string, stack []string) (options, error) {
+	for _, n := range stack {
+		if n == name {
+			return options{}, fmt.Errorf("profile %q extends itself", name)
+		}
+	}
+	p, ok := m.Profiles[name]
+	if !ok {
+		return options{}, fmt.Errorf("unknown profile %q", name)
+	}
+	if p.Extends == "" {
+		return p.options, nil
+	}
+	base, err := m.profileOptions(p.Extends, append(stack, name))
+	if err != nil {
+		return options{}, err
+	}
+	return overlayOptions(base, p.options), nil
+}
+
+// overlayOptions returns base with every option set in delta replacing the
+// inherited value. Boolean options can only be turned on by delta.
+func overlayOptions(base, delta options) options {
+	out := reflect.ValueOf(&base).Elem()
+	d := reflect.ValueOf(delta)
+	for i := 0; i < d.NumField(); i++ {
+		if !out.Field(i).CanSet() {
+			continue
+		}
+		if f := d.Field(i); !reflect.DeepEqual(f.Interface(), reflect.Zero(f.Type()).Interface()) {
+			out.Field(i).Set(f)
+		}
+	}
+	return base
+}
+
+// jobOptions returns the options of every job: base, i.e. the flag defaults
+// and any flags given with --manifest, overlaid with the job's profile.
+func (m *manifest) jobOptions(base options) ([]options, error) {
+	if len(m.Jobs) == 0 {
+		return nil, errors.New("manifest has no jobs")
+	}
+	out := make([]options, 0, len(m.Jobs))
+	for i, job := range m.Jobs {
+		if len(job.In) == 0 || job.Out == "" {
+			return nil, fmt.Errorf("job %d: in and out are required", i)
+		}
+		o := base
+		if job.Profile != "" {
+			p, err := m.profileOptions(job.Profile, nil)
+			if err != nil {
+				return nil, fmt.Errorf("job %d: %v", i, err)
+			}
+			o = overlayOptions(base, p)
+		}
+		o.In = job.In
+		o.Out = job.Out
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func resolvePath(dir, p string) string {
+	if p == "" || p == stdio || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// runManifest runs all jobs of the manifest concurrently with shared
+// resources and reports the errors of all failed jobs.
+func runManifest(filename string, writeLock bool, res *resources) error {
+	m, err := loadManifest(filename)
+	if err != nil {
+		return err
+	}
+	jobs, err := m.jobOptions(opts)
+	if err != nil {
+		return fmt.Errorf("invalid manifest %s: %v", filename, err)
+	}
+
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		jobs[i].writeLock = writeLock
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = newMerger(jobs[i], res).run()
+		}(i)
+	}
+	wg.Wait()
+
+	// the most severe failure decides the exit code
+	var msgs []string
+	code := exitOK
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("job %d (%s): %v", i, jobs[i].Out, err))
+			if c := exitCode(err); c > code {
+				code = c
+			}
+		}
+	}
+	if len(msgs) > 0 {
+		return &exitError{code: code, err: errors.New(strings.Join(msgs, "; "))}
+	}
+	return nil
+}
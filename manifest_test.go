@@ -0,0 +1,102 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunManifestConcurrentJobs runs several jobs sharing resources at once;
+// run it with -race.
+func TestRunManifestConcurrentJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "in"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, license := range []string{"MIT", "Apache-2.0", "BSD-3-Clause"} {
+		fragment := fmt.Sprintf(`{"version": 1, "projects": [{"project": "example.com/m%d", "licenses": [{"type": %q, "confidence": 1}]}]}`, i, license)
+		if err := ioutil.WriteFile(filepath.Join(dir, "in", fmt.Sprintf("%d.json", i)), []byte(fragment), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const jobs = 8
+	var list []string
+	for i := 0; i < jobs; i++ {
+		profile := []string{"report", "yaml"}[i%2]
+		list = append(list, fmt.Sprintf(`{"in": "in", "out": "out%d", "profile": %q}`, i, profile))
+		if err := os.Mkdir(filepath.Join(dir, fmt.Sprintf("out%d", i)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := `{
+  "profiles": {
+    "report": {"writeReport": true, "keyBy": "vcs"},
+    "yaml": {"format": "yaml", "sortBy": "risk"}
+  },
+  "jobs": [` + strings.Join(list, ",") + `]
+}`
+	filename := filepath.Join(dir, "manifest.json")
+	if err := ioutil.WriteFile(filename, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// VCS roots come from a shared cache, so the jobs look them up
+	// concurrently without network access
+	cache := filepath.Join(dir, "vcs.json")
+	roots := `{
+  "example.com/m0": {"root": "github.com/example/m0"},
+  "example.com/m1": {"root": "github.com/example/m1"},
+  "example.com/m2": {"root": "github.com/example/m2"}
+}`
+	if err := ioutil.WriteFile(cache, []byte(roots), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { vcsCacheURL = old }(vcsCacheURL)
+	vcsCacheURL = cache
+
+	res, err := newResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runManifest(filename, false, res); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < jobs; i++ {
+		name := []string{"bom_report.json", "bom.yaml"}[i%2]
+		out := filepath.Join(dir, fmt.Sprintf("out%d", i))
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("job %d: %v", i, err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(out, bomFileName([]string{formatNative, formatYAML}[i%2])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "github.com/example/m2") {
+			t.Errorf("job %d: VCS root of example.com/m2 missing:\n%s", i, data)
+		}
+	}
+}
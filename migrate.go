@@ -0,0 +1,117 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// runMigrate rewrites legacy two-array BOM fragments into the envelope
+// format in place. Envelopes and plain arrays of projects are left alone.
+// Arguments are files or directories of fragments.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: bom-merger migrate FILE|DIR...")
+	}
+
+	files, err := listFiles(args)
+	if err != nil {
+		return err
+	}
+	for _, filename := range files {
+		migrated, err := migrateFile(filename)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			fmt.Printf("migrated %s\n", filename)
+		} else {
+			fmt.Printf("%s is already current\n", filename)
+		}
+	}
+	return nil
+}
+
+// listFiles returns the files named by args and the files of the
+// directories named by args, without descending into subdirectories.
+func listFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		entries, err := ioutil.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(arg, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func migrateFile(filename string) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	doc, err := parseBOM(filename, data)
+	if err != nil {
+		return false, err
+	}
+	if !doc.Legacy {
+		return false, nil
+	}
+
+	out, err := MarshalJson(doc)
+	if err != nil {
+		return false, err
+	}
+	// YAML fragments stay YAML
+	if isYAMLFile(filename) {
+		if out, err = jsonToYAML(out); err != nil {
+			return false, err
+		}
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	// write next to the original and rename, so a failure never leaves a
+	// truncated fragment behind
+	tmp := filename + ".migrate.tmp"
+	if err := ioutil.WriteFile(tmp, out, fi.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
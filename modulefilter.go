@@ -0,0 +1,100 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// compileModuleRegexps compiles the regular expressions given to a module
+// filter flag.
+func compileModuleRegexps(flagName string, exprs []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %q: %v", flagName, expr, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// validateModuleFilters rejects invalid regular expressions before any
+// input is read.
+func validateModuleFilters(opts options) error {
+	if _, err := compileModuleRegexps("filter-modules-regex", opts.FilterModulesRegex); err != nil {
+		return err
+	}
+	_, err := compileModuleRegexps("only-modules", opts.OnlyModules)
+	return err
+}
+
+// filterModules removes the projects whose module path starts with one of
+// --filter-modules or matches one of --filter-modules-regex. Projects
+// matching --only-modules are exempt from both, and if neither is given,
+// --only-modules alone removes every project it does not match.
+func (m *merger) filterModules() error {
+	regexps, err := compileModuleRegexps("filter-modules-regex", m.opts.FilterModulesRegex)
+	if err != nil {
+		return err
+	}
+	only, err := compileModuleRegexps("only-modules", m.opts.OnlyModules)
+	if err != nil {
+		return err
+	}
+	kept := func(p merge.Project) bool {
+		for _, re := range only {
+			if re.MatchString(p.Project) {
+				return true
+			}
+		}
+		return false
+	}
+	remove := func(flagName, rule string, match func(p merge.Project) bool) {
+		removed := m.lib.Filter(flagName+": "+rule, func(p merge.Project) bool {
+			return match(p) && !kept(p)
+		})
+		for _, p := range removed {
+			m.tracef(p.Project, "removed by --%s %s", flagName, rule)
+			m.auditf("filter", p.Project, "%s: %s", flagName, rule)
+		}
+	}
+
+	for _, module := range m.opts.FilterModules {
+		module := module
+		remove("filter-modules", module, func(p merge.Project) bool {
+			return strings.HasPrefix(p.Project, module)
+		})
+	}
+	for _, re := range regexps {
+		re := re
+		remove("filter-modules-regex", re.String(), func(p merge.Project) bool {
+			return re.MatchString(p.Project)
+		})
+	}
+	if len(only) > 0 && len(m.opts.FilterModules) == 0 && len(regexps) == 0 {
+		remove("only-modules", strings.Join(m.opts.OnlyModules, ","), func(p merge.Project) bool {
+			return true
+		})
+	}
+	return nil
+}
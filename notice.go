@@ -0,0 +1,106 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+const noticeRule = "--------------------------------------------------------------------------------"
+
+// writeNotice renders the merged BOM to dir as a plain text attribution
+// file, e.g. THIRD_PARTY_LICENSES, listing every project with its version,
+// source and license. If textDir is set, the full text of every license is
+// appended from <textDir>/<SPDX id>.txt, as laid out in the text directory
+// of the SPDX license-list-data repository.
+func (m *merger) writeNotice(name, textDir, dir string) error {
+	projects := m.bom.Projects()
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "THIRD-PARTY SOFTWARE NOTICES")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "This product includes the following %d third-party projects.\n", len(projects))
+	ids := map[string]bool{}
+	for _, p := range projects {
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, noticeRule)
+		fmt.Fprintln(&buf, versionedPath(p.Project, p.Version))
+		if p.VCS != "" {
+			fmt.Fprintf(&buf, "Source:  %s\n", vcsURL(p.VCS))
+		}
+		fmt.Fprintf(&buf, "License: %s\n", noticeLicense(p))
+		for _, lic := range p.Licenses {
+			ids[merge.CanonicalID(lic.Type)] = true
+			if lic.Exception != "" {
+				ids[merge.CanonicalID(lic.Exception)] = true
+			}
+		}
+	}
+
+	if textDir != "" && len(ids) > 0 {
+		sorted := make([]string, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		sort.Strings(sorted)
+
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, noticeRule)
+		fmt.Fprintln(&buf)
+		fmt.Fprintln(&buf, "LICENSE TEXTS")
+		for _, id := range sorted {
+			text, err := ioutil.ReadFile(filepath.Join(textDir, id+".txt"))
+			if os.IsNotExist(err) {
+				warnf("no license text for %s in %s", id, textDir)
+				continue
+			} else if err != nil {
+				return err
+			}
+			fmt.Fprintln(&buf)
+			fmt.Fprintln(&buf, noticeRule)
+			fmt.Fprintln(&buf, id)
+			fmt.Fprintln(&buf, noticeRule)
+			fmt.Fprintln(&buf)
+			fmt.Fprintln(&buf, strings.TrimRight(string(text), "\n"))
+		}
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+}
+
+// noticeLicense returns the license line of p: the SPDX expression of a
+// dual-licensed project, the detected licenses, or the license status if
+// none was detected.
+func noticeLicense(p merge.Project) string {
+	switch {
+	case p.LicenseExpression != "":
+		return p.LicenseExpression
+	case len(p.Licenses) > 0:
+		return licenseTypes(p)
+	case p.LicenseStatus != "":
+		return p.LicenseStatus
+	default:
+		return "NOASSERTION"
+	}
+}
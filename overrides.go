@@ -0,0 +1,261 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// fileList is the value of a repeatable flag naming files, such as --in and
+// --override-file. In manifests it is a single path or a list of paths.
+type fileList []string
+
+func (l *fileList) String() string { return strings.Join(*l, ",") }
+func (l *fileList) Type() string   { return "stringArray" }
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (l *fileList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = nil
+		if single != "" {
+			*l = fileList{single}
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// overrideFiles returns the override files in the order they are applied:
+// as given, with every directory replaced by the .json files in it in
+// lexical order.
+func overrideFiles(paths []string) ([]string, error) {
+	var out []string
+	for _, p := range paths {
+		if p == stdio {
+			out = append(out, p)
+			continue
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			out = append(out, p)
+			continue
+		}
+		entries, err := ioutil.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+				out = append(out, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	return out, nil
+}
+
+func runOverrides(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return errors.New("usage: bom-merger overrides prune --in=DIR --override-file=FILE [merge flags]")
+	}
+	return runPruneOverrides(args[1:])
+}
+
+// runPruneOverrides merges the inputs without overrides and removes, or
+// comments out, the overrides whose entry is now detected the same way.
+// The override file is edited in place, so its comments are kept.
+func runPruneOverrides(args []string) error {
+	fs := flag.NewFlagSet("overrides prune", flag.ExitOnError)
+	fs.AddFlagSet(flag.CommandLine)
+	commentOut := fs.Bool("comment-out", false, "Comment out unneeded overrides instead of removing them")
+	dryRun := fs.Bool("dry-run", false, "Only print the unneeded overrides")
+	_ = fs.Parse(args)
+	if len(opts.OverrideFiles) == 0 || (len(opts.In) == 0 && len(opts.Images) == 0) {
+		return errors.New("usage: bom-merger overrides prune --in=DIR --override-file=FILE [merge flags]")
+	}
+	if len(opts.OverrideFiles) > 1 {
+		return errors.New("overrides prune edits a single override file, --override-file can not be repeated")
+	}
+	if opts.OverrideFiles[0] == stdio {
+		return errors.New("overrides prune edits the override file in place and can not read it from stdin")
+	}
+	if fi, err := os.Stat(opts.OverrideFiles[0]); err == nil && fi.IsDir() {
+		return errors.New("overrides prune edits a single override file, not a directory")
+	}
+	overrideFile := opts.OverrideFiles[0]
+
+	data, err := ioutil.ReadFile(overrideFile)
+	if err != nil {
+		return err
+	}
+	elems, err := jsoncArrayElements(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse override file %s: %v", overrideFile, err)
+	}
+
+	res, err := newResources()
+	if err != nil {
+		return err
+	}
+	o := opts
+	o.OverrideFiles = nil
+	m := newMerger(o, res)
+	if err := m.merge(); err != nil {
+		return err
+	}
+
+	var prune [][2]int
+	for _, e := range elems {
+		var override merge.Project
+		if err := json.Unmarshal(stripJSONC(data[e[0]:e[1]]), &override); err != nil {
+			return fmt.Errorf("failed to parse override file %s: %v", overrideFile, err)
+		}
+		override = merge.NormalizeLicenses(override)
+		detected, ok := m.bom.Get(override.Project)
+		if !ok || !overrideMatches(override, detected) {
+			continue
+		}
+		fmt.Printf("%s: detected as %s\n", override.Project, formatLicenses(detected.Licenses))
+		prune = append(prune, e)
+	}
+	if len(prune) == 0 {
+		fmt.Fprintf(os.Stderr, "all overrides of %s are still needed\n", overrideFile)
+		return nil
+	}
+	if *dryRun {
+		return nil
+	}
+
+	out, err := pruneElements(data, prune, *commentOut)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(overrideFile)
+	if err != nil {
+		return err
+	}
+	tmp := overrideFile + ".prune.tmp"
+	if err := ioutil.WriteFile(tmp, out, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, overrideFile); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "pruned %d of %d overrides from %s\n", len(prune), len(elems), overrideFile)
+	return nil
+}
+
+// overrideMatches reports whether the natural detection decides everything
+// the override does: the same license types and exceptions and, if the
+// override sets one, the same VCS root.
+func overrideMatches(override, detected merge.Project) bool {
+	if len(override.Licenses) != len(detected.Licenses) {
+		return false
+	}
+	for i := range override.Licenses {
+		if override.Licenses[i].Type != detected.Licenses[i].Type || override.Licenses[i].Exception != detected.Licenses[i].Exception {
+			return false
+		}
+	}
+	return override.VCS == "" || override.VCS == detected.VCS
+}
+
+// pruneElements removes the given element ranges of a JSONC array, with
+// their comma and the rest of their lines, or wraps them in comments.
+func pruneElements(data []byte, elems [][2]int, commentOut bool) ([]byte, error) {
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range elems {
+		start, end := e[0], skipJSONCSpace(data, e[1])
+		if end < len(data) && data[end] == ',' {
+			end++
+		} else {
+			end = e[1]
+		}
+		// take whole lines if the element is alone on them
+		lineStart := start
+		for lineStart > 0 && (data[lineStart-1] == ' ' || data[lineStart-1] == '\t') {
+			lineStart--
+		}
+		if lineStart == 0 || data[lineStart-1] == '\n' {
+			lineEnd := end
+			for lineEnd < len(data) && (data[lineEnd] == ' ' || data[lineEnd] == '\t' || data[lineEnd] == '\r') {
+				lineEnd++
+			}
+			if lineEnd < len(data) && data[lineEnd] == '\n' {
+				start, end = lineStart, lineEnd+1
+			}
+		}
+
+		buf.Write(data[last:start])
+		if commentOut {
+			region := data[start:end]
+			if bytes.Contains(region, []byte("*/")) {
+				return nil, fmt.Errorf("cannot comment out override containing */: %s", bytes.TrimSpace(region))
+			}
+			buf.WriteString("/* not needed, detected the same way:\n")
+			buf.Write(region)
+			buf.WriteString("*/\n")
+		}
+		last = end
+	}
+	buf.Write(data[last:])
+	return buf.Bytes(), nil
+}
+
+// skipJSONCSpace returns the offset of the first byte at or after i that is
+// neither whitespace nor part of a comment.
+func skipJSONCSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case isJSONSpace(data[i]):
+			i++
+		case bytes.HasPrefix(data[i:], []byte("//")):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case bytes.HasPrefix(data[i:], []byte("/*")):
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(data)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
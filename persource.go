@@ -0,0 +1,75 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// writePerSource writes every input fragment back to dir in the envelope
+// format, with the merged entries it supplied, so teams can commit their
+// corrected slice. Entries removed by filters are left out.
+func (m *merger) writePerSource(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	docs := map[string]*merge.Document{}
+	for _, source := range m.lib.Inputs {
+		docs[source] = &merge.Document{Version: merge.EnvelopeVersion, Projects: []merge.Project{}}
+	}
+	for _, reg := range []*merge.Registry{m.bom, m.review} {
+		for _, p := range reg.Projects() {
+			for _, source := range m.lib.Sources[p.Project] {
+				docs[source].Projects = append(docs[source].Projects, p)
+			}
+		}
+	}
+	for _, p := range m.errors.Projects() {
+		for _, rec := range p.Errors {
+			for _, source := range rec.Sources {
+				if doc, ok := docs[source]; ok && !hasProject(doc.Errors, p.Project) {
+					doc.Errors = append(doc.Errors, p)
+				}
+			}
+		}
+	}
+
+	for _, source := range m.lib.Inputs {
+		data, err := marshalOutput(docs[source], m.opts.Compact)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(source)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasProject(projects []merge.Project, project string) bool {
+	for _, p := range projects {
+		if p.Project == project {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"strings"
+)
+
+// builtinAliases maps license names commonly found in inputs, keyed by
+// aliasKey, to SPDX identifiers.
+var builtinAliases = map[string]string{
+	"apache 2":                        "Apache-2.0",
+	"apache 2.0":                      "Apache-2.0",
+	"apache license 2.0":              "Apache-2.0",
+	"apache license version 2.0":      "Apache-2.0",
+	"apache software license 2.0":     "Apache-2.0",
+	"apache-2":                        "Apache-2.0",
+	"apache2":                         "Apache-2.0",
+	"asl 2.0":                         "Apache-2.0",
+	"bsd":                             "BSD-3-Clause",
+	"bsd 2-clause":                    "BSD-2-Clause",
+	"bsd 3-clause":                    "BSD-3-Clause",
+	"bsd-2":                           "BSD-2-Clause",
+	"bsd-3":                           "BSD-3-Clause",
+	"new bsd":                         "BSD-3-Clause",
+	"simplified bsd":                  "BSD-2-Clause",
+	"eclipse public license 2.0":      "EPL-2.0",
+	"gplv2":                           "GPL-2.0",
+	"gplv3":                           "GPL-3.0",
+	"gnu general public license v2.0": "GPL-2.0",
+	"gnu general public license v3.0": "GPL-3.0",
+	"isc license":                     "ISC",
+	"lgplv2.1":                        "LGPL-2.1",
+	"lgplv3":                          "LGPL-3.0",
+	"mit license":                     "MIT",
+	"the mit license":                 "MIT",
+	"mozilla public license 2.0":      "MPL-2.0",
+	"mpl 2.0":                         "MPL-2.0",
+	"the unlicense":                   "Unlicense",
+	"the apache software license version 2.0": "Apache-2.0",
+}
+
+// licenseAliases holds the built-in aliases and those added with
+// AddLicenseAliases. It is guarded by licenseListMu.
+var licenseAliases = map[string]string{}
+
+func init() {
+	for name, id := range builtinAliases {
+		licenseAliases[name] = id
+	}
+}
+
+// aliasKey folds the spellings of a license name that differ only in case,
+// commas and whitespace, e.g. "Apache License, Version 2.0".
+func aliasKey(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.Replace(name, ",", " ", -1))), " ")
+}
+
+// AddLicenseAliases adds license names mapped to the SPDX identifiers they
+// stand for, replacing built-in aliases of the same name.
+func AddLicenseAliases(aliases map[string]string) {
+	licenseListMu.Lock()
+	defer licenseListMu.Unlock()
+	for name, id := range aliases {
+		licenseAliases[aliasKey(name)] = id
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"strings"
+)
+
+// License statuses of projects without licenses, with the meaning of the
+// SPDX special values.
+const (
+	// LicenseNoAssertion means no license could be determined.
+	LicenseNoAssertion = "NOASSERTION"
+	// LicenseNone means the project is known to have no license.
+	LicenseNone = "NONE"
+)
+
+// AnnotateLicenseStatus moves NOASSERTION and NONE entries from the
+// licenses of p to LicenseStatus, and sets LicenseStatus to NOASSERTION if
+// p has no license otherwise. It is cleared for projects with licenses.
+func AnnotateLicenseStatus(p Project) Project {
+	var licenses []License
+	status := p.LicenseStatus
+	for _, lic := range p.Licenses {
+		switch strings.ToUpper(strings.TrimSpace(lic.Type)) {
+		case LicenseNoAssertion:
+			if status == "" {
+				status = LicenseNoAssertion
+			}
+		case LicenseNone:
+			status = LicenseNone
+		default:
+			licenses = append(licenses, lic)
+		}
+	}
+	if len(licenses) != len(p.Licenses) {
+		p.Licenses = licenses
+	}
+	switch {
+	case len(p.Licenses) > 0:
+		p.LicenseStatus = ""
+	case status == LicenseNone:
+		p.LicenseStatus = LicenseNone
+	default:
+		p.LicenseStatus = LicenseNoAssertion
+	}
+	return p
+}
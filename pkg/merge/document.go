@@ -0,0 +1,229 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EnvelopeVersion is the version of the envelope format written and read.
+const EnvelopeVersion = 1
+
+// Document is the envelope format of a BOM fragment. It replaces the
+// legacy format of two concatenated JSON arrays, where the first array held
+// the projects and the second one the errors.
+type Document struct {
+	Version  int       `json:"version"`
+	Projects []Project `json:"projects"`
+	Errors   []Project `json:"errors,omitempty"`
+
+	// Legacy is set if the document was read from the two-array format. A
+	// single array of projects is read as a current document.
+	Legacy bool `json:"-"`
+}
+
+// DecodeError reports where in an input file decoding failed.
+type DecodeError struct {
+	File     string
+	Offset   int64
+	Line     int
+	Column   int
+	Document int
+	Section  string // projects or errors for envelope documents
+	Entry    int    // -1 if the error is not inside a project entry
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	where := fmt.Sprintf("document %d", e.Document)
+	if e.Section != "" {
+		where = e.Section
+	}
+	if e.Entry >= 0 {
+		where += fmt.Sprintf(", entry %d", e.Entry)
+	}
+	return fmt.Sprintf("%s:%d:%d (offset %d, %s): %v", e.File, e.Line, e.Column, e.Offset, where, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newDecodeError(filename string, data []byte, offset int64, doc int, section string, entry int, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		// offset of the type error is relative to the start of the entry
+		offset = skipSeparators(data, offset) + e.Offset
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, col := 1, 1
+	for _, c := range data[:offset] {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &DecodeError{
+		File:     filename,
+		Offset:   offset,
+		Line:     line,
+		Column:   col,
+		Document: doc,
+		Section:  section,
+		Entry:    entry,
+		Err:      err,
+	}
+}
+
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && (isJSONSpace(data[offset]) || data[offset] == ',' || data[offset] == ':') {
+		offset++
+	}
+	return offset
+}
+
+// ParseDocument decodes a BOM fragment in the envelope format, as a plain
+// array of projects or in the legacy two-array format. UTF-16 input and
+// byte-order marks are accepted; offsets in decode errors refer to the
+// input converted to UTF-8.
+func ParseDocument(filename string, data []byte) (*Document, error) {
+	data, err := ToUTF8(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	tok, err := decoder.Token()
+	if err == io.EOF {
+		return &Document{Version: EnvelopeVersion}, nil
+	}
+	if err != nil {
+		return nil, newDecodeError(filename, data, decoder.InputOffset(), 0, "", -1, err)
+	}
+	if tok == json.Delim('{') {
+		return parseEnvelope(filename, data, decoder)
+	}
+
+	doc := &Document{Version: EnvelopeVersion}
+	for i := 0; ; i++ {
+		if i > 0 {
+			tok, err = decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, newDecodeError(filename, data, decoder.InputOffset(), i, "", -1, err)
+			}
+		}
+		if tok != json.Delim('[') {
+			return nil, newDecodeError(filename, data, decoder.InputOffset(), i, "", -1, fmt.Errorf("expected a JSON array, found %v", tok))
+		}
+		entries, err := decodeEntries(filename, data, decoder, i, "")
+		if err != nil {
+			return nil, err
+		}
+		// the first document lists the projects, any later one errors
+		if i == 0 {
+			doc.Projects = entries
+		} else {
+			doc.Legacy = true
+			doc.Errors = append(doc.Errors, entries...)
+		}
+	}
+	return doc, nil
+}
+
+func parseEnvelope(filename string, data []byte, decoder *json.Decoder) (*Document, error) {
+	doc := &Document{}
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, newDecodeError(filename, data, decoder.InputOffset(), 0, "", -1, err)
+		}
+		key, _ := tok.(string)
+		offset := decoder.InputOffset()
+		switch key {
+		case "version":
+			if err := decoder.Decode(&doc.Version); err != nil {
+				return nil, newDecodeError(filename, data, offset, 0, key, -1, err)
+			}
+		case "projects", "errors":
+			tok, err := decoder.Token()
+			if err != nil {
+				return nil, newDecodeError(filename, data, decoder.InputOffset(), 0, key, -1, err)
+			}
+			if tok == nil {
+				continue
+			}
+			if tok != json.Delim('[') {
+				return nil, newDecodeError(filename, data, decoder.InputOffset(), 0, key, -1, fmt.Errorf("expected a JSON array, found %v", tok))
+			}
+			entries, err := decodeEntries(filename, data, decoder, 0, key)
+			if err != nil {
+				return nil, err
+			}
+			if key == "projects" {
+				doc.Projects = entries
+			} else {
+				doc.Errors = entries
+			}
+		default:
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, newDecodeError(filename, data, offset, 0, key, -1, err)
+			}
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, newDecodeError(filename, data, decoder.InputOffset(), 0, "", -1, err)
+	}
+	if doc.Version != EnvelopeVersion {
+		return nil, newDecodeError(filename, data, 0, 0, "", -1, fmt.Errorf("unsupported envelope version %d", doc.Version))
+	}
+	return doc, nil
+}
+
+// decodeEntries decodes the project entries of an array whose opening
+// bracket was already consumed.
+func decodeEntries(filename string, data []byte, decoder *json.Decoder, doc int, section string) ([]Project, error) {
+	var entries []Project
+	for i := 0; decoder.More(); i++ {
+		offset := decoder.InputOffset()
+		var project Project
+		if err := decoder.Decode(&project); err != nil {
+			return nil, newDecodeError(filename, data, offset, doc, section, i, err)
+		}
+		entries = append(entries, project)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, newDecodeError(filename, data, decoder.InputOffset(), doc, section, -1, err)
+	}
+	return entries, nil
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,48 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"testing"
+)
+
+func TestParseDocumentLegacy(t *testing.T) {
+	cases := []struct {
+		name     string
+		in       string
+		legacy   bool
+		projects int
+		errors   int
+	}{
+		{"empty", ``, false, 0, 0},
+		{"envelope", `{"version": 1, "projects": [{"project": "example.com/x"}]}`, false, 1, 0},
+		{"array", `[{"project": "example.com/x"}, {"project": "example.com/y"}]`, false, 2, 0},
+		{"two arrays", `[{"project": "example.com/x"}] [{"project": "example.com/y"}]`, true, 1, 1},
+		{"empty errors", `[{"project": "example.com/x"}] []`, true, 1, 0},
+	}
+	for _, c := range cases {
+		doc, err := ParseDocument("test.json", []byte(c.in))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if doc.Legacy != c.legacy || len(doc.Projects) != c.projects || len(doc.Errors) != c.errors {
+			t.Errorf("%s: legacy=%v projects=%d errors=%d, want legacy=%v projects=%d errors=%d",
+				c.name, doc.Legacy, len(doc.Projects), len(doc.Errors), c.legacy, c.projects, c.errors)
+		}
+	}
+}
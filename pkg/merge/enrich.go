@@ -0,0 +1,112 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Enricher is a custom pipeline stage run on every BOM entry after VCS
+// detection and before the outputs are written, e.g. to look up license
+// decisions in an internal database.
+type Enricher interface {
+	// Name identifies the enricher in errors and warnings.
+	Name() string
+	// Enrich returns p with the enrichment applied. It must not change
+	// the project path.
+	Enrich(p Project) (Project, error)
+}
+
+// ErrorPolicy decides what happens when an enricher fails for an entry.
+type ErrorPolicy string
+
+const (
+	// FailOnError aborts the merge.
+	FailOnError ErrorPolicy = "fail"
+	// KeepOnError keeps the entry unchanged and reports the error as a
+	// warning.
+	KeepOnError ErrorPolicy = "keep"
+)
+
+// EnricherOptions control when an enricher runs and how its errors are
+// handled. Enrichers run by ascending Order, then in registration order.
+type EnricherOptions struct {
+	Order   int
+	OnError ErrorPolicy
+}
+
+type registeredEnricher struct {
+	Enricher
+	EnricherOptions
+	seq int
+}
+
+var (
+	enrichersMu sync.Mutex
+	enrichers   []registeredEnricher
+)
+
+// RegisterEnricher adds e to the stages run by every merge of the process.
+// It is meant to be called from init functions of embedding programs.
+func RegisterEnricher(e Enricher, opts EnricherOptions) {
+	if opts.OnError == "" {
+		opts.OnError = FailOnError
+	}
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	enrichers = append(enrichers, registeredEnricher{Enricher: e, EnricherOptions: opts, seq: len(enrichers)})
+	sort.SliceStable(enrichers, func(i, j int) bool {
+		if enrichers[i].Order != enrichers[j].Order {
+			return enrichers[i].Order < enrichers[j].Order
+		}
+		return enrichers[i].seq < enrichers[j].seq
+	})
+}
+
+// Enrich runs the registered enrichers on every entry of r without
+// SkipEnrichment. Errors of enrichers with KeepOnError are passed to warn.
+func Enrich(r *Registry, warn func(err error)) error {
+	enrichersMu.Lock()
+	stages := make([]registeredEnricher, len(enrichers))
+	copy(stages, enrichers)
+	enrichersMu.Unlock()
+
+	for _, e := range stages {
+		err := r.Each(func(p Project) error {
+			if p.SkipEnrichment {
+				return nil
+			}
+			out, err := e.Enrich(p)
+			if err != nil {
+				err = fmt.Errorf("enricher %s failed for %s: %v", e.Name(), p.Project, err)
+				if e.OnError == KeepOnError {
+					warn(err)
+					return nil
+				}
+				return err
+			}
+			r.Set(out)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
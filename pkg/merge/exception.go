@@ -0,0 +1,109 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"strings"
+)
+
+// deprecatedExceptionIDs maps the deprecated SPDX identifiers that combine a
+// license with an exception to the license and exception they stand for.
+var deprecatedExceptionIDs = map[string][2]string{
+	"gpl-2.0-with-autoconf-exception":  {"GPL-2.0", "Autoconf-exception-2.0"},
+	"gpl-2.0-with-bison-exception":     {"GPL-2.0", "Bison-exception-2.2"},
+	"gpl-2.0-with-classpath-exception": {"GPL-2.0", "Classpath-exception-2.0"},
+	"gpl-2.0-with-font-exception":      {"GPL-2.0", "Font-exception-2.0"},
+	"gpl-2.0-with-gcc-exception":       {"GPL-2.0", "GCC-exception-2.0"},
+	"gpl-3.0-with-autoconf-exception":  {"GPL-3.0", "Autoconf-exception-3.0"},
+	"gpl-3.0-with-gcc-exception":       {"GPL-3.0", "GCC-exception-3.1"},
+}
+
+var (
+	permissiveExceptionIDs = []string{
+		"Autoconf-exception-2.0", "Autoconf-exception-3.0", "Bison-exception-2.2", "LLVM-exception",
+	}
+	weakCopyleftExceptionIDs = []string{
+		"Classpath-exception-2.0", "Font-exception-2.0", "GCC-exception-2.0", "GCC-exception-3.1",
+		"Linux-syscall-note", "Universal-FOSS-exception-1.0",
+	}
+)
+
+// exceptionCategories caps the category of a license by the exception
+// granted with it, e.g. the Classpath exception allows linking without
+// the copyleft obligations of the GPL.
+var exceptionCategories = map[string]LicenseCategory{}
+
+func init() {
+	for category, ids := range map[LicenseCategory][]string{
+		CategoryPermissive:   permissiveExceptionIDs,
+		CategoryWeakCopyleft: weakCopyleftExceptionIDs,
+	} {
+		for _, id := range ids {
+			exceptionCategories[strings.ToLower(id)] = category
+		}
+	}
+}
+
+// SplitException separates the exception from a license identifier, either
+// written as an SPDX expression "GPL-2.0 WITH Classpath-exception-2.0" or
+// as a deprecated combined identifier such as
+// "GPL-2.0-with-classpath-exception".
+func SplitException(licenseType string) (string, string) {
+	t := strings.TrimSpace(licenseType)
+	if ids, ok := deprecatedExceptionIDs[strings.ToLower(t)]; ok {
+		return ids[0], ids[1]
+	}
+	fields := strings.Fields(t)
+	if len(fields) == 3 && strings.EqualFold(fields[1], "WITH") {
+		return fields[0], fields[2]
+	}
+	return t, ""
+}
+
+// NormalizeLicenses moves exceptions spelled into the license types of p to
+// the Exception field, spells identifiers as in the SPDX license list and
+// sets the license status of projects without licenses.
+func NormalizeLicenses(p Project) Project {
+	if len(p.Licenses) == 0 {
+		return AnnotateLicenseStatus(p)
+	}
+	licenses := make([]License, len(p.Licenses))
+	for i, lic := range p.Licenses {
+		if lic.Exception == "" {
+			lic.Type, lic.Exception = SplitException(lic.Type)
+		}
+		lic.Type = CanonicalID(lic.Type)
+		if lic.Exception != "" {
+			lic.Exception = CanonicalID(lic.Exception)
+		}
+		licenses[i] = lic
+	}
+	p.Licenses = licenses
+	return AnnotateLicenseStatus(p)
+}
+
+// categoryOfLicense returns the category of lic, relaxed by its exception.
+func categoryOfLicense(lic License) LicenseCategory {
+	category := CategoryOf(lic.Type)
+	if lic.Exception == "" || category == CategoryUnknown {
+		return category
+	}
+	if c, ok := exceptionCategories[strings.ToLower(lic.Exception)]; ok && categoryRank[c] < categoryRank[category] {
+		return c
+	}
+	return category
+}
@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+)
+
+// KeyFunc derives the registry key of a project.
+type KeyFunc func(p Project) string
+
+// KeyByProject keys entries by module path.
+func KeyByProject(p Project) string {
+	return p.Project
+}
+
+// KeyByVCS keys entries by VCS root, combining all modules of a repository.
+// Entries without a VCS root are keyed by module path.
+func KeyByVCS(p Project) string {
+	if p.VCS != "" {
+		return p.VCS
+	}
+	return p.Project
+}
+
+// KeyByPURL keys entries by package URL.
+func KeyByPURL(p Project) string {
+	return PURL(p)
+}
+
+// KeyByPathVersion keys entries by module path and version.
+func KeyByPathVersion(p Project) string {
+	if p.Version == "" {
+		return p.Project
+	}
+	return p.Project + "@" + p.Version
+}
+
+// PURL returns the package URL of a Go module, e.g.
+// pkg:golang/github.com/spf13/pflag@v1.0.5.
+func PURL(p Project) string {
+	purl := "pkg:golang/" + p.Project
+	if p.Version != "" {
+		purl += "@" + p.Version
+	}
+	return purl
+}
+
+// KeyFuncs maps the names accepted by --key-by to key functions.
+var KeyFuncs = map[string]KeyFunc{
+	"module":       KeyByProject,
+	"vcs":          KeyByVCS,
+	"purl":         KeyByPURL,
+	"path+version": KeyByPathVersion,
+}
+
+func KeyFuncFor(name string) (KeyFunc, error) {
+	if fn, ok := KeyFuncs[name]; ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unknown key %q, must be one of module, vcs, purl or path+version", name)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// BuiltinLicenseList is the version reported for the license and exception
+// identifiers compiled into the binary.
+const BuiltinLicenseList = "builtin"
+
+var (
+	licenseListMu      sync.RWMutex
+	licenseListVersion = BuiltinLicenseList
+	// canonicalIDs maps lower case SPDX identifiers to their spelling in
+	// the license list.
+	canonicalIDs = map[string]string{}
+)
+
+func init() {
+	for _, ids := range [][]string{permissiveIDs, weakCopyleftIDs, copyleftIDs, permissiveExceptionIDs, weakCopyleftExceptionIDs} {
+		for _, id := range ids {
+			canonicalIDs[strings.ToLower(id)] = id
+		}
+	}
+}
+
+// spdxLicenseList is the subset of licenses.json and exceptions.json of the
+// SPDX license-list-data repository used for normalization.
+type spdxLicenseList struct {
+	Version  string `json:"licenseListVersion"`
+	Licenses []struct {
+		ID string `json:"licenseId"`
+	} `json:"licenses"`
+	Exceptions []struct {
+		ID string `json:"licenseExceptionId"`
+	} `json:"exceptions"`
+}
+
+// LoadLicenseList adds the identifiers of an SPDX licenses.json or
+// exceptions.json document to the known identifiers and returns its
+// license list version.
+func LoadLicenseList(data []byte) (string, error) {
+	var list spdxLicenseList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return "", err
+	}
+	licenseListMu.Lock()
+	defer licenseListMu.Unlock()
+	for _, l := range list.Licenses {
+		canonicalIDs[strings.ToLower(l.ID)] = l.ID
+	}
+	for _, e := range list.Exceptions {
+		canonicalIDs[strings.ToLower(e.ID)] = e.ID
+	}
+	if list.Version != "" {
+		licenseListVersion = list.Version
+	}
+	return licenseListVersion, nil
+}
+
+// LicenseListVersion returns the version of the loaded license list.
+func LicenseListVersion() string {
+	licenseListMu.RLock()
+	defer licenseListMu.RUnlock()
+	return licenseListVersion
+}
+
+// IsListed reports whether id is a license or exception identifier of the
+// SPDX license list, ignoring case.
+func IsListed(id string) bool {
+	licenseListMu.RLock()
+	defer licenseListMu.RUnlock()
+	_, ok := canonicalIDs[strings.ToLower(id)]
+	return ok
+}
+
+// CanonicalID returns the spelling of an SPDX license or exception
+// identifier in the license list, e.g. Apache-2.0 for apache-2.0, or the
+// identifier a license name is an alias of, e.g. Apache-2.0 for "Apache
+// License, Version 2.0". Unknown identifiers are returned unchanged.
+func CanonicalID(id string) string {
+	licenseListMu.RLock()
+	defer licenseListMu.RUnlock()
+	if c, ok := canonicalIDs[strings.ToLower(id)]; ok {
+		return c
+	}
+	if a, ok := licenseAliases[aliasKey(id)]; ok {
+		if c, ok := canonicalIDs[strings.ToLower(a)]; ok {
+			return c
+		}
+		return a
+	}
+	return id
+}
@@ -0,0 +1,270 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Merger merges BOM fragments into one BOM. It implements the core of the
+// bom-merger command, for use from other programs:
+//
+//	m := merge.NewMerger()
+//	if err := m.LoadDir("fragments"); err != nil { ... }
+//	m.Cleanup()
+//	if err := m.ApplyOverrides(overrides); err != nil { ... }
+//	if err := m.DetectVCS(nil); err != nil { ... }
+//	if err := m.Write("out"); err != nil { ... }
+type Merger struct {
+	// BOM holds the merged projects, Errors the projects whose license
+	// detection failed and Filtered the projects removed by Filter.
+	BOM      *Registry
+	Errors   *Registry
+	Filtered *Registry
+
+	// Sources lists the fragments that supplied each project, Inputs all
+	// fragments loaded.
+	Sources map[string][]string
+	Inputs  []string
+
+	// MultiLicenseThreshold makes Cleanup keep every license detected with
+	// at least this confidence instead of only the best one, for projects
+	// that are genuinely dual-licensed.
+	MultiLicenseThreshold float64
+
+	// VersionConflict decides what Add does with a project an earlier
+	// fragment supplied in another version. By default the entry of the
+	// later fragment replaces it.
+	VersionConflict VersionStrategy
+}
+
+// VersionStrategy resolves a project supplied in different versions by
+// several fragments.
+type VersionStrategy string
+
+const (
+	// HighestVersion keeps the entry with the highest version.
+	HighestVersion VersionStrategy = "highest"
+	// KeepAllVersions keeps an entry per version. It requires BOM and
+	// Filtered to be keyed by KeyByPathVersion.
+	KeepAllVersions VersionStrategy = "keep-all"
+	// FailOnVersionConflict makes Add return an error.
+	FailOnVersionConflict VersionStrategy = "error"
+)
+
+// ParseVersionStrategy returns the strategy named by --version-conflict.
+func ParseVersionStrategy(name string) (VersionStrategy, error) {
+	switch s := VersionStrategy(name); s {
+	case "", HighestVersion, KeepAllVersions, FailOnVersionConflict:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid version conflict strategy %q, must be highest, keep-all or error", name)
+}
+
+// NewMerger returns a Merger without any project.
+func NewMerger() *Merger {
+	return &Merger{
+		BOM:      NewRegistry(),
+		Errors:   NewRegistry(),
+		Filtered: NewRegistry(),
+		Sources:  map[string][]string{},
+	}
+}
+
+// LoadDir loads every file of dir, in order of name, as a BOM fragment.
+func (m *Merger) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			if err := m.LoadFile(filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadFile loads a BOM fragment in the envelope or legacy format.
+func (m *Merger) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	doc, err := ParseDocument(filename, data)
+	if err != nil {
+		return err
+	}
+	return m.Add(filename, doc)
+}
+
+// Add merges the projects and errors of doc, read from source. A project
+// replaces the entry of an earlier fragment, unless they differ in version
+// and VersionConflict says otherwise.
+func (m *Merger) Add(source string, doc *Document) error {
+	for _, p := range doc.Projects {
+		m.Sources[p.Project] = append(m.Sources[p.Project], source)
+		if d, ok := m.BOM.Get(m.BOM.KeyOf(p)); ok && d.Version != "" && p.Version != "" && d.Version != p.Version {
+			switch m.VersionConflict {
+			case FailOnVersionConflict:
+				return fmt.Errorf("%s supplies %s@%s, but an earlier fragment supplied version %s", source, p.Project, p.Version, d.Version)
+			case HighestVersion:
+				if CompareVersions(p.Version, d.Version) < 0 {
+					continue
+				}
+			}
+		}
+		m.BOM.Set(NormalizeLicenses(p))
+	}
+	m.Inputs = append(m.Inputs, source)
+	for _, p := range doc.Errors {
+		m.Errors.RecordError(p, source)
+	}
+	return nil
+}
+
+// Cleanup keeps only the license detected with the highest confidence of
+// every project, or with MultiLicenseThreshold set, all licenses above the
+// threshold as a choice between them.
+func (m *Merger) Cleanup() {
+	_ = m.BOM.Each(func(p Project) error {
+		if kept := m.multiLicenses(p); len(kept) > 1 {
+			p.Licenses = kept
+			p.LicenseExpression = OrExpression(kept)
+		} else if len(p.Licenses) > 1 {
+			var score float64 = 0
+			var idx int
+
+			for i, lic := range p.Licenses {
+				if lic.Confidence > score {
+					score = lic.Confidence
+					idx = i
+				}
+			}
+			p.Licenses = []License{p.Licenses[idx]}
+		}
+		m.BOM.Set(p)
+		return nil
+	})
+}
+
+// multiLicenses returns the distinct licenses of p detected with at least
+// MultiLicenseThreshold, best first.
+func (m *Merger) multiLicenses(p Project) []License {
+	if m.MultiLicenseThreshold <= 0 {
+		return nil
+	}
+	licenses := append([]License(nil), p.Licenses...)
+	sort.SliceStable(licenses, func(i, j int) bool {
+		return licenses[i].Confidence > licenses[j].Confidence
+	})
+	var kept []License
+	seen := map[License]bool{}
+	for _, lic := range licenses {
+		key := License{Type: strings.ToLower(lic.Type), Exception: strings.ToLower(lic.Exception)}
+		if lic.Confidence >= m.MultiLicenseThreshold && !seen[key] {
+			seen[key] = true
+			kept = append(kept, lic)
+		}
+	}
+	return kept
+}
+
+// OrExpression returns the SPDX expression offering the choice between
+// licenses, e.g. "MIT OR Apache-2.0".
+func OrExpression(licenses []License) string {
+	parts := make([]string, len(licenses))
+	for i, lic := range licenses {
+		parts[i] = lic.Type
+		if lic.Exception != "" {
+			parts[i] += " WITH " + lic.Exception
+		}
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// Filter moves the projects matching rule to Filtered, recording rule as
+// the reason, and returns them.
+func (m *Merger) Filter(rule string, match func(p Project) bool) []Project {
+	var removed []Project
+	_ = m.BOM.Each(func(p Project) error {
+		if match(p) {
+			p.FilteredBy = rule
+			m.Filtered.Set(p)
+			m.BOM.Remove(p)
+			removed = append(removed, p)
+		}
+		return nil
+	})
+	return removed
+}
+
+// ApplyOverrides replaces the licenses of the projects listed in overrides,
+// by module path or pattern as described for Overrides. Overrides for
+// projects not in the BOM are ignored.
+func (m *Merger) ApplyOverrides(overrides []Project) error {
+	normalized := make([]Project, len(overrides))
+	for i, p := range overrides {
+		normalized[i] = NormalizeLicenses(p)
+	}
+	o, err := NewOverrides(normalized)
+	if err != nil {
+		return err
+	}
+	m.ApplyOverrideSet(o)
+	return nil
+}
+
+// ApplyOverrideSet replaces the licenses of the entries of the BOM that
+// have an override in o.
+func (m *Merger) ApplyOverrideSet(o *Overrides) {
+	m.BOM.applyOverrides(o)
+}
+
+// DetectVCS sets the VCS root of the merged and the error entries. A nil
+// resolve uses DetectVCSRoot.
+func (m *Merger) DetectVCS(resolve VCSResolver) error {
+	if err := SetVCS(m.BOM, resolve); err != nil {
+		return err
+	}
+	return SetVCS(m.Errors, resolve)
+}
+
+// Write writes bom.json and bom_error.json to dir.
+func (m *Merger) Write(dir string) error {
+	for name, reg := range map[string]*Registry{"bom.json": m.BOM, "bom_error.json": m.Errors} {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reg.Projects()); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexpOverridePrefix marks the project of an override entry as a regular
+// expression, e.g. "re:^github\.com/myorg/".
+const RegexpOverridePrefix = "re:"
+
+// Overrides holds the entries of an override file. The project of an entry
+// is a module path, a glob such as github.com/myorg/*, or a regular
+// expression prefixed with RegexpOverridePrefix. Globs use the syntax of
+// path.Match and, like GOPRIVATE, match the leading elements of a module
+// path, so github.com/myorg/* also matches github.com/myorg/repo/v2. The
+// zero value holds no overrides.
+type Overrides struct {
+	exact    *Registry
+	patterns []overridePattern
+}
+
+type overridePattern struct {
+	match func(project string) bool
+	entry Project
+}
+
+// IsOverridePattern reports whether the project of an override entry is a
+// glob or regular expression rather than a module path.
+func IsOverridePattern(project string) bool {
+	return strings.HasPrefix(project, RegexpOverridePrefix) || strings.ContainsAny(project, "*?[")
+}
+
+// NewOverrides returns the overrides of entries. It fails on invalid
+// patterns.
+func NewOverrides(entries []Project) (*Overrides, error) {
+	o := &Overrides{exact: NewRegistry()}
+	for _, p := range entries {
+		switch {
+		case strings.HasPrefix(p.Project, RegexpOverridePrefix):
+			re, err := regexp.Compile(strings.TrimPrefix(p.Project, RegexpOverridePrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid override pattern %q: %v", p.Project, err)
+			}
+			o.patterns = append(o.patterns, overridePattern{re.MatchString, p})
+		case IsOverridePattern(p.Project):
+			glob := p.Project
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid override pattern %q: %v", glob, err)
+			}
+			o.patterns = append(o.patterns, overridePattern{func(project string) bool {
+				return matchPathPrefix(glob, project)
+			}, p})
+		default:
+			o.exact.Set(p)
+		}
+	}
+	return o, nil
+}
+
+// matchPathPrefix reports whether glob matches project or its leading path
+// elements.
+func matchPathPrefix(glob, project string) bool {
+	n := strings.Count(glob, "/") + 1
+	elems := strings.SplitN(project, "/", n+1)
+	if len(elems) < n {
+		return false
+	}
+	ok, _ := path.Match(glob, strings.Join(elems[:n], "/"))
+	return ok
+}
+
+// Len returns the number of entries.
+func (o *Overrides) Len() int {
+	if o == nil || o.exact == nil {
+		return 0
+	}
+	return o.exact.Len() + len(o.patterns)
+}
+
+// Keys returns the project of every entry, module paths first, then the
+// patterns in order.
+func (o *Overrides) Keys() []string {
+	if o == nil || o.exact == nil {
+		return nil
+	}
+	keys := o.exact.Keys()
+	seen := map[string]bool{}
+	for _, p := range o.patterns {
+		if !seen[p.entry.Project] {
+			seen[p.entry.Project] = true
+			keys = append(keys, p.entry.Project)
+		}
+	}
+	return keys
+}
+
+// Lookup returns the override of project and the project of the entry that
+// matched it. An entry for the module path takes precedence over patterns,
+// and of several matching patterns the longest wins, or the last one of
+// equal length, as later entries win. The entry is returned with the
+// module path of project, and its version unless the entry sets one.
+func (o *Overrides) Lookup(project Project) (Project, string, bool) {
+	if o == nil || o.exact == nil {
+		return Project{}, "", false
+	}
+	if e, ok := o.exact.Get(project.Project); ok {
+		if e.Version == "" {
+			e.Version = project.Version
+		}
+		return e, e.Project, true
+	}
+	var best *overridePattern
+	for i, p := range o.patterns {
+		if (best == nil || len(p.entry.Project) >= len(best.entry.Project)) && p.match(project.Project) {
+			best = &o.patterns[i]
+		}
+	}
+	if best == nil {
+		return Project{}, "", false
+	}
+	e := best.entry
+	e.Project = project.Project
+	if e.Version == "" {
+		e.Version = project.Version
+	}
+	return e, best.entry.Project, true
+}
+
+// applyOverrides replaces the licenses of the entries of r that have an
+// override, and their VCS root if the override sets one. Everything else
+// the inputs recorded, like the version, annotations, labels, scope,
+// binaries and processing hints, is kept.
+func (r *Registry) applyOverrides(o *Overrides) {
+	for key, p := range r.entries {
+		e, _, ok := o.Lookup(p)
+		if !ok {
+			continue
+		}
+		p.Licenses = e.Licenses
+		p.LicenseExpression = e.LicenseExpression
+		p.LicenseStatus = e.LicenseStatus
+		if e.VCS != "" {
+			p.VCS = e.VCS
+		}
+		r.entries[key] = p
+	}
+}
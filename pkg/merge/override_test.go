@@ -0,0 +1,150 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOverridesLookup(t *testing.T) {
+	entry := func(project, license string) Project {
+		return Project{Project: project, Licenses: []License{{Type: license}}}
+	}
+	o, err := NewOverrides([]Project{
+		entry("github.com/myorg/*", "glob"),
+		entry("github.com/myorg/legacy", "exact"),
+		entry(`re:^github\.com/myorg/(api|cli)$`, "regexp"),
+		entry("github.com/myorg/a*", "glob-a"),
+		entry("github.com/other/*", "first"),
+		entry(`re:^github\.com/ot`, "later"),
+		entry(`re:^example\.com/`, "first"),
+		entry("example.com/abc/*", "later"),
+		{Project: "k8s.io/*", Version: "v0.1.0", Licenses: []License{{Type: "k8s"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		project, version string
+		license, key     string
+		wantVersion      string
+	}{
+		// an entry for the module path wins over every pattern, and keeps
+		// the version of the project like a pattern
+		{"github.com/myorg/legacy", "v1.2.0", "exact", "github.com/myorg/legacy", "v1.2.0"},
+		{"github.com/myorg/legacy", "", "exact", "github.com/myorg/legacy", ""},
+		// globs match the leading path elements
+		{"github.com/myorg/repo", "v1.0.0", "glob", "github.com/myorg/*", "v1.0.0"},
+		{"github.com/myorg/repo/v2", "", "glob", "github.com/myorg/*", ""},
+		// the longest matching pattern wins, glob or regular expression
+		{"github.com/myorg/api", "", "regexp", `re:^github\.com/myorg/(api|cli)$`, ""},
+		{"github.com/myorg/app", "", "glob-a", "github.com/myorg/a*", ""},
+		// equally long patterns: the later entry wins
+		{"github.com/other/repo", "", "later", `re:^github\.com/ot`, ""},
+		{"example.com/abc/x", "", "later", "example.com/abc/*", ""},
+		// a version set by the entry replaces the one of the project
+		{"k8s.io/api", "v0.20.0", "k8s", "k8s.io/*", "v0.1.0"},
+	}
+	for _, c := range cases {
+		got, key, ok := o.Lookup(Project{Project: c.project, Version: c.version})
+		if !ok {
+			t.Errorf("Lookup(%s) found no override, want %s", c.project, c.key)
+			continue
+		}
+		if key != c.key || got.Licenses[0].Type != c.license {
+			t.Errorf("Lookup(%s) = %s from %s, want %s from %s", c.project, got.Licenses[0].Type, key, c.license, c.key)
+		}
+		if got.Project != c.project || got.Version != c.wantVersion {
+			t.Errorf("Lookup(%s) = %s@%s, want %s@%s", c.project, got.Project, got.Version, c.project, c.wantVersion)
+		}
+	}
+
+	for _, project := range []string{"github.com/myorg", "github.com/myorgx/repo", "golang.org/x/net"} {
+		if _, key, ok := o.Lookup(Project{Project: project}); ok {
+			t.Errorf("Lookup(%s) matched %s, want no override", project, key)
+		}
+	}
+}
+
+func TestNewOverridesInvalidPattern(t *testing.T) {
+	for _, project := range []string{"re:(", "github.com/[myorg/*"} {
+		if _, err := NewOverrides([]Project{{Project: project}}); err == nil {
+			t.Errorf("NewOverrides(%q) succeeded, want an error", project)
+		}
+	}
+}
+
+func TestOverridesZeroValue(t *testing.T) {
+	var o Overrides
+	if _, _, ok := o.Lookup(Project{Project: "x"}); ok || o.Len() != 0 || o.Keys() != nil {
+		t.Error("the zero value holds overrides")
+	}
+}
+
+func TestRegistryApplyOverrides(t *testing.T) {
+	o, err := NewOverrides([]Project{
+		{Project: "example.com/x", Licenses: []License{{Type: "MIT", Confidence: 1}}},
+		{Project: "example.com/p/*", Licenses: []License{{Type: "Apache-2.0"}}, VCS: "github.com/example/p"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep-all versions are keyed by path and version
+	reg := NewRegistryKeyedBy(KeyByPathVersion)
+	for _, version := range []string{"v1.2.0", "v2.0.0"} {
+		reg.Set(Project{
+			Project:     "example.com/x",
+			Version:     version,
+			Licenses:    []License{{Type: "Odd", Confidence: 0.4}},
+			VCS:         "github.com/example/x",
+			Scope:       "tool",
+			Binaries:    []string{"example.com/x/cmd/x"},
+			SkipVCS:     true,
+			Annotations: []Annotation{{Text: "checked by hand"}},
+			Labels:      map[string]string{"team": "infra"},
+		})
+	}
+	reg.Set(Project{Project: "example.com/p/q", Version: "v0.1.0", VCS: "example.com/p/q"})
+	reg.applyOverrides(o)
+
+	if got, want := reg.Keys(), []string{"example.com/p/q@v0.1.0", "example.com/x@v1.2.0", "example.com/x@v2.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %q, want %q", got, want)
+	}
+	for _, version := range []string{"v1.2.0", "v2.0.0"} {
+		p, _ := reg.Get("example.com/x@" + version)
+		want := Project{
+			Project:     "example.com/x",
+			Version:     version,
+			Licenses:    []License{{Type: "MIT", Confidence: 1}},
+			VCS:         "github.com/example/x",
+			Scope:       "tool",
+			Binaries:    []string{"example.com/x/cmd/x"},
+			SkipVCS:     true,
+			Annotations: []Annotation{{Text: "checked by hand"}},
+			Labels:      map[string]string{"team": "infra"},
+		}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", version, p, want)
+		}
+	}
+	// an override setting the VCS root replaces it
+	if p, _ := reg.Get("example.com/p/q@v0.1.0"); p.VCS != "github.com/example/p" || p.Version != "v0.1.0" || p.Licenses[0].Type != "Apache-2.0" {
+		t.Errorf("example.com/p/q = %+v", p)
+	}
+}
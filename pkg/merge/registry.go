@@ -0,0 +1,342 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Registry is a set of BOM entries, keyed by project path unless created
+// with a different KeyFunc.
+type Registry struct {
+	key     KeyFunc
+	entries map[string]Project
+}
+
+func NewRegistry() *Registry {
+	return NewRegistryKeyedBy(KeyByProject)
+}
+
+// NewRegistryKeyedBy returns an empty registry that dedups entries by key.
+func NewRegistryKeyedBy(key KeyFunc) *Registry {
+	return &Registry{key: key, entries: map[string]Project{}}
+}
+
+// NewRegistryFrom builds a registry from a list of projects. Later entries
+// replace earlier ones with the same project path.
+func NewRegistryFrom(projects []Project) *Registry {
+	r := NewRegistry()
+	for _, p := range projects {
+		r.Set(p)
+	}
+	return r
+}
+
+func (r *Registry) Len() int {
+	return len(r.entries)
+}
+
+func (r *Registry) Get(key string) (Project, bool) {
+	p, ok := r.entries[key]
+	return p, ok
+}
+
+// Set adds or replaces an entry. Entries without a project path are ignored.
+func (r *Registry) Set(p Project) {
+	if p.Project == "" {
+		return
+	}
+	r.entries[r.key(p)] = p
+}
+
+func (r *Registry) Delete(key string) {
+	delete(r.entries, key)
+}
+
+// KeyOf returns the key of p in r.
+func (r *Registry) KeyOf(p Project) string {
+	return r.key(p)
+}
+
+// Remove deletes the entry of p.
+func (r *Registry) Remove(p Project) {
+	delete(r.entries, r.key(p))
+}
+
+// Keys returns the sorted keys of the registry.
+func (r *Registry) Keys() []string {
+	keys := make([]string, 0, len(r.entries))
+	for k := range r.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Projects returns the entries sorted by key.
+func (r *Registry) Projects() []Project {
+	out := make([]Project, 0, len(r.entries))
+	for _, key := range r.Keys() {
+		out = append(out, r.entries[key])
+	}
+	return out
+}
+
+// ProjectsByRisk returns the entries sorted by descending risk score and
+// then by key.
+func (r *Registry) ProjectsByRisk() []Project {
+	out := r.Projects()
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Risk > out[j].Risk
+	})
+	return out
+}
+
+// Each calls fn for every entry in key order and stops at the first error.
+// fn may safely Set or Delete entries of r.
+func (r *Registry) Each(fn func(p Project) error) error {
+	for _, key := range r.Keys() {
+		p, ok := r.entries[key]
+		if !ok {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter removes every entry for which keep returns false.
+func (r *Registry) Filter(keep func(p Project) bool) {
+	for key, p := range r.entries {
+		if !keep(p) {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// Override replaces entries of r with the entry of overrides that has the
+// same key in overrides. Overrides for projects not present in r are
+// ignored.
+func (r *Registry) Override(overrides *Registry) {
+	for key, p := range r.entries {
+		if o, ok := overrides.entries[overrides.key(p)]; ok {
+			r.entries[key] = o
+		}
+	}
+}
+
+// ApplyLabels adds labels, keyed by project path, to the entries of r.
+// Labels already present on an entry are replaced by the given ones.
+func (r *Registry) ApplyLabels(labels map[string]map[string]string) {
+	for key, p := range r.entries {
+		kv, ok := labels[p.Project]
+		if !ok {
+			continue
+		}
+		merged := make(map[string]string, len(p.Labels)+len(kv))
+		for k, v := range p.Labels {
+			merged[k] = v
+		}
+		for k, v := range kv {
+			merged[k] = v
+		}
+		p.Labels = merged
+		r.entries[key] = p
+	}
+}
+
+// RecordError merges the error reported for p in source into r, counting
+// repeated messages instead of keeping only the last one seen.
+func (r *Registry) RecordError(p Project, source string) {
+	if p.Project == "" {
+		return
+	}
+	key := r.key(p)
+	info, ok := r.entries[key]
+	if !ok {
+		info = p
+		info.Errors = nil
+	}
+	info.Errors = addErrorRecord(info.Errors, ErrorRecord{
+		Message: p.Error,
+		Count:   1,
+		Sources: []string{source},
+	})
+	info.Error = info.Errors[0].Message
+	r.entries[key] = info
+}
+
+func addErrorRecord(records []ErrorRecord, rec ErrorRecord) []ErrorRecord {
+	found := false
+	for i := range records {
+		if records[i].Message == rec.Message {
+			records[i].Count += rec.Count
+			for _, src := range rec.Sources {
+				if !contains(records[i].Sources, src) {
+					records[i].Sources = append(records[i].Sources, src)
+				}
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		records = append(records, rec)
+	}
+
+	// most frequent message first; error keeps reporting it for existing consumers
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Count != records[j].Count {
+			return records[i].Count > records[j].Count
+		}
+		return records[i].Message < records[j].Message
+	})
+	return records
+}
+
+// errorRecords returns the aggregated errors of p, treating an entry that
+// only sets Error as a single occurrence of it.
+func errorRecords(p Project) []ErrorRecord {
+	if len(p.Errors) > 0 || p.Error == "" {
+		return p.Errors
+	}
+	return []ErrorRecord{{Message: p.Error, Count: 1}}
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ConflictStrategy decides which entry wins when Merge finds the same
+// project in both registries.
+type ConflictStrategy string
+
+const (
+	// KeepExisting keeps the entry already present in the destination.
+	KeepExisting ConflictStrategy = "keep-existing"
+	// Replace overwrites the destination entry with the source entry.
+	Replace ConflictStrategy = "replace"
+	// HighestConfidence keeps the entry whose best license has the higher
+	// confidence, preferring the destination on ties.
+	HighestConfidence ConflictStrategy = "highest-confidence"
+	// CombineErrors merges the aggregated errors of both entries. Use it
+	// for error registries.
+	CombineErrors ConflictStrategy = "combine-errors"
+	// FailOnConflict returns an error if an entry differs between the
+	// registries.
+	FailOnConflict ConflictStrategy = "fail"
+)
+
+// Merge adds every entry of src to dst, resolving entries present in both
+// registries with strategy. Entries are keyed by the KeyFunc of dst, which
+// may differ from the one of src.
+func Merge(dst, src *Registry, strategy ConflictStrategy) error {
+	for _, s := range src.Projects() {
+		key := dst.key(s)
+		d, ok := dst.entries[key]
+		if !ok {
+			dst.entries[key] = s
+			continue
+		}
+		p, err := resolveConflict(d, s, strategy)
+		if err != nil {
+			return fmt.Errorf("conflicting entries for %s: %v", key, err)
+		}
+		dst.entries[key] = p
+	}
+	return nil
+}
+
+// Rekey returns a copy of r keyed by key. Entries that end up with the same
+// key are combined with strategy, and the project paths of all combined
+// entries other than the kept one are recorded as its aliases.
+func (r *Registry) Rekey(key KeyFunc, strategy ConflictStrategy) (*Registry, error) {
+	out := NewRegistryKeyedBy(key)
+	for _, p := range r.Projects() {
+		k := key(p)
+		p.Key = k
+		d, ok := out.entries[k]
+		if !ok {
+			out.entries[k] = p
+			continue
+		}
+		winner, err := resolveConflict(d, p, strategy)
+		if err != nil {
+			return nil, fmt.Errorf("conflicting entries for %s: %v", k, err)
+		}
+		aliases := append(append([]string(nil), d.Aliases...), p.Aliases...)
+		for _, q := range []Project{d, p} {
+			if q.Project != winner.Project && !contains(aliases, q.Project) {
+				aliases = append(aliases, q.Project)
+			}
+		}
+		sort.Strings(aliases)
+		winner.Aliases = aliases
+		out.entries[k] = winner
+	}
+	return out, nil
+}
+
+func resolveConflict(d, s Project, strategy ConflictStrategy) (Project, error) {
+	switch strategy {
+	case KeepExisting:
+		return d, nil
+	case Replace:
+		return s, nil
+	case HighestConfidence:
+		if s.BestConfidence() > d.BestConfidence() {
+			return s, nil
+		}
+		return d, nil
+	case CombineErrors:
+		d.Errors = errorRecords(d)
+		for _, rec := range errorRecords(s) {
+			d.Errors = addErrorRecord(d.Errors, rec)
+		}
+		if len(d.Errors) > 0 {
+			d.Error = d.Errors[0].Message
+		}
+		return d, nil
+	case FailOnConflict:
+		if !equalProject(d, s) {
+			return Project{}, fmt.Errorf("%s and %s differ", d.Project, s.Project)
+		}
+		return d, nil
+	default:
+		return Project{}, fmt.Errorf("unknown conflict strategy %q", strategy)
+	}
+}
+
+func equalProject(a, b Project) bool {
+	if a.Project != b.Project || a.Error != b.Error || a.VCS != b.VCS || len(a.Licenses) != len(b.Licenses) {
+		return false
+	}
+	for i := range a.Licenses {
+		if a.Licenses[i] != b.Licenses[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeKeyFuncs(t *testing.T) {
+	dst := NewRegistryKeyedBy(KeyByVCS)
+	dst.Set(Project{Project: "github.com/org/repo/a", VCS: "github.com/org/repo", LicenseExpression: "MIT"})
+
+	src := NewRegistry()
+	src.Set(Project{Project: "github.com/org/repo/b", VCS: "github.com/org/repo", LicenseExpression: "MIT"})
+	src.Set(Project{Project: "example.com/x", LicenseExpression: "Apache-2.0"})
+
+	if err := Merge(dst, src, KeepExisting); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dst.Keys(), []string{"example.com/x", "github.com/org/repo"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %q, want %q", got, want)
+	}
+	for _, key := range dst.Keys() {
+		if _, ok := dst.Get(key); !ok {
+			t.Errorf("Get(%q) found no entry", key)
+		}
+	}
+	if p, _ := dst.Get("github.com/org/repo"); p.Project != "github.com/org/repo/a" {
+		t.Errorf("kept %s, want the existing github.com/org/repo/a", p.Project)
+	}
+}
+
+func TestMergeCombineErrors(t *testing.T) {
+	dst := NewRegistry()
+	dst.RecordError(Project{Project: "example.com/x", Error: "not found"}, "a.json")
+
+	src := NewRegistry()
+	// entries read from older fragments only set error
+	src.Set(Project{Project: "example.com/x", Error: "not found"})
+	src.Set(Project{Project: "example.com/y", Error: "timeout"})
+
+	if err := Merge(dst, src, CombineErrors); err != nil {
+		t.Fatal(err)
+	}
+	x, _ := dst.Get("example.com/x")
+	want := []ErrorRecord{{Message: "not found", Count: 2, Sources: []string{"a.json"}}}
+	if !reflect.DeepEqual(x.Errors, want) {
+		t.Errorf("errors = %+v, want %+v", x.Errors, want)
+	}
+
+	// both sides only set error
+	dst = NewRegistry()
+	dst.Set(Project{Project: "example.com/x", Error: "not found"})
+	if err := Merge(dst, src, CombineErrors); err != nil {
+		t.Fatal(err)
+	}
+	x, _ = dst.Get("example.com/x")
+	want = []ErrorRecord{{Message: "not found", Count: 2}}
+	if !reflect.DeepEqual(x.Errors, want) || x.Error != "not found" {
+		t.Errorf("error = %q, errors = %+v, want %+v", x.Error, x.Errors, want)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"math"
+	"strings"
+)
+
+// LicenseCategory groups licenses by the obligations they impose.
+type LicenseCategory string
+
+const (
+	CategoryPermissive   LicenseCategory = "permissive"
+	CategoryWeakCopyleft LicenseCategory = "weak-copyleft"
+	CategoryCopyleft     LicenseCategory = "copyleft"
+	CategoryUnknown      LicenseCategory = "unknown"
+)
+
+var (
+	permissiveIDs = []string{
+		"0BSD", "Apache-1.1", "Apache-2.0", "BlueOak-1.0.0", "BSD-2-Clause", "BSD-2-Clause-FreeBSD",
+		"BSD-3-Clause", "BSD-4-Clause", "BSL-1.0", "CC-BY-3.0", "CC-BY-4.0", "CC0-1.0", "ISC", "MIT",
+		"MIT-0", "NCSA", "OpenSSL", "PostgreSQL", "PSF-2.0", "Python-2.0", "Unlicense", "W3C", "X11",
+		"Zlib",
+	}
+	weakCopyleftIDs = []string{
+		"CDDL-1.0", "CDDL-1.1", "CPL-1.0", "EPL-1.0", "EPL-2.0", "LGPL-2.0", "LGPL-2.0-only",
+		"LGPL-2.0-or-later", "LGPL-2.1", "LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0",
+		"LGPL-3.0-only", "LGPL-3.0-or-later", "MPL-1.0", "MPL-1.1", "MPL-2.0",
+	}
+	copyleftIDs = []string{
+		"AGPL-1.0", "AGPL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "CC-BY-SA-4.0", "EUPL-1.1",
+		"EUPL-1.2", "GPL-1.0", "GPL-2.0", "GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0", "GPL-3.0-only",
+		"GPL-3.0-or-later", "OSL-3.0", "SSPL-1.0",
+	}
+)
+
+var licenseCategories = map[string]LicenseCategory{}
+
+func init() {
+	for category, ids := range map[LicenseCategory][]string{
+		CategoryPermissive:   permissiveIDs,
+		CategoryWeakCopyleft: weakCopyleftIDs,
+		CategoryCopyleft:     copyleftIDs,
+	} {
+		for _, id := range ids {
+			licenseCategories[strings.ToLower(id)] = category
+		}
+	}
+}
+
+// CategoryOf returns the category of an SPDX license identifier.
+func CategoryOf(licenseType string) LicenseCategory {
+	if c, ok := licenseCategories[strings.ToLower(strings.TrimSpace(licenseType))]; ok {
+		return c
+	}
+	return CategoryUnknown
+}
+
+var categoryRank = map[LicenseCategory]int{
+	CategoryPermissive:   0,
+	CategoryWeakCopyleft: 1,
+	CategoryCopyleft:     2,
+	CategoryUnknown:      3,
+}
+
+// Categorize returns the category of the licenses of p, or CategoryUnknown
+// if p has no license. All licenses of a project apply, so the most
+// restrictive one counts, unless its license expression offers a choice:
+// the consumer may pick the least restrictive alternative of an OR.
+func Categorize(p Project) LicenseCategory {
+	if len(p.Licenses) == 0 {
+		return CategoryUnknown
+	}
+	if p.LicenseExpression != "" {
+		if c, ok := expressionCategory(p.LicenseExpression); ok {
+			return c
+		}
+	}
+	category := CategoryPermissive
+	for _, lic := range p.Licenses {
+		if c := categoryOfLicense(lic); categoryRank[c] > categoryRank[category] {
+			category = c
+		}
+	}
+	return category
+}
+
+// expressionCategory returns the category of an SPDX license expression:
+// the least restrictive alternative of an OR and the most restrictive
+// operand of an AND. It reports false if expr can not be parsed.
+func expressionCategory(expr string) (LicenseCategory, bool) {
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr))
+	e := &expressionParser{tokens: tokens}
+	c, ok := e.or()
+	if !ok || e.pos != len(tokens) {
+		return CategoryUnknown, false
+	}
+	return c, true
+}
+
+type expressionParser struct {
+	tokens []string
+	pos    int
+}
+
+func (e *expressionParser) next(op string) bool {
+	if e.pos < len(e.tokens) && strings.EqualFold(e.tokens[e.pos], op) {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+func (e *expressionParser) or() (LicenseCategory, bool) {
+	category, ok := e.and()
+	for ok && e.next("OR") {
+		var c LicenseCategory
+		if c, ok = e.and(); categoryRank[c] < categoryRank[category] {
+			category = c
+		}
+	}
+	return category, ok
+}
+
+func (e *expressionParser) and() (LicenseCategory, bool) {
+	category, ok := e.term()
+	for ok && e.next("AND") {
+		var c LicenseCategory
+		if c, ok = e.term(); categoryRank[c] > categoryRank[category] {
+			category = c
+		}
+	}
+	return category, ok
+}
+
+func (e *expressionParser) term() (LicenseCategory, bool) {
+	if e.next("(") {
+		c, ok := e.or()
+		return c, ok && e.next(")")
+	}
+	if e.pos == len(e.tokens) {
+		return CategoryUnknown, false
+	}
+	lic := License{Type: e.tokens[e.pos]}
+	switch strings.ToUpper(lic.Type) {
+	case "AND", "OR", "WITH", ")":
+		return CategoryUnknown, false
+	}
+	e.pos++
+	if e.next("WITH") {
+		if e.pos == len(e.tokens) {
+			return CategoryUnknown, false
+		}
+		lic.Exception = e.tokens[e.pos]
+		e.pos++
+	}
+	return categoryOfLicense(lic), true
+}
+
+var categoryRisk = map[LicenseCategory]int{
+	CategoryPermissive:   0,
+	CategoryWeakCopyleft: 40,
+	CategoryCopyleft:     70,
+	CategoryUnknown:      90,
+}
+
+// shippedRisk is added to the risk of copyleft projects linked into a
+// shipped binary, whose obligations apply to the release itself.
+var shippedRisk = map[LicenseCategory]int{
+	CategoryWeakCopyleft: 10,
+	CategoryCopyleft:     20,
+}
+
+// RiskScore rates a project from 0 to 100 by license category, detection
+// confidence, disagreement with the declared license and copyleft in a
+// shipped binary, so triage can start with the riskiest entries.
+func RiskScore(p Project) int {
+	category := Categorize(p)
+	score := categoryRisk[category]
+	if p.Error != "" {
+		score = 100
+	}
+	if shipped(p) {
+		score += shippedRisk[category]
+	}
+	if category != CategoryUnknown {
+		// a missing confidence counts as no confidence at all
+		score += int(math.Round((1 - math.Min(p.BestConfidence(), 1)) * 30))
+	}
+	if p.LicenseMismatch {
+		// the declared license is a second opinion on the detection
+		score += 20
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+// shipped reports whether p is known to end up in a released binary: a main
+// package depends on it and it is not a build-only tool.
+func shipped(p Project) bool {
+	return p.Scope != "tool" && len(p.Binaries) > 0
+}
+
+// AnnotateRisk sets the license category and risk score of p.
+func AnnotateRisk(p Project) Project {
+	p.Category = Categorize(p)
+	p.Risk = RiskScore(p)
+	return p
+}
@@ -0,0 +1,65 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"testing"
+)
+
+func TestCategorizeExpressions(t *testing.T) {
+	mit := License{Type: "MIT", Confidence: 1}
+	gpl := License{Type: "GPL-3.0", Confidence: 1}
+	lgpl := License{Type: "LGPL-2.1", Confidence: 1}
+
+	cases := []struct {
+		expression string
+		licenses   []License
+		want       LicenseCategory
+	}{
+		// without an expression every license applies
+		{"", []License{mit, gpl}, CategoryCopyleft},
+		{"MIT OR GPL-3.0", []License{mit, gpl}, CategoryPermissive},
+		{"GPL-3.0 OR LGPL-2.1", []License{gpl, lgpl}, CategoryWeakCopyleft},
+		{"MIT AND GPL-3.0", []License{mit, gpl}, CategoryCopyleft},
+		{"mit and lgpl-2.1", []License{mit, lgpl}, CategoryWeakCopyleft},
+		{"(MIT OR GPL-3.0) AND LGPL-2.1", []License{mit, gpl, lgpl}, CategoryWeakCopyleft},
+		{"MIT AND GPL-3.0 OR LGPL-2.1", []License{mit, gpl, lgpl}, CategoryWeakCopyleft},
+		{"GPL-2.0 WITH Classpath-exception-2.0 OR GPL-3.0", []License{{Type: "GPL-2.0", Exception: "Classpath-exception-2.0"}, gpl}, CategoryWeakCopyleft},
+		{"MIT OR Odd", []License{mit, {Type: "Odd"}}, CategoryPermissive},
+		// unparsable expressions fall back to the licenses
+		{"MIT OR", []License{mit, gpl}, CategoryCopyleft},
+		{"(MIT OR GPL-3.0", []License{mit, gpl}, CategoryCopyleft},
+	}
+	for _, c := range cases {
+		p := Project{Project: "example.com/x", Licenses: c.licenses, LicenseExpression: c.expression}
+		if got := Categorize(p); got != c.want {
+			t.Errorf("Categorize(%q) = %s, want %s", c.expression, got, c.want)
+		}
+	}
+}
+
+func TestRiskScoreDualLicense(t *testing.T) {
+	licenses := []License{{Type: "MIT", Confidence: 1}, {Type: "GPL-3.0", Confidence: 1}}
+	or := Project{Project: "example.com/x", Licenses: licenses, LicenseExpression: "MIT OR GPL-3.0"}
+	and := Project{Project: "example.com/x", Licenses: licenses, LicenseExpression: "MIT AND GPL-3.0"}
+	if got := RiskScore(or); got != categoryRisk[CategoryPermissive] {
+		t.Errorf("RiskScore(MIT OR GPL-3.0) = %d, want %d", got, categoryRisk[CategoryPermissive])
+	}
+	if got := RiskScore(and); got != categoryRisk[CategoryCopyleft] {
+		t.Errorf("RiskScore(MIT AND GPL-3.0) = %d, want %d", got, categoryRisk[CategoryCopyleft])
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+// Project is a single BOM entry as produced by license-bill-of-materials.
+type Project struct {
+	Project  string    `json:"project"`
+	Version  string    `json:"version,omitempty"`
+	Licenses []License `json:"licenses,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	VCS      string    `json:"vcs,omitempty"`
+
+	// LicenseExpression is the SPDX expression offering the choice between
+	// Licenses, e.g. "MIT OR Apache-2.0", set if more than one license was
+	// kept for a dual-licensed project.
+	LicenseExpression string `json:"licenseExpression,omitempty"`
+
+	// LicenseText is the content of the license file of the repository at
+	// Version, and LicenseFile its name. If the texts are written to a
+	// directory instead, LicenseText is empty and LicenseFile is the path
+	// of the file relative to the BOM.
+	LicenseText string `json:"licenseText,omitempty"`
+	LicenseFile string `json:"licenseFile,omitempty"`
+
+	// LicenseDeviation is the share of the license text not found in the
+	// canonical text of the license, e.g. 0.25 for added clauses.
+	// LicenseModified is set if it exceeds the configured threshold.
+	LicenseDeviation float64 `json:"licenseDeviation,omitempty"`
+	LicenseModified  bool    `json:"licenseModified,omitempty"`
+
+	// LicenseURL points at the canonical text of the license on the SPDX
+	// license list, or at the license file of the project if the license
+	// is not on the list.
+	LicenseURL string `json:"licenseURL,omitempty"`
+
+	// DeclaredLicense is the license the package registry declares for the
+	// version, as an SPDX expression. LicenseMismatch is set if it does not
+	// match the detected licenses.
+	DeclaredLicense string `json:"declaredLicense,omitempty"`
+	LicenseMismatch bool   `json:"licenseMismatch,omitempty"`
+
+	// RepoLicense is the license GitHub detected for the repository of the
+	// project, set if it differs from the detected licenses.
+	RepoLicense string `json:"repoLicense,omitempty"`
+
+	// Scorecard is the OpenSSF scorecard score of the source repository
+	// and Dependents the number of packages depending on the version,
+	// both as reported by deps.dev.
+	Scorecard  float64 `json:"scorecard,omitempty"`
+	Dependents int     `json:"dependents,omitempty"`
+
+	// LicenseStatus explains an empty Licenses list: NOASSERTION if no
+	// license could be determined, NONE if the project has no license.
+	LicenseStatus string `json:"licenseStatus,omitempty"`
+
+	// PseudoVersion is set if Version refers to an untagged commit, which
+	// legal review treats differently from tagged releases. Revision is
+	// the commit encoded in the pseudo-version.
+	PseudoVersion bool   `json:"pseudoVersion,omitempty"`
+	Revision      string `json:"revision,omitempty"`
+
+	// ReviewReason explains why the entry was routed to manual review
+	// instead of the merged BOM.
+	ReviewReason string `json:"reviewReason,omitempty"`
+
+	// FilteredBy names the rule that intentionally excluded the entry
+	// from the merged BOM.
+	FilteredBy string `json:"filteredBy,omitempty"`
+
+	// Category and Risk are derived from the licenses at the end of a merge.
+	Category LicenseCategory `json:"category,omitempty"`
+	Risk     int             `json:"risk,omitempty"`
+
+	// Inactive is set to the reason, e.g. archived, if the repository of
+	// the project looks abandoned.
+	Inactive string `json:"inactive,omitempty"`
+
+	// VCSRedirect is set to "<module host> -> <vcs host>" if the VCS root
+	// is hosted elsewhere than the module path suggests, which may point
+	// to a squatted or hijacked vanity import path.
+	VCSRedirect string `json:"vcsRedirect,omitempty"`
+
+	// Checksum is the go.sum hash of the module recorded in the checksum
+	// database. Integrity reports whether the module served by the proxy
+	// matches it: verified, mismatch or unknown.
+	Checksum  string `json:"checksum,omitempty"`
+	Integrity string `json:"integrity,omitempty"`
+
+	// Retracted is set to the rationale of the retract directive, or to
+	// "retracted" if it has none, if the module author retracted Version.
+	Retracted string `json:"retracted,omitempty"`
+
+	// Scope is tool for build-only dependencies, such as code generators,
+	// and empty for runtime dependencies.
+	Scope string `json:"scope,omitempty"`
+
+	// Fork is the module path and version that a replace directive
+	// substitutes for the project. VCS then refers to the fork.
+	Fork string `json:"fork,omitempty"`
+
+	// Binaries lists the main packages that depend on the project, when
+	// merging for a repository with several of them.
+	Binaries []string `json:"binaries,omitempty"`
+
+	// Key is the registry key the entry was deduplicated by, if other
+	// than the project path. Aliases lists the project paths of the
+	// entries that were combined into this one.
+	Key     string   `json:"key,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Waiver is the approved exception that covers the entry in policy
+	// checks. It does not change the detected license.
+	Waiver *Waiver `json:"waiver,omitempty"`
+
+	// IntroducedIn is the label, usually the release version, of the first
+	// recorded BOM of the history that contained the project, and
+	// IntroducedAt the date it was recorded.
+	IntroducedIn string `json:"introducedIn,omitempty"`
+	IntroducedAt string `json:"introducedAt,omitempty"`
+
+	// SkipVCS and SkipEnrichment are processing hints of fragments and
+	// overrides, e.g. for internal modules that never resolve publicly.
+	// They skip VCS detection and the lookups of enrichers for the entry.
+	SkipVCS        bool `json:"skipVcs,omitempty"`
+	SkipEnrichment bool `json:"skipEnrichment,omitempty"`
+
+	// Annotations are comments of reviewers or tools on the entry. They are
+	// kept through conversions from and to SPDX and CycloneDX documents.
+	Annotations []Annotation `json:"annotations,omitempty"`
+
+	// Labels carry arbitrary organization specific metadata, e.g. cost
+	// center or product area, through to the exported documents.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Errors aggregates every distinct error reported for this project
+	// across all input files. Only populated for bom_error.json entries.
+	Errors []ErrorRecord `json:"errors,omitempty"`
+}
+
+type License struct {
+	Type       string  `json:"type,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Exception is the SPDX identifier of an exception granted in addition
+	// to the license, e.g. Classpath-exception-2.0.
+	Exception string `json:"exception,omitempty"`
+}
+
+// Waiver records who approved an exception for a project, why and until
+// when. License limits the waiver to entries detected with that license.
+type Waiver struct {
+	License    string `json:"license,omitempty"`
+	ApprovedBy string `json:"approvedBy"`
+	Reason     string `json:"reason"`
+	Expires    string `json:"expires,omitempty"`
+}
+
+// Annotation is a comment on an entry. Annotator names its author in the
+// form SPDX uses, e.g. "Person: Jane Doe", "Organization: ACME" or
+// "Tool: syft", Date is an RFC 3339 timestamp and Type is REVIEW or OTHER.
+type Annotation struct {
+	Annotator string `json:"annotator,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Text      string `json:"text"`
+}
+
+type ErrorRecord struct {
+	Message string   `json:"message"`
+	Count   int      `json:"count"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// BestConfidence returns the highest license confidence of the project.
+func (p Project) BestConfidence() float64 {
+	var score float64
+	for _, lic := range p.Licenses {
+		if lic.Confidence > score {
+			score = lic.Confidence
+		}
+	}
+	return score
+}
@@ -0,0 +1,68 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"strings"
+
+	"gomodules.xyz/mod"
+)
+
+// VCSResolver returns the VCS root of a project, or an empty string if it
+// has none.
+type VCSResolver func(project string) (string, error)
+
+// DetectVCSRoot returns the VCS root of project and how it was found, from
+// the go-import meta tag or, for GitHub projects without one, the path.
+func DetectVCSRoot(project string) (string, string, error) {
+	vcs, err := mod.DetectVCSRoot(project)
+	if err != nil {
+		return "", "", err
+	}
+	if vcs == "" && strings.HasPrefix(project, "github.com/") {
+		// for github projects keep first 3 parts
+		return strings.Join(strings.Split(project, "/")[:3], "/"), "github.com path", nil
+	}
+	return vcs, "go-import meta tag", nil
+}
+
+func detectVCS(project string) (string, error) {
+	vcs, _, err := DetectVCSRoot(project)
+	return vcs, err
+}
+
+// SetVCS sets the VCS root of every entry of reg that resolve finds one
+// for. Entries without one, or with SkipVCS set, keep their VCS field.
+func SetVCS(reg *Registry, resolve VCSResolver) error {
+	if resolve == nil {
+		resolve = detectVCS
+	}
+	return reg.Each(func(p Project) error {
+		if p.SkipVCS {
+			return nil
+		}
+		vcs, err := resolve(p.Project)
+		if err != nil {
+			return err
+		}
+		if vcs != "" {
+			p.VCS = vcs
+			reg.Set(p)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pseudoVersionRE matches Go module pseudo-versions, e.g.
+// v0.0.0-20201107204938-8bf34a254118 or v1.2.4-0.20191109021931-daa7c04131f5.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// IsPseudoVersion reports whether v is a pseudo-version, i.e. refers to an
+// untagged commit rather than a release.
+func IsPseudoVersion(v string) bool {
+	return strings.Count(v, "-") >= 2 && pseudoVersionRE.MatchString(v)
+}
+
+// PseudoVersionRevision returns the commit hash prefix encoded in the
+// pseudo-version v.
+func PseudoVersionRevision(v string) (string, bool) {
+	if !IsPseudoVersion(v) {
+		return "", false
+	}
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	return v[strings.LastIndex(v, "-")+1:], true
+}
+
+// AnnotateVersion marks projects pinned to a pseudo-version and records the
+// underlying commit.
+func AnnotateVersion(p Project) Project {
+	if rev, ok := PseudoVersionRevision(p.Version); ok {
+		p.PseudoVersion = true
+		p.Revision = rev
+	} else {
+		p.PseudoVersion = false
+	}
+	return p
+}
+
+// CompareVersions compares two module versions like vMAJOR.MINOR.PATCH with
+// optional pre-release, following the precedence rules of semantic
+// versioning, and returns -1, 0 or +1. Build metadata is ignored.
+func CompareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	if i := strings.Index(a, "+"); i >= 0 {
+		a = a[:i]
+	}
+	if i := strings.Index(b, "+"); i >= 0 {
+		b = b[:i]
+	}
+	aCore, aPre := splitPrerelease(a)
+	bCore, bPre := splitPrerelease(b)
+	if c := compareIdentifiers(strings.Split(aCore, "."), strings.Split(bCore, ".")); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareIdentifiers(strings.Split(aPre, "."), strings.Split(bPre, "."))
+}
+
+func splitPrerelease(v string) (string, string) {
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// compareIdentifiers compares dot separated identifiers, numerically if both
+// are numbers. A shorter list of otherwise equal identifiers is lower.
+func compareIdentifiers(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.Atoi(a[i])
+		bn, bErr := strconv.Atoi(b[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.0.0", "v1.0.0", 0},
+		{"v1.0.0", "v1.0.1", -1},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"1.0.0", "v1.0.0", 0},
+
+		// pre-releases, in the order of the semantic versioning spec
+		{"v1.0.0-alpha", "v1.0.0", -1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", -1},
+		{"v1.0.0-alpha.beta", "v1.0.0-beta", -1},
+		{"v1.0.0-beta", "v1.0.0-beta.2", -1},
+		{"v1.0.0-beta.2", "v1.0.0-beta.11", -1},
+		{"v1.0.0-beta.11", "v1.0.0-rc.1", -1},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.1-rc.1", "v1.0.0", 1},
+
+		// build metadata, such as +incompatible, is ignored
+		{"v2.0.0+incompatible", "v2.0.0", 0},
+		{"v2.0.1+incompatible", "v2.0.0+incompatible", 1},
+		{"v3.0.0+incompatible", "v2.9.9", 1},
+		{"v2.0.0-rc.1+incompatible", "v2.0.0+incompatible", -1},
+
+		// pseudo-versions without a base version order by commit time
+		{"v0.0.0-20191109021931-daa7c04131f5", "v0.0.0-20201107204938-8bf34a254118", -1},
+		{"v0.0.0-20201107204938-8bf34a254118", "v0.1.0", -1},
+		// pseudo-versions based on a release sort after it and before the
+		// next patch release
+		{"v1.2.4-0.20191109021931-daa7c04131f5", "v1.2.3", 1},
+		{"v1.2.4-0.20191109021931-daa7c04131f5", "v1.2.4", -1},
+		{"v1.2.4-0.20191109021931-daa7c04131f5", "v1.2.4-0.20201107204938-8bf34a254118", -1},
+		// pseudo-versions based on a pre-release sort after it
+		{"v1.2.3-pre.0.20191109021931-daa7c04131f5", "v1.2.3-pre", 1},
+		{"v1.2.3-pre.0.20191109021931-daa7c04131f5", "v1.2.3", -1},
+		{"v2.0.0-20191109021931-daa7c04131f5+incompatible", "v2.0.0-20201107204938-8bf34a254118+incompatible", -1},
+	}
+	for _, c := range cases {
+		if got := CompareVersions(c.a, c.b); got != c.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := CompareVersions(c.b, c.a); got != -c.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", c.b, c.a, got, -c.want)
+		}
+	}
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	cases := []struct {
+		v        string
+		pseudo   bool
+		revision string
+	}{
+		// vX.0.0-yyyymmddhhmmss-abcdefabcdef, no earlier tagged version
+		{"v0.0.0-20191109021931-daa7c04131f5", true, "daa7c04131f5"},
+		{"v2.0.0-20191109021931-daa7c04131f5+incompatible", true, "daa7c04131f5"},
+		// vX.Y.(Z+1)-0.yyyymmddhhmmss-abcdefabcdef, based on release vX.Y.Z
+		{"v1.2.4-0.20191109021931-daa7c04131f5", true, "daa7c04131f5"},
+		// vX.Y.Z-pre.0.yyyymmddhhmmss-abcdefabcdef, based on vX.Y.Z-pre
+		{"v1.2.3-rc.1.0.20191109021931-daa7c04131f5", true, "daa7c04131f5"},
+		{"v1.2.3-pre.0.20191109021931-daa7c04131f5+incompatible", true, "daa7c04131f5"},
+
+		{"v1.2.3", false, ""},
+		{"v1.2.3-rc.1", false, ""},
+		{"v2.0.0+incompatible", false, ""},
+		{"v1.2.3-20191109021931", false, ""},
+		{"v1.2.4-1.20191109021931-daa7c04131f5", false, ""},
+		{"v1.2.3-pre-20191109021931-daa7c04131f5", false, ""},
+		{"v1.0.0-2019110902193-daa7c04131f5", false, ""},
+		{"", false, ""},
+	}
+	for _, c := range cases {
+		if got := IsPseudoVersion(c.v); got != c.pseudo {
+			t.Errorf("IsPseudoVersion(%q) = %v, want %v", c.v, got, c.pseudo)
+		}
+		revision, ok := PseudoVersionRevision(c.v)
+		if ok != c.pseudo || revision != c.revision {
+			t.Errorf("PseudoVersionRevision(%q) = %q, %v, want %q, %v", c.v, revision, ok, c.revision, c.pseudo)
+		}
+	}
+}
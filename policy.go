@@ -0,0 +1,176 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// licensePolicy lists the SPDX identifiers a release may or may not ship.
+// If Allow is set, every license not in it is forbidden as well.
+type licensePolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// policyViolation is an entry of bom_policy.json.
+type policyViolation struct {
+	Project string `json:"project"`
+	Version string `json:"version,omitempty"`
+	License string `json:"license"`
+	Rule    string `json:"rule"`
+}
+
+type policyReport struct {
+	Policy     string            `json:"policy"`
+	Violations []policyViolation `json:"violations"`
+}
+
+func readPolicy(filename string) (*licensePolicy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var p licensePolicy
+	if err := json.Unmarshal(stripJSONC(data), &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", filename, err)
+	}
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return nil, fmt.Errorf("policy file %s lists neither allowed nor denied licenses", filename)
+	}
+	for i, id := range p.Allow {
+		p.Allow[i] = policyID(merge.SplitException(id))
+	}
+	for i, id := range p.Deny {
+		p.Deny[i] = policyID(merge.SplitException(id))
+	}
+	return &p, nil
+}
+
+// policyID spells a license as policies list it, with its exception as in
+// "GPL-2.0 WITH Classpath-exception-2.0".
+func policyID(license, exception string) string {
+	id := merge.CanonicalID(license)
+	if exception != "" {
+		id += " WITH " + merge.CanonicalID(exception)
+	}
+	return id
+}
+
+// rule returns the rule forbidding lic, deny or allow, or an empty string
+// if the license may be shipped. A license with an exception is matched as
+// a whole first; it is also denied with its bare license, unless the
+// policy allows the combination explicitly.
+func (p *licensePolicy) rule(lic merge.License) string {
+	id := policyID(lic.Type, lic.Exception)
+	switch {
+	case listed(p.Deny, id):
+		return "deny"
+	case listed(p.Allow, id):
+		return ""
+	case lic.Exception != "" && listed(p.Deny, policyID(lic.Type, "")):
+		return "deny"
+	case len(p.Allow) == 0:
+		return ""
+	}
+	return "allow"
+}
+
+func listed(ids []string, id string) bool {
+	for _, x := range ids {
+		if strings.EqualFold(x, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAny reports whether the policy permits one of licenses, which is
+// enough for a choice between them.
+func (p *licensePolicy) allowsAny(licenses []merge.License) bool {
+	for _, lic := range licenses {
+		if p.rule(lic) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePolicy records every license of a merged, not waived entry that
+// the policy forbids, unless the entry offers a choice of licenses one of
+// which is permitted. Entries without a license are left to
+// --min-license-coverage.
+func (m *merger) evaluatePolicy(policy *licensePolicy) {
+	m.policyViolations = []policyViolation{}
+	for _, p := range m.bom.Projects() {
+		if p.Waiver != nil || (p.LicenseExpression != "" && policy.allowsAny(p.Licenses)) {
+			continue
+		}
+		for _, lic := range p.Licenses {
+			rule := policy.rule(lic)
+			if rule == "" {
+				continue
+			}
+			id := policyID(lic.Type, lic.Exception)
+			m.policyViolations = append(m.policyViolations, policyViolation{
+				Project: p.Project,
+				Version: p.Version,
+				License: id,
+				Rule:    rule,
+			})
+			if rule == "deny" {
+				m.tracef(p.Project, "license %s is denied by the policy", id)
+			} else {
+				m.tracef(p.Project, "license %s is not allowed by the policy", id)
+			}
+		}
+	}
+}
+
+func (m *merger) writePolicyReport(filename string) error {
+	data, err := MarshalJson(policyReport{
+		Policy:     filepath.Base(m.opts.PolicyFile),
+		Violations: m.policyViolations,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// checkPolicy fails the merge if the policy forbids a license of a merged
+// entry.
+func (m *merger) checkPolicy() error {
+	if len(m.policyViolations) == 0 {
+		m.auditf("policy", "", "policy-file passed")
+		return nil
+	}
+	var msgs []string
+	for _, v := range m.policyViolations {
+		m.auditf("policy", v.Project, "license %s violates the %s list of %s", v.License, v.Rule, m.opts.PolicyFile)
+		msgs = append(msgs, fmt.Sprintf("%s (%s, %s)", v.Project, v.License, v.Rule))
+	}
+	err := fmt.Errorf("%d license policy violations found: %s", len(m.policyViolations), strings.Join(msgs, ", "))
+	m.auditf("policy", "", "policy-file failed: %v", err)
+	return err
+}
@@ -0,0 +1,108 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+func TestLicensePolicyExceptions(t *testing.T) {
+	gpl := merge.License{Type: "GPL-2.0"}
+	classpath := merge.License{Type: "GPL-2.0", Exception: "Classpath-exception-2.0"}
+	gcc := merge.License{Type: "GPL-2.0", Exception: "GCC-exception-2.0"}
+	mit := merge.License{Type: "MIT"}
+
+	cases := []struct {
+		name   string
+		policy string
+		want   map[merge.License]string
+	}{
+		{
+			name:   "deny bare license",
+			policy: `{"deny": ["GPL-2.0"]}`,
+			want:   map[merge.License]string{gpl: "deny", classpath: "deny", mit: ""},
+		},
+		{
+			name:   "deny with an allowed exception",
+			policy: `{"deny": ["GPL-2.0"], "allow": ["MIT", "gpl-2.0 with classpath-exception-2.0"]}`,
+			want:   map[merge.License]string{gpl: "deny", classpath: "", gcc: "deny", mit: ""},
+		},
+		{
+			name:   "deny an exception only",
+			policy: `{"deny": ["GPL-2.0 WITH Classpath-exception-2.0"]}`,
+			want:   map[merge.License]string{gpl: "", classpath: "deny", gcc: ""},
+		},
+		{
+			name:   "deprecated combined identifier",
+			policy: `{"deny": ["GPL-2.0-with-classpath-exception"]}`,
+			want:   map[merge.License]string{gpl: "", classpath: "deny"},
+		},
+		{
+			name:   "allow an exception",
+			policy: `{"allow": ["MIT", "GPL-2.0 WITH Classpath-exception-2.0"]}`,
+			want:   map[merge.License]string{gpl: "allow", classpath: "", gcc: "allow", mit: ""},
+		},
+		{
+			name:   "allow bare license",
+			policy: `{"allow": ["GPL-2.0"]}`,
+			want:   map[merge.License]string{gpl: "", classpath: "allow"},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "bom-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "policy.json")
+	for _, c := range cases {
+		if err := ioutil.WriteFile(filename, []byte(c.policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		policy, err := readPolicy(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for lic, want := range c.want {
+			if got := policy.rule(lic); got != want {
+				t.Errorf("%s: rule(%s) = %q, want %q", c.name, policyID(lic.Type, lic.Exception), got, want)
+			}
+		}
+	}
+}
+
+func TestEvaluatePolicyExceptions(t *testing.T) {
+	m := &merger{bom: merge.NewRegistry()}
+	m.bom.Set(merge.Project{Project: "example.com/classpath", Licenses: []merge.License{{Type: "GPL-2.0", Exception: "Classpath-exception-2.0"}}})
+	m.bom.Set(merge.Project{Project: "example.com/gpl", Licenses: []merge.License{{Type: "GPL-2.0"}}})
+
+	m.evaluatePolicy(&licensePolicy{Deny: []string{"GPL-2.0"}, Allow: []string{"GPL-2.0 WITH Classpath-exception-2.0"}})
+	want := []policyViolation{{Project: "example.com/gpl", License: "GPL-2.0", Rule: "deny"}}
+	if len(m.policyViolations) != 1 || m.policyViolations[0] != want[0] {
+		t.Errorf("violations = %+v, want %+v", m.policyViolations, want)
+	}
+
+	m.evaluatePolicy(&licensePolicy{Deny: []string{"GPL-2.0"}})
+	if len(m.policyViolations) != 2 || m.policyViolations[0].License != "GPL-2.0 WITH Classpath-exception-2.0" {
+		t.Errorf("violations = %+v, want both projects denied", m.policyViolations)
+	}
+}
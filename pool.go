@@ -0,0 +1,146 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxAutoWorkers caps the number of workers of a tuned pool, which mostly
+// matters for the VCS lookups that keep growing as long as the hosts answer
+// just as fast.
+const maxAutoWorkers = 64
+
+// minRoundTasks keeps the rounds of a tuned pool long enough for their
+// throughput to be measured reliably.
+const minRoundTasks = 16
+
+// runPool calls task for every index below n with the given number of
+// workers and returns the number of workers it used at most. With workers 0
+// the number is tuned, see poolTuner.
+func runPool(n, workers int, task func(i int)) int {
+	if n == 0 {
+		return 0
+	}
+	var tuner *poolTuner
+	if workers <= 0 {
+		tuner = newPoolTuner()
+		workers = tuner.workers
+	}
+
+	var next, started int64 = -1, 0
+	var wg sync.WaitGroup
+	var spawn func(k int)
+	spawn = func(k int) {
+		for ; k > 0; k-- {
+			// no more workers than tasks
+			if int(atomic.AddInt64(&started, 1)) > n {
+				atomic.AddInt64(&started, -1)
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					i := int(atomic.AddInt64(&next, 1))
+					if i >= n {
+						return
+					}
+					task(i)
+					if tuner != nil {
+						spawn(tuner.observe())
+					}
+				}
+			}()
+		}
+	}
+	spawn(workers)
+	wg.Wait()
+	return int(started)
+}
+
+// poolTuner sizes a pool by the observed latency of its tasks. It starts
+// with GOMAXPROCS workers and doubles them after every round of twice as
+// many tasks as there are workers, but at least minRoundTasks, as long as
+// the throughput of the round, tasks completed per second, rises by at
+// least a tenth. Tasks bound by the CPU stop growing right away, since more
+// workers only make each task slower, while tasks waiting on the network
+// grow until their latency rises with the load or maxAutoWorkers is
+// reached.
+type poolTuner struct {
+	mu      sync.Mutex
+	workers int
+	settled bool
+
+	round time.Time
+	tasks int
+	best  float64
+}
+
+func newPoolTuner() *poolTuner {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > maxAutoWorkers {
+		workers = maxAutoWorkers
+	}
+	return &poolTuner{workers: workers, round: time.Now()}
+}
+
+// observe records a completed task and returns the number of workers to
+// add.
+func (t *poolTuner) observe() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.settled {
+		return 0
+	}
+	t.tasks++
+	if t.tasks < minRoundTasks || t.tasks < 2*t.workers {
+		return 0
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(t.round)
+	if elapsed < time.Microsecond {
+		elapsed = time.Microsecond
+	}
+	throughput := float64(t.tasks) / elapsed.Seconds()
+	t.round, t.tasks = now, 0
+	if throughput < t.best*1.1 || t.workers >= maxAutoWorkers {
+		t.settled = true
+		return 0
+	}
+	t.best = throughput
+	grow := t.workers
+	if t.workers+grow > maxAutoWorkers {
+		grow = maxAutoWorkers - t.workers
+	}
+	t.workers += grow
+	return grow
+}
+
+// recordWorkers keeps the most workers a stage ran with, for the report.
+func (m *merger) recordWorkers(stage string, n int) {
+	if m.workers == nil {
+		m.workers = map[string]int{}
+	}
+	if n > m.workers[stage] {
+		m.workers[stage] = n
+	}
+}
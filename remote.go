@@ -0,0 +1,292 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// remoteSchemes are the URL schemes --in accepts to download fragments
+// from, instead of reading them from a local directory.
+var remoteSchemes = []string{"https://", "http://", "s3://", "gs://"}
+
+// remoteInputs returns the URLs of a comma separated --in, or false if it
+// is not a list of URLs.
+func remoteInputs(in string) ([]string, bool) {
+	if in == "" {
+		return nil, false
+	}
+	urls := strings.Split(in, ",")
+	for i, u := range urls {
+		urls[i] = strings.TrimSpace(u)
+		if !isRemoteInput(urls[i]) {
+			return nil, false
+		}
+	}
+	return urls, true
+}
+
+func isRemoteInput(s string) bool {
+	for _, scheme := range remoteSchemes {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteClient downloads fragments and archives of fragments published as
+// release artifacts. s3:// URLs are signed with the AWS credentials of the
+// environment and gs:// URLs with GOOGLE_OAUTH_ACCESS_TOKEN, if set;
+// otherwise objects must be public.
+type remoteClient struct {
+	client *http.Client
+
+	s3Endpoint  string
+	awsRegion   string
+	awsKeyID    string
+	awsSecret   string
+	awsSession  string
+	gcsEndpoint string
+	gcsToken    string
+}
+
+func newRemoteClient() *remoteClient {
+	c := &remoteClient{
+		client:      &http.Client{Timeout: 5 * time.Minute},
+		s3Endpoint:  firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		awsRegion:   firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		awsKeyID:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		awsSecret:   os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		awsSession:  os.Getenv("AWS_SESSION_TOKEN"),
+		gcsEndpoint: "https://storage.googleapis.com",
+		gcsToken:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+	}
+	if c.awsRegion == "" {
+		c.awsRegion = "us-east-1"
+	}
+	// like the Google client libraries, talk to an emulator if one is set
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		c.gcsEndpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(c.gcsEndpoint, "://") {
+			c.gcsEndpoint = "http://" + c.gcsEndpoint
+		}
+	}
+	return c
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Fragments downloads rawurl and returns it as a fragment named by the URL,
+// or the entries of an archive, which is told by the extension of the URL
+// path, named like those of local archives.
+func (c *remoteClient) Fragments(rawurl string) ([]sourceFragment, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme == "s3" || u.Scheme == "gs") && (u.Host == "" || strings.Trim(u.Path, "/") == "") {
+		return nil, fmt.Errorf("invalid object URL %s, must be %s://BUCKET/OBJECT", rawurl, u.Scheme)
+	}
+	data, err := c.download(u)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	if isArchive(u.Path) {
+		fragments, err := readArchiveData(u.Path, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %v", rawurl, err)
+		}
+		for i := range fragments {
+			fragments[i].Source = rawurl + strings.TrimPrefix(fragments[i].Source, u.Path)
+		}
+		return fragments, nil
+	}
+	return []sourceFragment{{Source: rawurl, Data: data}}, nil
+}
+
+func (c *remoteClient) download(u *url.URL) ([]byte, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	var req *http.Request
+	var err error
+	switch u.Scheme {
+	case "s3":
+		req, err = http.NewRequest(http.MethodGet, c.s3ObjectURL(bucket, key), nil)
+		if err == nil && c.awsKeyID != "" {
+			c.signS3(req, time.Now().UTC())
+		}
+	case "gs":
+		req, err = http.NewRequest(http.MethodGet, c.gcsEndpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o/"+url.PathEscape(key)+"?alt=media", nil)
+		if err == nil && c.gcsToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.gcsToken)
+		}
+	default:
+		req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// s3ObjectURL addresses the bucket by host name on AWS and by path on a
+// custom endpoint, e.g. MinIO.
+func (c *remoteClient) s3ObjectURL(bucket, key string) string {
+	if c.s3Endpoint != "" {
+		return strings.TrimSuffix(c.s3Endpoint, "/") + "/" + bucket + "/" + awsEscapePath(key)
+	}
+	return "https://" + bucket + ".s3." + c.awsRegion + ".amazonaws.com/" + awsEscapePath(key)
+}
+
+// signS3 adds an AWS Signature Version 4 to a GET request without body.
+func (c *remoteClient) signS3(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := hex.EncodeToString(sha256Sum(nil))
+
+	headers := [][2]string{
+		{"host", req.URL.Host},
+		{"x-amz-content-sha256", payloadHash},
+		{"x-amz-date", amzDate},
+	}
+	if c.awsSession != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", c.awsSession})
+	}
+	var canonicalHeaders strings.Builder
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		canonicalHeaders.WriteString(h[0] + ":" + h[1] + "\n")
+		names[i] = h[0]
+		if h[0] != "host" {
+			req.Header.Set(h[0], h[1])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.awsRegion + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sha256Sum([]byte(canonicalRequest)))
+
+	key := []byte("AWS4" + c.awsSecret)
+	for _, part := range []string{day, c.awsRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.awsKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// awsEscapePath escapes an object key as AWS expects in the canonical
+// request: everything but unreserved characters and slashes.
+func awsEscapePath(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// remoteObjectName is the name of the object a URL points to, matched by
+// --include and --exclude.
+func remoteObjectName(rawurl string) string {
+	if u, err := url.Parse(rawurl); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawurl)
+}
+
+// loadRemote downloads the --in URLs with a pool of --load-workers workers
+// and loads their fragments in order of the URLs. Like local files, the
+// object names and archive entries are matched by --include and --exclude.
+func (m *merger) loadRemote(urls []string, filter inputFilter) error {
+	if m.opts.Recursive {
+		return errors.New("--recursive can not be used with URLs in --in")
+	}
+	fragments := make([][]sourceFragment, len(urls))
+	errs := make([]error, len(urls))
+	m.recordWorkers("load", runPool(len(urls), loadWorkers, func(i int) {
+		fragments[i], errs[i] = m.res.remote.Fragments(urls[i])
+	}))
+
+	var matched []sourceFragment
+	for i, rawurl := range urls {
+		if err := m.skip(errs[i]); err != nil {
+			return err
+		}
+		fs := fragments[i]
+		sort.Slice(fs, func(i, j int) bool { return fs[i].Source < fs[j].Source })
+		for _, f := range fs {
+			name := remoteObjectName(rawurl)
+			if strings.HasPrefix(f.Source, rawurl+"!") {
+				name = strings.TrimPrefix(f.Source, rawurl+"!")
+			}
+			if filter.Match(name) {
+				matched = append(matched, f)
+			}
+		}
+	}
+	return m.loadSourceFragments(matched)
+}
@@ -0,0 +1,253 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// runReport summarizes a merge for operators, written to bom_report.json
+// with --write-report.
+type runReport struct {
+	Started  time.Time      `json:"started"`
+	Duration string         `json:"duration"`
+	Inputs   int            `json:"inputs"`
+	Outputs  map[string]int `json:"outputs"`
+
+	// LicenseCoverage is the percentage of entries with a license detected
+	// with at least --min-license-confidence.
+	LicenseCoverage float64 `json:"licenseCoverage"`
+
+	// LicenseList is the version of the SPDX license list identifiers were
+	// normalized with.
+	LicenseList string `json:"licenseList"`
+
+	// DeclaredLicenseMismatches counts the entries whose detected license
+	// differs from the one declared on deps.dev.
+	DeclaredLicenseMismatches int `json:"declaredLicenseMismatches,omitempty"`
+
+	// RepoLicenseMismatches lists the entries whose detected license differs
+	// from the one GitHub shows for their repository.
+	RepoLicenseMismatches []string `json:"repoLicenseMismatches,omitempty"`
+
+	// ModifiedLicenses lists the entries whose license text deviates from
+	// the canonical text, found with --detect-modified-licenses.
+	ModifiedLicenses []string `json:"modifiedLicenses,omitempty"`
+
+	// Waivers counts the entries exempted from policy checks by a waiver,
+	// ExpiredWaivers the waivers that matched but were no longer valid.
+	Waivers        int `json:"waivers,omitempty"`
+	ExpiredWaivers int `json:"expiredWaivers,omitempty"`
+
+	// Overrides counts the entries replaced by every override file.
+	Overrides map[string]int `json:"overrides,omitempty"`
+
+	// UnusedOverrides lists the overrides that matched no project, with
+	// the file that supplied them.
+	UnusedOverrides []string `json:"unusedOverrides,omitempty"`
+
+	// NewProjects lists the entries first recorded in the history by this
+	// run, with --history-dir.
+	NewProjects []string `json:"newProjects,omitempty"`
+
+	// SkippedErrors lists the errors ignored by --continue-on-error.
+	SkippedErrors []string `json:"skippedErrors,omitempty"`
+
+	// Workers is the most workers the load, vcs and export stages ran
+	// with, tuned unless set by --load-workers, --vcs-workers and
+	// --export-workers.
+	Workers map[string]int `json:"workers,omitempty"`
+
+	// Stages is the time spent in every stage of the pipeline up to
+	// writing the report.
+	Stages map[string]string `json:"stages,omitempty"`
+
+	// ErrorClasses groups the bom_error.json entries by the cause of their
+	// errors, largest first.
+	ErrorClasses []*errorClass `json:"errorClasses,omitempty"`
+
+	VCS []*hostStats `json:"vcs,omitempty"`
+}
+
+// errorClass counts the error entries whose errors have the same cause.
+// Network marks the classes caused by the network or a remote host rather
+// than by the project, which retrying or fixing the infrastructure solves.
+type errorClass struct {
+	Class    string   `json:"class"`
+	Network  bool     `json:"network,omitempty"`
+	Count    int      `json:"count"`
+	Projects []string `json:"projects"`
+}
+
+// errorClasses maps the messages of error entries to a class by the first
+// matching substring, in order, as scanners word the same cause
+// differently. Messages matching none are classed as other.
+var errorClasses = []struct {
+	class    string
+	network  bool
+	patterns []string
+}{
+	{"dns-failure", true, []string{"no such host", "dns", "server misbehaving"}},
+	{"timeout", true, []string{"timeout", "timed out", "deadline exceeded"}},
+	{"connection-failure", true, []string{"connection refused", "connection reset", "tls", "certificate", "unexpected eof"}},
+	{"not-found", true, []string{"404", "meta tag", "go-import", "unrecognized import path"}},
+	{"no-license", false, []string{"license"}},
+}
+
+// classifyError returns the class of an error message and whether it is a
+// network error.
+func classifyError(message string) (string, bool) {
+	message = strings.ToLower(message)
+	for _, c := range errorClasses {
+		for _, p := range c.patterns {
+			if strings.Contains(message, p) {
+				return c.class, c.network
+			}
+		}
+	}
+	return "other", false
+}
+
+// groupErrors groups error entries by class. An entry whose errors have
+// several causes is counted in each class.
+func groupErrors(projects []merge.Project) []*errorClass {
+	byClass := map[string]*errorClass{}
+	for _, p := range projects {
+		messages := []string{p.Error}
+		if len(p.Errors) > 0 {
+			messages = messages[:0]
+			for _, rec := range p.Errors {
+				messages = append(messages, rec.Message)
+			}
+		}
+		seen := map[string]bool{}
+		for _, msg := range messages {
+			class, network := classifyError(msg)
+			if seen[class] {
+				continue
+			}
+			seen[class] = true
+			c, ok := byClass[class]
+			if !ok {
+				c = &errorClass{Class: class, Network: network}
+				byClass[class] = c
+			}
+			c.Count++
+			c.Projects = append(c.Projects, versionedPath(p.Project, p.Version))
+		}
+	}
+	out := make([]*errorClass, 0, len(byClass))
+	for _, c := range byClass {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Class < out[j].Class
+	})
+	return out
+}
+
+// hostStats counts VCS resolutions of the projects hosted on one domain.
+// Resolved and Unresolved count lookups that did or did not find a VCS root,
+// ErrorEntries counts the bom_error.json entries of the host and
+// VCSRedirects the projects whose VCS root is on another host.
+type hostStats struct {
+	Host         string  `json:"host"`
+	Resolved     int     `json:"resolved"`
+	Unresolved   int     `json:"unresolved"`
+	ErrorEntries int     `json:"errorEntries"`
+	VCSRedirects int     `json:"vcsRedirects"`
+	SuccessRate  float64 `json:"successRate"`
+}
+
+// projectHost returns the domain of a project path, e.g. k8s.io.
+func projectHost(project string) string {
+	return strings.SplitN(project, "/", 2)[0]
+}
+
+func (m *merger) hostStats(project string) *hostStats {
+	host := projectHost(project)
+	if m.vcsStats == nil {
+		m.vcsStats = map[string]*hostStats{}
+	}
+	s, ok := m.vcsStats[host]
+	if !ok {
+		s = &hostStats{Host: host}
+		m.vcsStats[host] = s
+	}
+	return s
+}
+
+func (m *merger) recordVCSResolution(project string, resolved bool) {
+	s := m.hostStats(project)
+	if resolved {
+		s.Resolved++
+	} else {
+		s.Unresolved++
+	}
+}
+
+func (m *merger) writeReport(filename string, outputs map[string]int) error {
+	errorEntries := m.errors.Projects()
+	for _, p := range errorEntries {
+		m.hostStats(p.Project).ErrorEntries++
+	}
+	report := runReport{
+		Started:  m.started,
+		Duration: time.Since(m.started).Round(time.Millisecond).String(),
+		Inputs:   len(m.lib.Inputs),
+		Outputs:  outputs,
+
+		LicenseCoverage: m.licenseCoverage(),
+		LicenseList:     merge.LicenseListVersion(),
+
+		DeclaredLicenseMismatches: m.declaredMismatches,
+		RepoLicenseMismatches:     m.repoMismatches,
+		ModifiedLicenses:          m.modifiedLicenses,
+		Waivers:                   m.waived,
+		ExpiredWaivers:            m.expiredWaivers,
+		Workers:                   m.workers,
+		Stages:                    m.stageDurations(),
+		NewProjects:               m.newProjects(),
+		Overrides:                 m.overridden,
+		UnusedOverrides:           m.unusedOverrides,
+		ErrorClasses:              groupErrors(errorEntries),
+	}
+	for _, err := range m.skipped {
+		report.SkippedErrors = append(report.SkippedErrors, err.Error())
+	}
+	for _, s := range m.vcsStats {
+		if n := s.Resolved + s.Unresolved; n > 0 {
+			s.SuccessRate = float64(s.Resolved) / float64(n)
+		}
+		report.VCS = append(report.VCS, s)
+	}
+	sort.Slice(report.VCS, func(i, j int) bool { return report.VCS[i].Host < report.VCS[j].Host })
+
+	data, err := MarshalJson(report)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// retraction is a retract directive of a go.mod file. Low and High are the
+// same for a single version.
+type retraction struct {
+	Low, High string
+	Rationale string
+}
+
+// retractionChecker looks up the retract directives of the latest version
+// of modules on the module proxy, which apply to all versions of a module.
+// Results are cached per module for the lifetime of the process.
+type retractionChecker struct {
+	proxy  string
+	client *http.Client
+
+	mu      sync.Mutex
+	lookups map[string]*retractionLookup
+}
+
+type retractionLookup struct {
+	done        chan struct{}
+	retractions []retraction
+	err         error
+}
+
+func newRetractionChecker() *retractionChecker {
+	return &retractionChecker{
+		proxy:   goproxyURL(),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		lookups: map[string]*retractionLookup{},
+	}
+}
+
+// Retracted returns the rationale if version of module is retracted, or
+// "retracted" if the directive has none, and an empty string otherwise.
+func (c *retractionChecker) Retracted(module, version string) (string, error) {
+	c.mu.Lock()
+	l, ok := c.lookups[module]
+	if !ok {
+		l = &retractionLookup{done: make(chan struct{})}
+		c.lookups[module] = l
+		c.mu.Unlock()
+
+		l.retractions, l.err = c.lookup(module)
+		close(l.done)
+	} else {
+		c.mu.Unlock()
+		<-l.done
+	}
+	if l.err != nil {
+		return "", l.err
+	}
+	for _, r := range l.retractions {
+		if merge.CompareVersions(r.Low, version) <= 0 && merge.CompareVersions(version, r.High) <= 0 {
+			if r.Rationale == "" {
+				return "retracted", nil
+			}
+			return r.Rationale, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *retractionChecker) lookup(module string) ([]retraction, error) {
+	escMod, err := escapeModulePath(module)
+	if err != nil {
+		return nil, err
+	}
+	data, found, err := c.get(c.proxy + "/" + escMod + "/@latest")
+	if err != nil || !found {
+		return nil, err
+	}
+	var latest struct {
+		Version string
+	}
+	if err := json.Unmarshal(data, &latest); err != nil {
+		return nil, fmt.Errorf("failed to parse latest version of %s: %v", module, err)
+	}
+	escVer, err := escapeModulePath(latest.Version)
+	if err != nil {
+		return nil, err
+	}
+	data, found, err = c.get(c.proxy + "/" + escMod + "/@v/" + escVer + ".mod")
+	if err != nil || !found {
+		return nil, err
+	}
+	return parseRetractions(data), nil
+}
+
+func (c *retractionChecker) get(url string) ([]byte, bool, error) {
+	return proxyGet(c.client, url)
+}
+
+// parseRetractions returns the retract directives of a go.mod file. The
+// rationale is the comment on the directive or the lines right above it.
+func parseRetractions(gomod []byte) []retraction {
+	var out []retraction
+	var comments []string
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(gomod))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		comment := ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+2:])
+		}
+		switch {
+		case line == "":
+			if comment != "" {
+				comments = append(comments, comment)
+			} else {
+				comments = nil
+			}
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+		case line == "retract (":
+			inBlock = true
+		case inBlock || strings.HasPrefix(line, "retract "):
+			spec := strings.TrimSpace(strings.TrimPrefix(line, "retract "))
+			if comment == "" {
+				comment = strings.Join(comments, " ")
+			}
+			if r, ok := parseRetractSpec(spec); ok {
+				r.Rationale = comment
+				out = append(out, r)
+			}
+		}
+		comments = nil
+	}
+	return out
+}
+
+// parseRetractSpec parses "v1.0.0" or "[v1.0.0, v1.0.5]".
+func parseRetractSpec(spec string) (retraction, bool) {
+	if strings.HasPrefix(spec, "[") && strings.HasSuffix(spec, "]") {
+		parts := strings.Split(strings.Trim(spec, "[]"), ",")
+		if len(parts) != 2 {
+			return retraction{}, false
+		}
+		return retraction{Low: strings.TrimSpace(parts[0]), High: strings.TrimSpace(parts[1])}, true
+	}
+	if len(strings.Fields(spec)) != 1 {
+		return retraction{}, false
+	}
+	return retraction{Low: spec, High: spec}, true
+}
@@ -0,0 +1,369 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// sbomFormat detects CycloneDX and SPDX JSON documents, e.g. produced by
+// syft or trivy, among the BOM fragments.
+type sbomFormat struct {
+	BOMFormat   string `json:"bomFormat"`
+	SPDXVersion string `json:"spdxVersion"`
+}
+
+// cdxInput is the part of a CycloneDX document read as input. Components
+// may be nested.
+type cdxInput struct {
+	Components  []cdxInputComponent `json:"components"`
+	Annotations []cdxAnnotation     `json:"annotations"`
+}
+
+type cdxInputComponent struct {
+	BOMRef             string              `json:"bom-ref"`
+	Group              string              `json:"group"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version"`
+	Purl               string              `json:"purl"`
+	Licenses           []cdxInputLicense   `json:"licenses"`
+	ExternalReferences []cdxExternalRef    `json:"externalReferences"`
+	Components         []cdxInputComponent `json:"components"`
+}
+
+type cdxInputLicense struct {
+	License *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"license"`
+	Expression string `json:"expression"`
+}
+
+// parseSBOM converts a CycloneDX or SPDX JSON document to a BOM fragment.
+// It reports false if data is neither.
+func parseSBOM(filename string, data []byte) (*merge.Document, bool, error) {
+	var format sbomFormat
+	if err := json.Unmarshal(data, &format); err != nil {
+		return nil, false, nil
+	}
+	switch {
+	case format.BOMFormat == "CycloneDX":
+		var in cdxInput
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, true, fmt.Errorf("failed to parse CycloneDX document %s: %v", filename, err)
+		}
+		notes := map[string][]merge.Annotation{}
+		for _, a := range in.Annotations {
+			for _, ref := range a.Subjects {
+				notes[ref] = append(notes[ref], merge.Annotation{
+					Annotator: annotatorOfCDX(a.Annotator),
+					Date:      a.Timestamp,
+					Text:      a.Text,
+				})
+			}
+		}
+		doc := &merge.Document{Version: merge.EnvelopeVersion}
+		addCycloneDXComponents(doc, in.Components, notes)
+		return doc, true, nil
+	case format.SPDXVersion != "":
+		var in spdxDocument
+		if err := json.Unmarshal(data, &in); err != nil {
+			return nil, true, fmt.Errorf("failed to parse SPDX document %s: %v", filename, err)
+		}
+		return fromSPDX(in), true, nil
+	}
+	return nil, false, nil
+}
+
+// addCycloneDXComponents adds components and their nested components to
+// doc, with the annotations in notes whose subject they are.
+func addCycloneDXComponents(doc *merge.Document, components []cdxInputComponent, notes map[string][]merge.Annotation) {
+	for _, c := range components {
+		name := c.Name
+		if c.Group != "" {
+			name = c.Group + "/" + c.Name
+		}
+		p := sbomProject(name, c.Version, c.Purl)
+		for _, lic := range c.Licenses {
+			switch {
+			case lic.Expression != "":
+				p.Licenses = append(p.Licenses, licensesOfExpression(lic.Expression, nil)...)
+			case lic.License != nil && lic.License.ID != "":
+				p.Licenses = append(p.Licenses, merge.License{Type: lic.License.ID})
+			case lic.License != nil && lic.License.Name != "":
+				p.Licenses = append(p.Licenses, merge.License{Type: lic.License.Name})
+			}
+		}
+		for _, ref := range c.ExternalReferences {
+			if ref.Type == "vcs" {
+				p.VCS = vcsRootOf(ref.URL)
+				break
+			}
+		}
+		if c.BOMRef != "" {
+			p.Annotations = notes[c.BOMRef]
+		}
+		doc.Projects = append(doc.Projects, p)
+		addCycloneDXComponents(doc, c.Components, notes)
+	}
+}
+
+// fromSPDX converts the packages of an SPDX document. Described packages
+// without a package URL, such as the directory or image syft scanned, are
+// the subject of the document rather than dependencies and are skipped.
+func fromSPDX(in spdxDocument) *merge.Document {
+	described := map[string]bool{}
+	for _, id := range in.DocumentDescribes {
+		described[id] = true
+	}
+	for _, r := range in.Relationships {
+		if r.SPDXElementID == in.SPDXID && r.RelationshipType == "DESCRIBES" {
+			described[r.RelatedSPDXElement] = true
+		}
+	}
+	refs := map[string]string{}
+	for _, lic := range in.ExtractedLicenses {
+		if lic.Name != "" && lic.Name != merge.LicenseNoAssertion {
+			refs[lic.LicenseID] = lic.Name
+		}
+	}
+
+	doc := &merge.Document{Version: merge.EnvelopeVersion}
+	for _, pkg := range in.Packages {
+		purl := ""
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				purl = ref.ReferenceLocator
+				break
+			}
+		}
+		if purl == "" && described[pkg.SPDXID] {
+			continue
+		}
+		p := sbomProject(pkg.Name, pkg.VersionInfo, purl)
+		license := pkg.LicenseConcluded
+		if license == "" || license == merge.LicenseNoAssertion {
+			license = pkg.LicenseDeclared
+		}
+		if license != "" {
+			p.Licenses = licensesOfExpression(license, refs)
+		}
+		if loc := pkg.DownloadLocation; loc != "" && loc != merge.LicenseNoAssertion && loc != merge.LicenseNone {
+			p.VCS = vcsRootOf(loc)
+		}
+		p.Annotations = annotationsOfSPDX(pkg)
+		doc.Projects = append(doc.Projects, p)
+	}
+	return doc
+}
+
+// sbomProject names a component by its Go module path if it is a Go module
+// and by its name otherwise.
+func sbomProject(name, version, purl string) merge.Project {
+	p := merge.Project{Project: name, Version: version}
+	if !strings.HasPrefix(purl, "pkg:golang/") {
+		return p
+	}
+	purl = strings.TrimPrefix(purl, "pkg:golang/")
+	if i := strings.IndexAny(purl, "?#"); i >= 0 {
+		purl = purl[:i]
+	}
+	if i := strings.LastIndex(purl, "@"); i >= 0 {
+		if v, err := url.PathUnescape(purl[i+1:]); err == nil && p.Version == "" {
+			p.Version = v
+		}
+		purl = purl[:i]
+	}
+	if path, err := url.PathUnescape(purl); err == nil {
+		p.Project = path
+	}
+	return p
+}
+
+var licenseExpressionOp = regexp.MustCompile(`(?i)\s+(?:AND|OR)\s+`)
+
+// licensesOfExpression returns the terms of an SPDX license expression as
+// licenses, keeping WITH exceptions. LicenseRef- identifiers are replaced by
+// the names in refs.
+func licensesOfExpression(expr string, refs map[string]string) []merge.License {
+	expr = strings.NewReplacer("(", " ", ")", " ").Replace(expr)
+	var licenses []merge.License
+	for _, term := range licenseExpressionOp.Split(strings.TrimSpace(expr), -1) {
+		term = strings.Join(strings.Fields(term), " ")
+		if name, ok := refs[term]; ok {
+			term = name
+		}
+		if term != "" {
+			licenses = append(licenses, merge.License{Type: term})
+		}
+	}
+	return licenses
+}
+
+// vcsRootOf turns a VCS URL like git+https://github.com/spf13/pflag.git@v1
+// into a VCS root like github.com/spf13/pflag.
+func vcsRootOf(location string) string {
+	location = strings.TrimPrefix(location, "git+")
+	if i := strings.Index(location, "://"); i >= 0 {
+		location = location[i+3:]
+	}
+	if i := strings.IndexAny(location, "#?"); i >= 0 {
+		location = location[:i]
+	}
+	if i := strings.LastIndex(location, "@"); i > strings.LastIndex(location, "/") {
+		location = location[:i]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(location, "/"), ".git")
+}
+
+// isSPDXTagValue reports whether data is an SPDX tag-value document.
+func isSPDXTagValue(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("SPDXVersion:"))
+}
+
+// parseSPDXTagValue reads the fields of an SPDX tag-value document that
+// fromSPDX uses. <text> values may span several lines.
+func parseSPDXTagValue(filename string, data []byte) (*merge.Document, error) {
+	var in spdxDocument
+	var pkg *spdxPackage
+	var ref *spdxExtractedLicense
+	// annotations refer to their package by SPDXREF
+	var notes []spdxAnnotation
+	var noteRefs []string
+
+	var tag, value string
+	inText := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if inText {
+			i := strings.Index(text, "</text>")
+			if i < 0 {
+				value += "\n" + text
+				continue
+			}
+			value += "\n" + text[:i]
+			inText = false
+		} else {
+			text = strings.TrimSpace(text)
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			i := strings.Index(text, ":")
+			if i < 0 {
+				return nil, fmt.Errorf("%s:%d: expected Tag: value, found %q", filename, line, text)
+			}
+			tag, value = text[:i], strings.TrimSpace(text[i+1:])
+			if strings.HasPrefix(value, "<text>") {
+				value = strings.TrimPrefix(value, "<text>")
+				if i := strings.Index(value, "</text>"); i >= 0 {
+					value = value[:i]
+				} else {
+					inText = true
+					continue
+				}
+			}
+		}
+
+		switch tag {
+		case "SPDXVersion":
+			in.SPDXVersion = value
+		case "SPDXID":
+			if pkg != nil {
+				pkg.SPDXID = value
+			} else {
+				in.SPDXID = value
+			}
+		case "PackageName":
+			in.Packages = append(in.Packages, spdxPackage{Name: value})
+			pkg = &in.Packages[len(in.Packages)-1]
+		case "LicenseID":
+			in.ExtractedLicenses = append(in.ExtractedLicenses, spdxExtractedLicense{LicenseID: value})
+			ref, pkg = &in.ExtractedLicenses[len(in.ExtractedLicenses)-1], nil
+		case "LicenseName":
+			if ref != nil {
+				ref.Name = value
+			}
+		case "Relationship":
+			if f := strings.Fields(value); len(f) == 3 {
+				in.Relationships = append(in.Relationships, spdxRelationship{
+					SPDXElementID:      f[0],
+					RelationshipType:   f[1],
+					RelatedSPDXElement: f[2],
+				})
+			}
+		case "Annotator":
+			notes = append(notes, spdxAnnotation{Annotator: value})
+			noteRefs = append(noteRefs, "")
+		}
+		if n := len(notes) - 1; n >= 0 {
+			switch tag {
+			case "AnnotationDate":
+				notes[n].AnnotationDate = value
+			case "AnnotationType":
+				notes[n].AnnotationType = value
+			case "AnnotationComment":
+				notes[n].Comment = value
+			case "SPDXREF":
+				noteRefs[n] = value
+			}
+		}
+		if pkg == nil {
+			continue
+		}
+		switch tag {
+		case "PackageVersion":
+			pkg.VersionInfo = value
+		case "PackageDownloadLocation":
+			pkg.DownloadLocation = value
+		case "PackageLicenseConcluded":
+			pkg.LicenseConcluded = value
+		case "PackageLicenseDeclared":
+			pkg.LicenseDeclared = value
+		case "PackageComment":
+			pkg.Comment = value
+		case "ExternalRef":
+			if f := strings.Fields(value); len(f) == 3 {
+				pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{
+					ReferenceCategory: f[0],
+					ReferenceType:     f[1],
+					ReferenceLocator:  f[2],
+				})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read SPDX document %s: %v", filename, err)
+	}
+	for i, note := range notes {
+		for j := range in.Packages {
+			if in.Packages[j].SPDXID == noteRefs[i] {
+				in.Packages[j].Annotations = append(in.Packages[j].Annotations, note)
+			}
+		}
+	}
+	return fromSPDX(in), nil
+}
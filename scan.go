@@ -0,0 +1,222 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// scanConfidence is the confidence of licenses detected by scan. Licenses
+// are recognized by characteristic phrases rather than compared with the
+// full license text.
+const scanConfidence = 0.9
+
+// licenseSignatures recognize licenses by phrases of their text, which
+// must all occur in a license file. More specific licenses come first,
+// e.g. the LGPL, whose text refers to the GPL.
+var licenseSignatures = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "version 2.0"}},
+	{"EPL-2.0", []string{"eclipse public license - v 2.0"}},
+	{"EPL-1.0", []string{"eclipse public license - v 1.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", []string{"permission to use, copy, modify, and", "distribute this software for any purpose with or without fee"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+	{"Zlib", []string{"provided 'as-is', without any express or implied", "altered source versions must be plainly marked"}},
+}
+
+// licenseFileName matches the names of license files in the root of a
+// module, e.g. LICENSE, LICENSE.md, LICENSE-APACHE or COPYING.
+var licenseFileName = regexp.MustCompile(`(?i)^(un)?licen[cs]e([.-].*)?$|^copying([.-].*)?$`)
+
+// goModule is the part of the output of go list -m -json read by scan.
+type goModule struct {
+	Path    string
+	Version string
+	Main    bool
+	Dir     string
+	Replace *goModule
+}
+
+// runScan writes a BOM fragment for the dependencies of the Go module in a
+// directory, with the licenses detected from the module cache.
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	out := fs.String("out", "", "File to write the BOM fragment to (defaults to stdout)")
+	_ = fs.Parse(args)
+	if fs.NArg() > 1 {
+		return errors.New("usage: bom-merger scan [--out=FILE] [DIR]")
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	modules, err := goModules(dir)
+	if err != nil {
+		return err
+	}
+	doc := merge.Document{Version: merge.EnvelopeVersion, Projects: []merge.Project{}}
+	for _, mod := range modules {
+		p, err := scanModule(dir, mod)
+		if err != nil {
+			doc.Errors = append(doc.Errors, merge.Project{Project: mod.Path, Version: mod.Version, Error: err.Error()})
+			continue
+		}
+		doc.Projects = append(doc.Projects, p)
+	}
+
+	data, err := MarshalJson(doc)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(*out, data, 0644)
+}
+
+// goModules lists the dependencies of the main module in dir with go list
+// -m all.
+func goModules(dir string) ([]goModule, error) {
+	data, err := goCommand(dir, "list", "-m", "-json", "all")
+	if err != nil {
+		return nil, err
+	}
+	var modules []goModule
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var mod goModule
+		if err := decoder.Decode(&mod); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %v", err)
+		}
+		if !mod.Main {
+			modules = append(modules, mod)
+		}
+	}
+	return modules, nil
+}
+
+// scanModule detects the licenses of a module from the license files in
+// its root, downloading it to the module cache if needed.
+func scanModule(dir string, mod goModule) (merge.Project, error) {
+	p := merge.Project{Project: mod.Path, Version: mod.Version}
+	if mod.Dir == "" {
+		src := mod
+		if mod.Replace != nil {
+			src = *mod.Replace
+		}
+		if src.Version == "" {
+			return p, errors.New("module is not in the module cache")
+		}
+		data, err := goCommand(dir, "mod", "download", "-json", src.Path+"@"+src.Version)
+		if err != nil {
+			return p, err
+		}
+		if err := json.Unmarshal(data, &mod); err != nil || mod.Dir == "" {
+			return p, fmt.Errorf("failed to download %s@%s", src.Path, src.Version)
+		}
+	}
+
+	files, err := ioutil.ReadDir(mod.Dir)
+	if err != nil {
+		return p, err
+	}
+	var unknown []string
+	for _, fi := range files {
+		if fi.IsDir() || !licenseFileName.MatchString(fi.Name()) {
+			continue
+		}
+		text, err := ioutil.ReadFile(filepath.Join(mod.Dir, fi.Name()))
+		if err != nil {
+			return p, err
+		}
+		id := detectLicense(text)
+		if id == "" {
+			unknown = append(unknown, fi.Name())
+			continue
+		}
+		if !hasLicense(p.Licenses, id) {
+			p.Licenses = append(p.Licenses, merge.License{Type: id, Confidence: scanConfidence})
+		}
+	}
+	switch {
+	case len(p.Licenses) > 0:
+		sort.Slice(p.Licenses, func(i, j int) bool { return p.Licenses[i].Type < p.Licenses[j].Type })
+		return p, nil
+	case len(unknown) > 0:
+		return p, fmt.Errorf("unknown license in %s", strings.Join(unknown, ", "))
+	default:
+		return p, errors.New("cannot find license file")
+	}
+}
+
+// detectLicense returns the SPDX identifier of the license text, or an
+// empty string if it matches no known license.
+func detectLicense(text []byte) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(string(text))), " ")
+	for _, sig := range licenseSignatures {
+		matched := true
+		for _, phrase := range sig.phrases {
+			if !strings.Contains(normalized, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return sig.id
+		}
+	}
+	return ""
+}
+
+func hasLicense(licenses []merge.License, id string) bool {
+	for _, lic := range licenses {
+		if lic.Type == id {
+			return true
+		}
+	}
+	return false
+}
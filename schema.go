@@ -0,0 +1,283 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// bomSchema is the JSON Schema of the native output: bom.json and the
+// other outputs listing entries, such as bom_error.json. It is strict, so
+// a field added to merge.Project must be added here as well.
+const bomSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/appscodelabs/bom-merger/bom.schema.json",
+  "title": "bom-merger BOM",
+  "type": "array",
+  "items": {"$ref": "#/$defs/project"},
+  "$defs": {
+    "project": {
+      "type": "object",
+      "required": ["project"],
+      "additionalProperties": false,
+      "properties": {
+        "project": {"type": "string", "minLength": 1},
+        "version": {"type": "string"},
+        "licenses": {"type": "array", "items": {"$ref": "#/$defs/license"}},
+        "error": {"type": "string"},
+        "vcs": {"type": "string"},
+        "licenseExpression": {"type": "string"},
+        "licenseText": {"type": "string"},
+        "licenseFile": {"type": "string"},
+        "licenseDeviation": {"type": "number", "minimum": 0, "maximum": 1},
+        "licenseModified": {"type": "boolean"},
+        "licenseURL": {"type": "string"},
+        "declaredLicense": {"type": "string"},
+        "licenseMismatch": {"type": "boolean"},
+        "repoLicense": {"type": "string"},
+        "scorecard": {"type": "number", "minimum": 0, "maximum": 10},
+        "dependents": {"type": "integer", "minimum": 0},
+        "licenseStatus": {"enum": ["NOASSERTION", "NONE"]},
+        "pseudoVersion": {"type": "boolean"},
+        "revision": {"type": "string"},
+        "reviewReason": {"type": "string"},
+        "filteredBy": {"type": "string"},
+        "category": {"enum": ["permissive", "weak-copyleft", "copyleft", "unknown"]},
+        "risk": {"type": "integer", "minimum": 0, "maximum": 100},
+        "inactive": {"type": "string"},
+        "vcsRedirect": {"type": "string"},
+        "checksum": {"type": "string", "pattern": "^h1:"},
+        "integrity": {"enum": ["verified", "mismatch", "unknown"]},
+        "retracted": {"type": "string"},
+        "scope": {"type": "string"},
+        "fork": {"type": "string"},
+        "binaries": {"type": "array", "items": {"type": "string"}},
+        "key": {"type": "string"},
+        "aliases": {"type": "array", "items": {"type": "string"}},
+        "waiver": {"$ref": "#/$defs/waiver"},
+        "introducedIn": {"type": "string"},
+        "introducedAt": {"type": "string", "pattern": "^[0-9]{4}-[0-9]{2}-[0-9]{2}$"},
+        "skipVcs": {"type": "boolean"},
+        "skipEnrichment": {"type": "boolean"},
+        "annotations": {"type": "array", "items": {"$ref": "#/$defs/annotation"}},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "errors": {"type": "array", "items": {"$ref": "#/$defs/errorRecord"}}
+      }
+    },
+    "license": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "type": {"type": "string"},
+        "confidence": {"type": "number", "minimum": 0},
+        "exception": {"type": "string"}
+      }
+    },
+    "waiver": {
+      "type": "object",
+      "required": ["approvedBy", "reason"],
+      "additionalProperties": false,
+      "properties": {
+        "license": {"type": "string"},
+        "approvedBy": {"type": "string"},
+        "reason": {"type": "string"},
+        "expires": {"type": "string"}
+      }
+    },
+    "annotation": {
+      "type": "object",
+      "required": ["text"],
+      "additionalProperties": false,
+      "properties": {
+        "annotator": {"type": "string"},
+        "date": {"type": "string"},
+        "type": {"type": "string"},
+        "text": {"type": "string"}
+      }
+    },
+    "errorRecord": {
+      "type": "object",
+      "required": ["message", "count"],
+      "additionalProperties": false,
+      "properties": {
+        "message": {"type": "string"},
+        "count": {"type": "integer", "minimum": 1},
+        "sources": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}
+`
+
+// jsonSchema is the subset of JSON Schema bomSchema is written in.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Pattern              string                 `json:"pattern"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+var parsedBOMSchema = func() *jsonSchema {
+	var s jsonSchema
+	if err := json.Unmarshal([]byte(bomSchema), &s); err != nil {
+		panic(err)
+	}
+	return &s
+}()
+
+// validateOutput checks the native encoding of projects against bomSchema
+// and reports the first violation, named by its JSON path in name.
+func validateOutput(name string, projects []merge.Project) error {
+	data, err := marshalOutput(projects, true)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := parsedBOMSchema.validate(parsedBOMSchema, doc, "$"); err != nil {
+		return fmt.Errorf("%s does not match the output schema: %v", name, err)
+	}
+	return nil
+}
+
+func (s *jsonSchema) validate(root *jsonSchema, v interface{}, path string) error {
+	if s.Ref != "" {
+		def, ok := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema reference %s", path, s.Ref)
+		}
+		return def.validate(root, v, path)
+	}
+	if s.Type != "" && !hasJSONType(v, s.Type) {
+		return fmt.Errorf("%s: %s is not of type %s", path, jsonTypeOf(v), s.Type)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		if s.MinLength != nil && len(x) < *s.MinLength {
+			return fmt.Errorf("%s: %q is shorter than %d", path, x, *s.MinLength)
+		}
+		if s.Pattern != "" {
+			if ok, err := regexp.MatchString(s.Pattern, x); err != nil || !ok {
+				return fmt.Errorf("%s: %q does not match %s", path, x, s.Pattern)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && x < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, x, *s.Minimum)
+		}
+		if s.Maximum != nil && x > *s.Maximum {
+			return fmt.Errorf("%s: %v is greater than the maximum %v", path, x, *s.Maximum)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range x {
+				if err := s.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := x[key]; !ok {
+				return fmt.Errorf("%s: missing required field %s", path, key)
+			}
+		}
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := path + "." + key
+			if prop, ok := s.Properties[key]; ok {
+				if err := prop.validate(root, x[key], field); err != nil {
+					return err
+				}
+				continue
+			}
+			switch string(s.AdditionalProperties) {
+			case "", "true":
+			case "false":
+				return fmt.Errorf("%s: unknown field", field)
+			default:
+				var extra jsonSchema
+				if err := json.Unmarshal(s.AdditionalProperties, &extra); err != nil {
+					return err
+				}
+				if err := extra.validate(root, x[key], field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasJSONType(v interface{}, typ string) bool {
+	if typ == "integer" {
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	return jsonTypeOf(v) == typ
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+const scopeTool = "tool"
+
+// readToolPackages returns the packages declared as tools in a go.mod file
+// by tool directives, or imported by a tools.go file.
+func readToolPackages(filename string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(filename) == ".go" {
+		f, err := parser.ParseFile(token.NewFileSet(), filename, data, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		var pkgs []string
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			pkgs = append(pkgs, path)
+		}
+		return pkgs, nil
+	}
+
+	var pkgs []string
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			pkgs = append(pkgs, fields[0])
+		case fields[0] == "tool" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "tool" && len(fields) == 2:
+			pkgs = append(pkgs, fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filename, err)
+	}
+	return pkgs, nil
+}
+
+// markToolScope sets the scope of every project providing one of the tool
+// packages listed in files to tool. A package belongs to the project with
+// the longest module path that is a prefix of it.
+func (m *merger) markToolScope(files []string) error {
+	var pkgs []string
+	for _, filename := range files {
+		p, err := readToolPackages(filename)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, p...)
+	}
+
+	for _, pkg := range pkgs {
+		var owner merge.Project
+		found := false
+		_ = m.bom.Each(func(p merge.Project) error {
+			if (pkg == p.Project || strings.HasPrefix(pkg, p.Project+"/")) && len(p.Project) > len(owner.Project) {
+				owner, found = p, true
+			}
+			return nil
+		})
+		if found && owner.Scope != scopeTool {
+			m.tracef(owner.Project, "scope set to tool by tool package %s", pkg)
+			owner.Scope = scopeTool
+			m.bom.Set(owner)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runSlice extracts from a merged BOM the projects that one binary ships,
+// given either its dependency list or its main package as recorded with
+// --binaries-from. It reports false if dependencies are missing from the
+// BOM.
+func runSlice(args []string) (bool, error) {
+	fs := flag.NewFlagSet("slice", flag.ExitOnError)
+	depsFile := fs.String("deps", "", "Dependency list of the binary, as printed by go list -m all or go version -m")
+	binary := fs.String("binary", "", "Main package of the binary, matched against the binaries recorded with --binaries-from")
+	format := fs.String("format", formatNative, "Format to write the slice in, native, cyclonedx, spdx or spdx-tv")
+	out := fs.String("out", "", "File to write the slice to (defaults to stdout)")
+	compact := fs.Bool("compact", false, "Write minified JSON instead of indented JSON")
+	addSPDXIDFlags(fs)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || (*depsFile == "") == (*binary == "") {
+		return false, errors.New("usage: bom-merger slice --deps=FILE|--binary=PKG [--format=FORMAT] [--out=FILE] BOM")
+	}
+	if err := validateFormat(*format, ""); err != nil {
+		return false, err
+	}
+
+	projects, err := readBOMProjects(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	var deps []dependency
+	if *depsFile != "" {
+		if deps, err = readDependencies(*depsFile); err != nil {
+			return false, err
+		}
+	}
+	slice, missing := sliceProjects(projects, deps, *binary)
+	for _, d := range missing {
+		warnf("%s is not in %s", versionedPath(d.Path, d.Version), fs.Arg(0))
+	}
+	if len(slice) == 0 {
+		return false, fmt.Errorf("no project of %s is shipped by the binary", fs.Arg(0))
+	}
+
+	data, err := encodeBOM(slice, *format, *compact)
+	if err != nil {
+		return false, err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*out, data, 0644)
+	}
+	return len(missing) == 0, err
+}
+
+// dependency is a module in the dependency list of a binary.
+type dependency struct {
+	Path    string
+	Version string
+}
+
+// readDependencies reads a dependency list with one module path and
+// optional version per line, e.g. the output of go list -m all, or the
+// output of go version -m for a binary, whose dep lines it picks. Blank
+// lines and lines starting with # are ignored.
+func readDependencies(filename string) ([]dependency, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var deps []dependency
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "dep":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("%s:%d: invalid dep line %q", filename, i+1, line)
+			}
+			deps = append(deps, dependency{Path: fields[1], Version: fields[2]})
+			continue
+		case "path", "mod", "build", "=>":
+			// the binary itself, its build settings and replacements
+			continue
+		}
+		if strings.HasSuffix(fields[0], ":") {
+			// the "<binary>: <go version>" header of go version -m
+			continue
+		}
+		d := dependency{Path: fields[0]}
+		if len(fields) > 1 {
+			d.Version = fields[1]
+		}
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+// sliceProjects returns the projects listed in deps, or pulled in by the
+// main package binary, in the order of the BOM, and the versioned
+// dependencies that are not in the BOM. Projects whose version differs from
+// the listed one are kept with a warning, since the BOM only records one
+// version of each.
+func sliceProjects(projects []merge.Project, deps []dependency, binary string) ([]merge.Project, []dependency) {
+	byPath := map[string]merge.Project{}
+	for _, p := range projects {
+		byPath[p.Project] = p
+	}
+	shipped := map[string]bool{}
+	var missing []dependency
+	for _, d := range deps {
+		p, ok := byPath[d.Path]
+		if !ok {
+			// the main module and local replacements have no version
+			if d.Version != "" {
+				missing = append(missing, d)
+			}
+			continue
+		}
+		if d.Version != "" && p.Version != "" && d.Version != p.Version {
+			warnf("%s ships %s but the BOM lists %s", d.Path, d.Version, p.Version)
+		}
+		shipped[d.Path] = true
+	}
+
+	slice := []merge.Project{}
+	for _, p := range projects {
+		if shipped[p.Project] {
+			slice = append(slice, p)
+			continue
+		}
+		for _, b := range p.Binaries {
+			if b == binary {
+				slice = append(slice, p)
+				break
+			}
+		}
+	}
+	return slice, missing
+}
@@ -0,0 +1,402 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	formatSPDX   = "spdx"
+	formatSPDXTV = "spdx-tv"
+)
+
+// Ways to generate the document namespace and package SPDXIDs. Names and
+// hashes are stable, so regenerated documents can be diffed; UUIDs are
+// unique, for submission systems that reject a namespace seen before.
+const (
+	spdxIDName = "name"
+	spdxIDHash = "hash"
+	spdxIDUUID = "uuid"
+)
+
+var (
+	spdxNamespace  string
+	spdxPackageIDs string
+)
+
+// addSPDXIDFlags registers the flags that configure SPDX identifiers on fs.
+// They are shared by merge, convert and slice.
+func addSPDXIDFlags(fs *flag.FlagSet) {
+	fs.StringVar(&spdxNamespace, "spdx-namespace", spdxIDHash, "How the documentNamespace of SPDX documents is generated: hash of the packages, uuid, or a URL template using {{.Name}}, {{.Hash}} and {{.UUID}}")
+	fs.StringVar(&spdxPackageIDs, "spdx-package-ids", spdxIDName, "How the SPDXIDs of packages are generated: name, from module path and version, hash of the package URL, or uuid")
+}
+
+// spdxNamespaceData is the data of a --spdx-namespace template.
+type spdxNamespaceData struct {
+	Name string
+	Hash string
+	UUID string
+}
+
+// spdxDocumentNamespace returns the documentNamespace of a document named
+// name whose packages hash to hash.
+func spdxDocumentNamespace(name, hash string) (string, error) {
+	switch spdxNamespace {
+	case "", spdxIDHash:
+		return "https://spdx.org/spdxdocs/" + name + "-" + hash, nil
+	case spdxIDUUID:
+		return "https://spdx.org/spdxdocs/" + name + "-" + newUUID(), nil
+	}
+	tmpl, err := template.New("spdx-namespace").Option("missingkey=error").Parse(spdxNamespace)
+	if err != nil {
+		return "", fmt.Errorf("invalid --spdx-namespace: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, spdxNamespaceData{Name: name, Hash: hash, UUID: newUUID()}); err != nil {
+		return "", fmt.Errorf("invalid --spdx-namespace: %v", err)
+	}
+	// SPDX requires an absolute URI without a fragment
+	ns := buf.String()
+	if u, err := url.Parse(ns); err != nil || !u.IsAbs() || strings.Contains(ns, "#") {
+		return "", fmt.Errorf("invalid --spdx-namespace: %q is not an absolute URI without #", ns)
+	}
+	return ns, nil
+}
+
+// spdxPackageID returns the SPDXID of p, before it is made unique within
+// the document.
+func spdxPackageID(p merge.Project) (string, error) {
+	switch spdxPackageIDs {
+	case "", spdxIDName:
+		name := p.Project
+		if p.Version != "" {
+			name += "-" + p.Version
+		}
+		return "SPDXRef-Package-" + spdxIDInvalid.ReplaceAllString(name, "-"), nil
+	case spdxIDHash:
+		sum := sha256.Sum256([]byte(merge.PURL(p)))
+		return fmt.Sprintf("SPDXRef-Package-%x", sum[:8]), nil
+	case spdxIDUUID:
+		return "SPDXRef-Package-" + newUUID(), nil
+	}
+	return "", fmt.Errorf("invalid --spdx-package-ids %q, must be name, hash or uuid", spdxPackageIDs)
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// spdxDocument is an SPDX 2.3 document. Only the fields bom-merger can fill
+// are declared.
+type spdxDocument struct {
+	SPDXVersion       string                 `json:"spdxVersion"`
+	DataLicense       string                 `json:"dataLicense"`
+	SPDXID            string                 `json:"SPDXID"`
+	Name              string                 `json:"name"`
+	DocumentNamespace string                 `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo       `json:"creationInfo"`
+	Packages          []spdxPackage          `json:"packages"`
+	Relationships     []spdxRelationship     `json:"relationships"`
+	ExtractedLicenses []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+
+	// DocumentDescribes is only read from input documents; written
+	// documents use DESCRIBES relationships.
+	DocumentDescribes []string `json:"documentDescribes,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string            `json:"comment,omitempty"`
+	Annotations      []spdxAnnotation  `json:"annotations,omitempty"`
+}
+
+type spdxAnnotation struct {
+	AnnotationDate string `json:"annotationDate"`
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	Comment        string `json:"comment"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxExtractedLicense declares a LicenseRef- for a detected license type
+// that is not on the SPDX license list.
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxDoc converts projects to an SPDX document. By default the namespace
+// is derived from the packages, so it only changes with them.
+func spdxDoc(projects []merge.Project) (spdxDocument, error) {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        "bom-merger",
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: bom-merger"},
+		},
+		Packages:      make([]spdxPackage, 0, len(projects)),
+		Relationships: make([]spdxRelationship, 0, len(projects)),
+	}
+
+	ids := map[string]bool{}
+	refs := map[string]string{}
+	h := sha256.New()
+	for _, p := range projects {
+		name, err := spdxPackageID(p)
+		if err != nil {
+			return spdxDocument{}, err
+		}
+		id := name
+		for n := 2; ids[id]; n++ {
+			id = fmt.Sprintf("%s-%d", name, n)
+		}
+		ids[id] = true
+
+		pkg := spdxPackage{
+			Name:             p.Project,
+			SPDXID:           id,
+			VersionInfo:      p.Version,
+			DownloadLocation: merge.LicenseNoAssertion,
+			LicenseConcluded: spdxLicenseExpression(p, refs),
+			LicenseDeclared:  merge.LicenseNoAssertion,
+			CopyrightText:    merge.LicenseNoAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  merge.PURL(p),
+			}},
+		}
+		if p.VCS != "" {
+			pkg.DownloadLocation = "git+" + vcsURL(p.VCS)
+		}
+		if p.DeclaredLicense != "" {
+			pkg.LicenseDeclared = p.DeclaredLicense
+		}
+		pkg.Comment, pkg.Annotations = spdxAnnotations(p.Annotations, doc.CreationInfo.Created)
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+		fmt.Fprintf(h, "%s %s %s\n", id, pkg.LicenseConcluded, pkg.DownloadLocation)
+	}
+	ns, err := spdxDocumentNamespace(doc.Name, fmt.Sprintf("%x", h.Sum(nil)))
+	if err != nil {
+		return spdxDocument{}, err
+	}
+	doc.DocumentNamespace = ns
+
+	for ref, name := range refs {
+		doc.ExtractedLicenses = append(doc.ExtractedLicenses, spdxExtractedLicense{
+			LicenseID:     ref,
+			Name:          name,
+			ExtractedText: "License detected as " + name + ", which is not on the SPDX license list.",
+		})
+	}
+	sort.Slice(doc.ExtractedLicenses, func(i, j int) bool {
+		return doc.ExtractedLicenses[i].LicenseID < doc.ExtractedLicenses[j].LicenseID
+	})
+	return doc, nil
+}
+
+// spdxAnnotations converts annotations to SPDX package annotations.
+// Annotations without an annotator, which SPDX requires, become the package
+// comment instead. Annotations without a date get created.
+func spdxAnnotations(annotations []merge.Annotation, created string) (string, []spdxAnnotation) {
+	var comments []string
+	var out []spdxAnnotation
+	for _, a := range annotations {
+		if a.Annotator == "" {
+			comments = append(comments, a.Text)
+			continue
+		}
+		sa := spdxAnnotation{
+			AnnotationDate: a.Date,
+			AnnotationType: a.Type,
+			Annotator:      a.Annotator,
+			Comment:        a.Text,
+		}
+		if sa.AnnotationDate == "" {
+			sa.AnnotationDate = created
+		}
+		if sa.AnnotationType == "" {
+			sa.AnnotationType = "OTHER"
+		}
+		out = append(out, sa)
+	}
+	return strings.Join(comments, "\n\n"), out
+}
+
+// annotationsOfSPDX returns the comment and the annotations of pkg as
+// annotations.
+func annotationsOfSPDX(pkg spdxPackage) []merge.Annotation {
+	var out []merge.Annotation
+	if pkg.Comment != "" {
+		out = append(out, merge.Annotation{Text: pkg.Comment})
+	}
+	for _, a := range pkg.Annotations {
+		out = append(out, merge.Annotation{
+			Annotator: a.Annotator,
+			Date:      a.AnnotationDate,
+			Type:      a.AnnotationType,
+			Text:      a.Comment,
+		})
+	}
+	return out
+}
+
+// spdxLicenseExpression joins the detected licenses with AND, since a module
+// has to comply with all of them. Types not on the SPDX license list become
+// LicenseRef- identifiers, which are recorded in refs.
+func spdxLicenseExpression(p merge.Project, refs map[string]string) string {
+	if len(p.Licenses) == 0 {
+		if p.LicenseStatus == merge.LicenseNone {
+			return merge.LicenseNone
+		}
+		return merge.LicenseNoAssertion
+	}
+	parts := make([]string, 0, len(p.Licenses))
+	for _, lic := range p.Licenses {
+		id := lic.Type
+		if !merge.IsListed(id) {
+			ref := "LicenseRef-" + spdxIDInvalid.ReplaceAllString(id, "-")
+			refs[ref] = id
+			id = ref
+		}
+		if lic.Exception != "" {
+			id += " WITH " + lic.Exception
+		}
+		parts = append(parts, id)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	if p.LicenseExpression != "" {
+		// a choice between licenses of a dual-licensed project
+		return "(" + strings.Join(parts, " OR ") + ")"
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+// marshalSPDXTagValue encodes doc in the SPDX tag-value format.
+func marshalSPDXTagValue(doc spdxDocument) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "SPDXVersion: %s\n", doc.SPDXVersion)
+	fmt.Fprintf(&buf, "DataLicense: %s\n", doc.DataLicense)
+	fmt.Fprintf(&buf, "SPDXID: %s\n", doc.SPDXID)
+	fmt.Fprintf(&buf, "DocumentName: %s\n", doc.Name)
+	fmt.Fprintf(&buf, "DocumentNamespace: %s\n", doc.DocumentNamespace)
+	for _, c := range doc.CreationInfo.Creators {
+		fmt.Fprintf(&buf, "Creator: %s\n", c)
+	}
+	fmt.Fprintf(&buf, "Created: %s\n", doc.CreationInfo.Created)
+
+	for _, pkg := range doc.Packages {
+		buf.WriteString("\n##### Package\n\n")
+		fmt.Fprintf(&buf, "PackageName: %s\n", pkg.Name)
+		fmt.Fprintf(&buf, "SPDXID: %s\n", pkg.SPDXID)
+		if pkg.VersionInfo != "" {
+			fmt.Fprintf(&buf, "PackageVersion: %s\n", pkg.VersionInfo)
+		}
+		fmt.Fprintf(&buf, "PackageDownloadLocation: %s\n", pkg.DownloadLocation)
+		fmt.Fprintf(&buf, "FilesAnalyzed: %t\n", pkg.FilesAnalyzed)
+		fmt.Fprintf(&buf, "PackageLicenseConcluded: %s\n", pkg.LicenseConcluded)
+		fmt.Fprintf(&buf, "PackageLicenseDeclared: %s\n", pkg.LicenseDeclared)
+		fmt.Fprintf(&buf, "PackageCopyrightText: %s\n", pkg.CopyrightText)
+		for _, ref := range pkg.ExternalRefs {
+			fmt.Fprintf(&buf, "ExternalRef: %s %s %s\n", ref.ReferenceCategory, ref.ReferenceType, ref.ReferenceLocator)
+		}
+		if pkg.Comment != "" {
+			fmt.Fprintf(&buf, "PackageComment: <text>%s</text>\n", pkg.Comment)
+		}
+		for _, a := range pkg.Annotations {
+			buf.WriteString("\n")
+			fmt.Fprintf(&buf, "Annotator: %s\n", a.Annotator)
+			fmt.Fprintf(&buf, "AnnotationDate: %s\n", a.AnnotationDate)
+			fmt.Fprintf(&buf, "AnnotationType: %s\n", a.AnnotationType)
+			fmt.Fprintf(&buf, "SPDXREF: %s\n", pkg.SPDXID)
+			fmt.Fprintf(&buf, "AnnotationComment: <text>%s</text>\n", a.Comment)
+		}
+	}
+
+	if len(doc.Relationships) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, r := range doc.Relationships {
+		fmt.Fprintf(&buf, "Relationship: %s %s %s\n", r.SPDXElementID, r.RelationshipType, r.RelatedSPDXElement)
+	}
+
+	for _, lic := range doc.ExtractedLicenses {
+		buf.WriteString("\n")
+		fmt.Fprintf(&buf, "LicenseID: %s\n", lic.LicenseID)
+		fmt.Fprintf(&buf, "ExtractedText: <text>%s</text>\n", lic.ExtractedText)
+		fmt.Fprintf(&buf, "LicenseName: %s\n", lic.Name)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,94 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+func TestSPDXIDs(t *testing.T) {
+	defer func(ns, ids string) { spdxNamespace, spdxPackageIDs = ns, ids }(spdxNamespace, spdxPackageIDs)
+	projects := []merge.Project{
+		{Project: "example.com/x", Version: "v1.0.0", Licenses: []merge.License{{Type: "MIT"}}},
+		{Project: "example.com/y", Licenses: []merge.License{{Type: "Apache-2.0"}}},
+	}
+	uuid := regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	cases := []struct {
+		namespace, packageIDs string
+		stable                bool
+		checkNamespace        func(string) bool
+		checkID               func(string) bool
+	}{
+		{spdxIDHash, spdxIDName, true,
+			func(ns string) bool { return strings.HasPrefix(ns, "https://spdx.org/spdxdocs/bom-merger-") },
+			func(id string) bool { return id == "SPDXRef-Package-example.com-x-v1.0.0" }},
+		{spdxIDHash, spdxIDHash, true,
+			func(ns string) bool { return strings.HasPrefix(ns, "https://spdx.org/spdxdocs/bom-merger-") },
+			regexp.MustCompile(`^SPDXRef-Package-[0-9a-f]{16}$`).MatchString},
+		{spdxIDUUID, spdxIDUUID, false,
+			uuid.MatchString,
+			uuid.MatchString},
+		{"https://example.com/spdx/{{.Name}}/{{.Hash}}", spdxIDName, true,
+			regexp.MustCompile(`^https://example.com/spdx/bom-merger/[0-9a-f]{64}$`).MatchString,
+			func(id string) bool { return strings.HasPrefix(id, "SPDXRef-Package-") }},
+	}
+	for _, c := range cases {
+		spdxNamespace, spdxPackageIDs = c.namespace, c.packageIDs
+		first, err := spdxDoc(projects)
+		if err != nil {
+			t.Fatalf("%s/%s: %v", c.namespace, c.packageIDs, err)
+		}
+		second, err := spdxDoc(projects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.checkNamespace(first.DocumentNamespace) {
+			t.Errorf("%s/%s: unexpected namespace %s", c.namespace, c.packageIDs, first.DocumentNamespace)
+		}
+		if id := first.Packages[0].SPDXID; !c.checkID(id) {
+			t.Errorf("%s/%s: unexpected package ID %s", c.namespace, c.packageIDs, id)
+		}
+		if first.Packages[0].SPDXID == first.Packages[1].SPDXID {
+			t.Errorf("%s/%s: packages share the ID %s", c.namespace, c.packageIDs, first.Packages[0].SPDXID)
+		}
+		stable := first.DocumentNamespace == second.DocumentNamespace && first.Packages[0].SPDXID == second.Packages[0].SPDXID
+		if stable != c.stable {
+			t.Errorf("%s/%s: regenerated IDs stable = %v, want %v", c.namespace, c.packageIDs, stable, c.stable)
+		}
+	}
+}
+
+func TestSPDXIDsInvalid(t *testing.T) {
+	defer func(ns, ids string) { spdxNamespace, spdxPackageIDs = ns, ids }(spdxNamespace, spdxPackageIDs)
+	projects := []merge.Project{{Project: "example.com/x"}}
+	for _, c := range []struct{ namespace, packageIDs string }{
+		{"bom-{{.Hash}}", spdxIDName},
+		{"https://example.com/spdx#{{.Hash}}", spdxIDName},
+		{"https://example.com/{{.Missing}}", spdxIDName},
+		{spdxIDHash, "random"},
+	} {
+		spdxNamespace, spdxPackageIDs = c.namespace, c.packageIDs
+		if _, err := spdxDoc(projects); err == nil {
+			t.Errorf("%s/%s: no error", c.namespace, c.packageIDs)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// splitIndex describes the parts written instead of bom.json when the output
+// is split.
+type splitIndex struct {
+	SplitBy string      `json:"splitBy"`
+	Parts   []splitPart `json:"parts"`
+}
+
+type splitPart struct {
+	File     string                `json:"file"`
+	Category merge.LicenseCategory `json:"category,omitempty"`
+	Count    int                   `json:"count"`
+	Size     int                   `json:"size"`
+}
+
+// parseSplitBy validates the --split-by value and returns the size limit in
+// bytes for size based splitting.
+func parseSplitBy(splitBy string) (int64, error) {
+	switch {
+	case splitBy == "", splitBy == "license-category":
+		return 0, nil
+	case strings.HasPrefix(splitBy, "size="):
+		limit, err := parseSize(strings.TrimPrefix(splitBy, "size="))
+		if err != nil {
+			return 0, fmt.Errorf("invalid split size %q: %v", splitBy, err)
+		}
+		return limit, nil
+	default:
+		return 0, fmt.Errorf("invalid split %q, must be license-category or size=<limit>", splitBy)
+	}
+}
+
+// parseSize parses sizes like 10MB, 512KB or 1048576. Units are powers of 1024.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSuffix(s, u.suffix), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("size must be positive")
+	}
+	return n * mult, nil
+}
+
+// writeSplitBOM writes the projects as multiple bom.<part>.json files and an
+// index in bom.index.json.
+func writeSplitBOM(dir string, projects []merge.Project, splitBy string, compact bool) error {
+	limit, err := parseSplitBy(splitBy)
+	if err != nil {
+		return err
+	}
+
+	entries := make([][]byte, len(projects))
+	for i, p := range projects {
+		entries[i], err = marshalEntry(p, compact)
+		if err != nil {
+			return err
+		}
+	}
+
+	index := splitIndex{SplitBy: splitBy}
+	writePart := func(file string, category merge.LicenseCategory, part [][]byte) error {
+		data := joinEntries(part, compact)
+		index.Parts = append(index.Parts, splitPart{
+			File:     file,
+			Category: category,
+			Count:    len(part),
+			Size:     len(data),
+		})
+		return ioutil.WriteFile(filepath.Join(dir, file), data, 0644)
+	}
+
+	if splitBy == "license-category" {
+		byCategory := map[merge.LicenseCategory][][]byte{}
+		for i, p := range projects {
+			c := merge.Categorize(p)
+			byCategory[c] = append(byCategory[c], entries[i])
+		}
+		for _, c := range []merge.LicenseCategory{merge.CategoryPermissive, merge.CategoryWeakCopyleft, merge.CategoryCopyleft, merge.CategoryUnknown} {
+			if part, ok := byCategory[c]; ok {
+				if err := writePart("bom."+string(c)+".json", c, part); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		var part [][]byte
+		size := int64(len(joinEntries(nil, compact)))
+		for _, e := range entries {
+			// each entry adds its encoding plus separator
+			n := int64(len(e)) + int64(len(entrySeparator(compact)))
+			if len(part) > 0 && size+n > limit {
+				if err := writePart(fmt.Sprintf("bom.%03d.json", len(index.Parts)+1), "", part); err != nil {
+					return err
+				}
+				part, size = nil, int64(len(joinEntries(nil, compact)))
+			}
+			part = append(part, e)
+			size += n
+		}
+		if len(part) > 0 || len(index.Parts) == 0 {
+			if err := writePart(fmt.Sprintf("bom.%03d.json", len(index.Parts)+1), "", part); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := marshalOutput(index, compact)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "bom.index.json"), data, 0644)
+}
+
+// marshalEntry encodes p the way marshalOutput encodes an element of a list.
+func marshalEntry(p merge.Project, compact bool) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if !compact {
+		encoder.SetIndent("  ", "  ")
+	}
+	if err := encoder.Encode(p); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func entrySeparator(compact bool) string {
+	if compact {
+		return ","
+	}
+	return ",\n  "
+}
+
+func joinEntries(entries [][]byte, compact bool) []byte {
+	if len(entries) == 0 {
+		return []byte("[]\n")
+	}
+	var buf bytes.Buffer
+	if compact {
+		buf.WriteString("[")
+	} else {
+		buf.WriteString("[\n  ")
+	}
+	buf.Write(bytes.Join(entries, []byte(entrySeparator(compact))))
+	if compact {
+		buf.WriteString("]\n")
+	} else {
+		buf.WriteString("\n]\n")
+	}
+	return buf.Bytes()
+}
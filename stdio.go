@@ -0,0 +1,203 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// stdio as --in reads the fragments from stdin and as --out writes the BOM
+// to stdout, so bom-merger can be used as a filter in a pipeline.
+const stdio = "-"
+
+// stdin is read once, since several stages may read the same input, e.g.
+// the override file, whose content is also hashed for the audit log.
+var stdin struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// readFileOrStdin reads filename, or stdin if it is -.
+func readFileOrStdin(filename string) ([]byte, error) {
+	if filename != stdio {
+		return ioutil.ReadFile(filename)
+	}
+	stdin.once.Do(func() {
+		stdin.data, stdin.err = ioutil.ReadAll(os.Stdin)
+		if stdin.err != nil {
+			stdin.err = fmt.Errorf("failed to read stdin: %v", stdin.err)
+		}
+	})
+	return stdin.data, stdin.err
+}
+
+// validateStdio rejects the options that need an input or an output
+// directory when reading from stdin or writing to stdout.
+func validateStdio(opts options) error {
+	stdinFiles := 0
+	for _, in := range opts.In {
+		if in == stdio {
+			stdinFiles++
+		}
+	}
+	if stdinFiles > 0 && len(opts.In) > 1 {
+		return errors.New("--in=- can not be combined with other inputs")
+	}
+	if stdinFiles > 0 && (len(opts.Include) > 0 || len(opts.Exclude) > 0 || opts.Recursive) {
+		return errors.New("--include, --exclude and --recursive can not be used with --in=-")
+	}
+	for _, f := range opts.OverrideFiles {
+		if f == stdio {
+			stdinFiles++
+		}
+	}
+	if stdinFiles > 1 {
+		return errors.New("only one of --in and --override-file can be read from stdin")
+	}
+	if opts.Out != stdio {
+		return nil
+	}
+	switch {
+	case opts.SplitBy != "":
+		return errors.New("--split-by can not be used with --out=-")
+	case opts.Template != "":
+		return errors.New("--template can not be used with --out=-")
+	case opts.Notice != "":
+		return errors.New("--notice can not be used with --out=-")
+	case opts.LicenseTexts == licenseTextsDir:
+		return fmt.Errorf("--license-texts=%s can not be used with --out=-", licenseTextsDir)
+	case opts.WriteReport, opts.HTMLReport, opts.WriteFiltered, opts.RequireConfidence:
+		return errors.New("--write-report, --html-report, --write-filtered and --require-confidence write files beside bom.json and can not be used with --out=-")
+	case opts.MinConfidence > 0:
+		return errors.New("--min-confidence writes bom_review.json beside bom.json and can not be used with --out=-")
+	case (opts.Locked || opts.writeLock) && opts.LockFile == "":
+		return errors.New("--out=- requires --lock-file, there is no output directory to keep bom.lock.json in")
+	}
+	return nil
+}
+
+// readStdinFragments reads the fragments piped to stdin. Several fragments
+// may be concatenated, like `cat a.json b.json`, and are named stdin#1,
+// stdin#2 and so on in traces; a single one is named stdin. YAML fragments
+// are separated by --- and converted to JSON first.
+func readStdinFragments(yaml bool) ([]sourceFragment, error) {
+	data, err := readFileOrStdin(stdio)
+	if err != nil {
+		return nil, err
+	}
+	if yaml {
+		if data, err = yamlToJSON("stdin", data); err != nil {
+			return nil, err
+		}
+	}
+	parts := splitFragments(data)
+	out := make([]sourceFragment, len(parts))
+	for i, part := range parts {
+		out[i] = sourceFragment{Source: "stdin", Data: part}
+		if len(parts) > 1 {
+			out[i].Source = fmt.Sprintf("stdin#%d", i+1)
+		}
+	}
+	return out, nil
+}
+
+// splitFragments splits a stream of concatenated JSON documents into
+// fragments. Every object is a fragment of its own, while consecutive
+// arrays form a single fragment in the legacy two-array format. SPDX
+// tag-value documents and data that is not valid JSON are returned as a
+// single fragment, so parsing it reports the error.
+func splitFragments(data []byte) [][]byte {
+	if isSPDXTagValue(data) {
+		return [][]byte{data}
+	}
+	var out [][]byte
+	legacy := false
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		start := decoder.InputOffset()
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			return append(out, data[start:])
+		}
+		isArray := bytes.HasPrefix(bytes.TrimSpace(raw), []byte("["))
+		if isArray && legacy {
+			last := len(out) - 1
+			out[last] = append(append(out[last], '\n'), raw...)
+			continue
+		}
+		legacy = isArray
+		out = append(out, append([]byte(nil), raw...))
+	}
+}
+
+// writeStdout writes the BOM in --format to stdout instead of the output
+// directory. Error entries have no place in the stream and are only
+// counted in a warning.
+func (m *merger) writeStdout() error {
+	reg, err := m.outputRegistry(m.bom, merge.HighestConfidence)
+	if err != nil {
+		return err
+	}
+	projects := sortedProjects(reg, m.opts.SortBy)
+	if m.opts.ValidateOutput && (m.opts.Format == formatNative || m.opts.Format == formatYAML) {
+		if err := validateOutput("bom.json", projects); err != nil {
+			return err
+		}
+	}
+	data, err := encodeBOM(projects, m.opts.Format, m.opts.Compact)
+	if err != nil {
+		return err
+	}
+	if m.errors.Len() > 0 {
+		warnf("%d entries with errors are not written with --out=-", m.errors.Len())
+	}
+	consoleMu.Lock()
+	_, err = stdout.Write(data)
+	consoleMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if m.opts.PerSourceOut != "" {
+		if err := m.writePerSource(m.opts.PerSourceOut); err != nil {
+			return err
+		}
+	}
+	if m.opts.writeLock {
+		if err := writeLockFile(m.opts.LockFile, m.bom, m.evidence); err != nil {
+			return err
+		}
+	}
+	if m.opts.HistoryDir != "" {
+		return m.recordHistory(m.bom)
+	}
+	return nil
+}
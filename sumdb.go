@@ -0,0 +1,235 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	integrityVerified = "verified"
+	integrityMismatch = "mismatch"
+	integrityUnknown  = "unknown"
+)
+
+// checksumVerifier downloads modules from the module proxy and compares
+// their hash with the one recorded in the checksum database. Results are
+// cached per module version for the lifetime of the process.
+type checksumVerifier struct {
+	proxy  string
+	sumdb  string
+	client *http.Client
+
+	mu      sync.Mutex
+	lookups map[string]*checksumLookup
+}
+
+type checksumLookup struct {
+	done   chan struct{}
+	sum    string
+	status string
+	err    error
+}
+
+func newChecksumVerifier() *checksumVerifier {
+	return &checksumVerifier{
+		proxy:   goproxyURL(),
+		sumdb:   sumdbURL(os.Getenv("GOSUMDB")),
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		lookups: map[string]*checksumLookup{},
+	}
+}
+
+// goproxyURL returns the first HTTP module proxy of GOPROXY.
+func goproxyURL() string {
+	for _, entry := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://") {
+			return strings.TrimSuffix(entry, "/")
+		}
+	}
+	return "https://proxy.golang.org"
+}
+
+// sumdbURL returns the base URL of the checksum database named by GOSUMDB,
+// which is either "name[+key]" or "name[+key] url".
+func sumdbURL(gosumdb string) string {
+	fields := strings.Fields(gosumdb)
+	switch len(fields) {
+	case 0:
+		return "https://sum.golang.org"
+	case 1:
+		return "https://" + strings.SplitN(fields[0], "+", 2)[0]
+	default:
+		return strings.TrimSuffix(fields[1], "/")
+	}
+}
+
+// Verify returns the checksum database hash of module@version and whether
+// the module served by the proxy matches it.
+func (v *checksumVerifier) Verify(module, version string) (string, string, error) {
+	id := module + "@" + version
+	v.mu.Lock()
+	l, ok := v.lookups[id]
+	if !ok {
+		l = &checksumLookup{done: make(chan struct{})}
+		v.lookups[id] = l
+		v.mu.Unlock()
+
+		l.sum, l.status, l.err = v.verify(module, version)
+		close(l.done)
+	} else {
+		v.mu.Unlock()
+		<-l.done
+	}
+	return l.sum, l.status, l.err
+}
+
+func (v *checksumVerifier) verify(module, version string) (string, string, error) {
+	escMod, err := escapeModulePath(module)
+	if err != nil {
+		return "", "", err
+	}
+	escVer, err := escapeModulePath(version)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, found, err := v.get(v.sumdb + "/lookup/" + escMod + "@" + escVer)
+	if err != nil {
+		return "", "", err
+	}
+	if !found {
+		return "", integrityUnknown, nil
+	}
+	sum := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == module && fields[1] == version {
+			sum = fields[2]
+			break
+		}
+	}
+	if sum == "" {
+		return "", integrityUnknown, nil
+	}
+
+	data, found, err = v.get(v.proxy + "/" + escMod + "/@v/" + escVer + ".zip")
+	if err != nil {
+		return "", "", err
+	}
+	if !found {
+		return sum, integrityUnknown, nil
+	}
+	actual, err := hashModuleZip(data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash %s: %v", module+"@"+version, err)
+	}
+	if actual != sum {
+		return sum, integrityMismatch, nil
+	}
+	return sum, integrityVerified, nil
+}
+
+func (v *checksumVerifier) get(url string) ([]byte, bool, error) {
+	return proxyGet(v.client, url)
+}
+
+// proxyGet fetches url from a module proxy or checksum database and reports
+// whether it exists.
+func proxyGet(client *http.Client, url string) ([]byte, bool, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// hashModuleZip computes the h1: hash go.sum records for a module zip file.
+func hashModuleZip(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	files := make([]*zip.File, len(zr.File))
+	copy(files, zr.File)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	summary := sha256.New()
+	for _, f := range files {
+		if strings.Contains(f.Name, "\n") {
+			return "", fmt.Errorf("file name %q contains a newline", f.Name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), f.Name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// escapeModulePath applies the case encoding of the module proxy protocol,
+// which replaces every upper case letter with an exclamation mark followed
+// by the lower case letter.
+func escapeModulePath(path string) (string, error) {
+	var buf strings.Builder
+	for _, r := range path {
+		switch {
+		case r == '!' || r >= utf8.RuneSelf:
+			return "", fmt.Errorf("invalid module path or version %q", path)
+		case 'A' <= r && r <= 'Z':
+			buf.WriteByte('!')
+			buf.WriteRune(r + 'a' - 'A')
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+const (
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+)
+
+var tableHeader = []string{"Project", "Version", "License", "Confidence", "VCS"}
+
+// tableRow returns the columns of p in the CSV and Markdown tables. The
+// license is the SPDX expression of the entry, or its licenses joined with
+// AND, and the confidence the highest of its licenses.
+func tableRow(p merge.Project) []string {
+	license := p.LicenseExpression
+	var types []string
+	var confidence float64
+	for _, lic := range p.Licenses {
+		t := lic.Type
+		if lic.Exception != "" {
+			t += " WITH " + lic.Exception
+		}
+		types = append(types, t)
+		if lic.Confidence > confidence {
+			confidence = lic.Confidence
+		}
+	}
+	if license == "" {
+		license = strings.Join(types, " AND ")
+	}
+	conf := ""
+	if confidence > 0 {
+		conf = strconv.FormatFloat(confidence, 'f', -1, 64)
+	}
+	return []string{p.Project, p.Version, license, conf, p.VCS}
+}
+
+// marshalCSV writes projects as CSV with a header row, for spreadsheets.
+func marshalCSV(projects []merge.Project) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(tableHeader); err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if err := w.Write(tableRow(p)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// marshalMarkdown writes projects as a Markdown table, e.g. for release
+// notes.
+func marshalMarkdown(projects []merge.Project) []byte {
+	var buf bytes.Buffer
+	writeRow := func(cols []string) {
+		for i, c := range cols {
+			cols[i] = markdownCell(c)
+		}
+		buf.WriteString("| " + strings.Join(cols, " | ") + " |\n")
+	}
+	writeRow(append([]string(nil), tableHeader...))
+	buf.WriteString("|" + strings.Repeat(" --- |", len(tableHeader)) + "\n")
+	for _, p := range projects {
+		writeRow(tableRow(p))
+	}
+	return buf.Bytes()
+}
+
+// markdownCell escapes the characters that would break a table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(s)
+}
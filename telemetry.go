@@ -0,0 +1,331 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vcsSpanBatch is the number of VCS lookups recorded in one span, so large
+// BOMs show how the lookup latency develops without a span per project.
+const vcsSpanBatch = 100
+
+// tracer records the spans of the merges of a process and sends them to an
+// OpenTelemetry collector with OTLP over HTTP, in its JSON encoding, once
+// the process is done. A nil tracer records nothing.
+type tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+	traceID  string
+
+	mu    sync.Mutex
+	spans []*span
+}
+
+// span is a timed operation of the pipeline, e.g. a stage of a merge.
+type span struct {
+	t      *tracer
+	name   string
+	id     string
+	parent string
+	start  time.Time
+	end    time.Time
+	attrs  []spanAttr
+	err    error
+}
+
+type spanAttr struct {
+	key   string
+	value interface{}
+}
+
+func attr(key string, value interface{}) spanAttr {
+	return spanAttr{key, value}
+}
+
+// otlpEndpoint returns the URL spans are sent to, the --otlp-endpoint
+// flag or the standard OpenTelemetry environment variables.
+func otlpEndpoint(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		return v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		return strings.TrimSuffix(v, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// newTracer returns a tracer sending spans to endpoint, or nil if it is
+// empty. Headers, e.g. for authentication, are taken from
+// OTEL_EXPORTER_OTLP_HEADERS as comma separated key=value pairs.
+func newTracer(endpoint string) *tracer {
+	if endpoint == "" {
+		return nil
+	}
+	headers := map[string]string{}
+	for _, pair := range strings.Split(firstEnv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) != "" {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "bom-merger"
+	}
+	return &tracer{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  service,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		traceID:  randomID(16),
+	}
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = io.ReadFull(rand.Reader, b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts a span, a child of parent unless it is nil.
+func (t *tracer) Start(name string, parent *span, attrs ...spanAttr) *span {
+	return t.Record(name, parent, time.Now(), time.Time{}, attrs...)
+}
+
+// Record adds a span that started at start. It ends at end unless that is
+// zero, in which case End must be called.
+func (t *tracer) Record(name string, parent *span, start, end time.Time, attrs ...spanAttr) *span {
+	if t == nil {
+		return nil
+	}
+	s := &span{t: t, name: name, id: randomID(8), start: start, end: end, attrs: attrs}
+	if parent != nil {
+		s.parent = parent.id
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return s
+}
+
+// End ends s, marking it failed if err is not nil.
+func (s *span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.end.IsZero() {
+		s.end, s.err = time.Now(), err
+	}
+}
+
+// Flush sends the recorded spans to the collector. Spans that were not
+// ended are sent as ending now.
+func (t *tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(t.otlpRequest(spans, time.Now()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", t.endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// otlpRequest builds the ExportTraceServiceRequest of spans, as defined by
+// the OTLP JSON encoding: IDs are hex encoded and 64 bit integers strings.
+func (t *tracer) otlpRequest(spans []*span, now time.Time) interface{} {
+	type object = map[string]interface{}
+	out := make([]object, 0, len(spans))
+	for _, s := range spans {
+		end := s.end
+		if end.IsZero() {
+			end = now
+		}
+		o := object{
+			"traceId":           t.traceID,
+			"spanId":            s.id,
+			"name":              s.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != "" {
+			o["parentSpanId"] = s.parent
+		}
+		if s.err != nil {
+			o["status"] = object{"code": 2, "message": s.err.Error()} // STATUS_CODE_ERROR
+		}
+		out = append(out, o)
+	}
+	return object{
+		"resourceSpans": []object{{
+			"resource": object{
+				"attributes": otlpAttributes([]spanAttr{attr("service.name", t.service)}),
+			},
+			"scopeSpans": []object{{
+				"scope": object{"name": "bom-merger"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs []spanAttr) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]interface{}
+		switch x := a.value.(type) {
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(x)}
+		case bool:
+			v = map[string]interface{}{"boolValue": x}
+		case float64:
+			v = map[string]interface{}{"doubleValue": x}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]interface{}{"key": a.key, "value": v})
+	}
+	return out
+}
+
+// tracer returns the tracer of the merge, nil if the merge is not traced.
+func (m *merger) tracer() *tracer {
+	if m.res == nil {
+		return nil
+	}
+	return m.res.tracer
+}
+
+// setStage moves the merge on to the next stage of the pipeline, ending
+// the span and adding up the duration of the previous one.
+func (m *merger) setStage(stage string) {
+	if stage == m.stage {
+		return
+	}
+	m.endStage(nil)
+	m.stage = stage
+	m.stageStarted = time.Now()
+	m.stageSpan = m.tracer().Start(stage, m.span)
+}
+
+// endStage ends the current stage, marking its span failed if err is not
+// nil.
+func (m *merger) endStage(err error) {
+	if m.stage == "" || m.stageStarted.IsZero() {
+		return
+	}
+	if m.stages == nil {
+		m.stages = map[string]time.Duration{}
+	}
+	m.stages[m.stage] += time.Since(m.stageStarted)
+	m.stageStarted = time.Time{}
+	m.stageSpan.End(err)
+	m.stageSpan = nil
+}
+
+// stageDurations returns the time spent in every stage so far, including
+// the running one, for the report.
+func (m *merger) stageDurations() map[string]string {
+	out := map[string]string{}
+	for stage, d := range m.stages {
+		out[stage] = d.Round(time.Millisecond).String()
+	}
+	if !m.stageStarted.IsZero() {
+		out[m.stage] = (m.stages[m.stage] + time.Since(m.stageStarted)).Round(time.Millisecond).String()
+	}
+	return out
+}
+
+// traceVCSLookups records the lookups of projects, which started and ended
+// at the given times, in spans of vcsSpanBatch lookups each.
+func (m *merger) traceVCSLookups(projects []string, started, ended []time.Time) {
+	t := m.tracer()
+	if t == nil {
+		return
+	}
+	for first := 0; first < len(projects); first += vcsSpanBatch {
+		last := first + vcsSpanBatch
+		if last > len(projects) {
+			last = len(projects)
+		}
+		start, end := started[first], ended[first]
+		var failed int
+		for i := first; i < last; i++ {
+			if started[i].Before(start) {
+				start = started[i]
+			}
+			if ended[i].After(end) {
+				end = ended[i]
+			}
+			if _, _, err := m.res.vcs.Resolve(projects[i]); err != nil {
+				failed++
+			}
+		}
+		t.Record("vcs lookups", m.stageSpan, start, end,
+			attr("lookups", last-first),
+			attr("failed", failed),
+			attr("first", projects[first]),
+			attr("last", projects[last-1]))
+	}
+}
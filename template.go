@@ -0,0 +1,143 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// templateData is the data custom documents are rendered from.
+type templateData struct {
+	Projects []merge.Project
+	Errors   []merge.Project
+	Review   []merge.Project
+}
+
+// licenseGroup is an element of the result of groupByLicense.
+type licenseGroup struct {
+	License  string
+	Projects []merge.Project
+}
+
+var templateFuncs = template.FuncMap{
+	"groupByLicense": groupByLicense,
+	"sortBy":         sortProjectsBy,
+	"matchGlob":      matchGlob,
+	"spdxURL":        spdxURL,
+	"join":           strings.Join,
+	"lower":          strings.ToLower,
+	"upper":          strings.ToUpper,
+}
+
+// groupByLicense groups projects by the type of their first license, in
+// order of license type. Projects without a license are grouped under
+// "unknown".
+func groupByLicense(projects []merge.Project) []licenseGroup {
+	groups := map[string][]merge.Project{}
+	for _, p := range projects {
+		license := "unknown"
+		if len(p.Licenses) > 0 && p.Licenses[0].Type != "" {
+			license = p.Licenses[0].Type
+		}
+		groups[license] = append(groups[license], p)
+	}
+	out := make([]licenseGroup, 0, len(groups))
+	for license, ps := range groups {
+		out = append(out, licenseGroup{License: license, Projects: ps})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].License < out[j].License })
+	return out
+}
+
+// sortProjectsBy returns projects sorted by a field of merge.Project, e.g.
+// Risk or VCS, keeping the order of equal entries.
+func sortProjectsBy(field string, projects []merge.Project) ([]merge.Project, error) {
+	f, ok := reflect.TypeOf(merge.Project{}).FieldByName(field)
+	if !ok {
+		return nil, fmt.Errorf("unknown project field %q", field)
+	}
+	out := make([]merge.Project, len(projects))
+	copy(out, projects)
+	var err error
+	sort.SliceStable(out, func(i, j int) bool {
+		a := reflect.ValueOf(out[i]).FieldByIndex(f.Index)
+		b := reflect.ValueOf(out[j]).FieldByIndex(f.Index)
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Int:
+			return a.Int() < b.Int()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		default:
+			err = fmt.Errorf("cannot sort by project field %q of type %s", field, a.Type())
+			return false
+		}
+	})
+	return out, err
+}
+
+// matchGlob reports whether s matches a path pattern such as k8s.io/*.
+func matchGlob(pattern, s string) (bool, error) {
+	return path.Match(pattern, s)
+}
+
+// spdxURL returns the page of a license or exception in the SPDX license
+// list.
+func spdxURL(id string) string {
+	return "https://spdx.org/licenses/" + merge.CanonicalID(id) + ".html"
+}
+
+// writeTemplate renders the template file to dir, under its name without
+// the .tmpl extension.
+func (m *merger) writeTemplate(filename, dir string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(filepath.Base(filename)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s: %v", filename, err)
+	}
+	var filter *exportFilter
+	if m.opts.ExportFilter != "" {
+		if filter, err = parseExportFilter(m.opts.ExportFilter); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, templateData{
+		Projects: filter.Apply(sortedProjects(m.bom, reportOrder(m.opts.SortBy))),
+		Errors:   filter.Apply(m.errors.Projects()),
+		Review:   filter.Apply(m.review.Projects()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render template %s: %v", filename, err)
+	}
+	out := strings.TrimSuffix(filepath.Base(filename), ".tmpl")
+	return ioutil.WriteFile(filepath.Join(dir, out), buf.Bytes(), 0644)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runValidate parses BOM fragments and documents without merging them and
+// reports every invalid file. It reports false if any file is invalid.
+func runValidate(args []string) (bool, error) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		return false, errors.New("usage: bom-merger validate FILE|DIR...")
+	}
+	files, err := listFiles(fs.Args())
+	if err != nil {
+		return false, err
+	}
+
+	valid := true
+	for _, filename := range files {
+		if err := validateFile(filename); err != nil {
+			fmt.Printf("invalid %s: %v\n", filename, err)
+			valid = false
+			continue
+		}
+		fmt.Printf("ok %s\n", filename)
+	}
+	if !valid {
+		fmt.Fprintln(os.Stderr, "some files are invalid")
+	}
+	return valid, nil
+}
+
+func validateFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	doc, err := parseBOM(filename, data)
+	if err != nil {
+		return err
+	}
+	for i, p := range doc.Projects {
+		if p.Project == "" {
+			return fmt.Errorf("project %d has no project path", i)
+		}
+	}
+	for i, p := range doc.Errors {
+		if p.Project == "" {
+			return fmt.Errorf("error %d has no project path", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// vcsResolver detects VCS roots and caches the results, so merges running
+// concurrently in one process resolve every project only once. If cache is
+// set, results are also shared with other runs through it.
+type vcsResolver struct {
+	cache   vcsCache
+	refresh bool
+	workers int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	lookups map[string]*vcsLookup
+}
+
+type vcsLookup struct {
+	done   chan struct{}
+	root   string
+	source string
+	err    error
+}
+
+func newVCSResolver(cache vcsCache, refresh bool, workers int, timeout time.Duration) *vcsResolver {
+	return &vcsResolver{
+		cache:   cache,
+		refresh: refresh,
+		workers: workers,
+		timeout: timeout,
+		lookups: map[string]*vcsLookup{},
+	}
+}
+
+// Prefetch resolves the projects with a pool of workers, tuned if workers
+// is 0, so the following calls to Resolve return without waiting on the
+// network. Errors are kept for Resolve to report. It returns the number of
+// workers used. If started and ended are not nil, the times the lookup of
+// every project started and ended are stored at its index.
+func (r *vcsResolver) Prefetch(projects []string, started, ended []time.Time) int {
+	workers := runPool(len(projects), r.workers, func(i int) {
+		if started != nil {
+			started[i] = time.Now()
+			defer func() { ended[i] = time.Now() }()
+		}
+		_, _, _ = r.Resolve(projects[i])
+	})
+	r.Flush()
+	return workers
+}
+
+// Flush saves the lookups a cache keeps in memory, such as the file cache,
+// at once. Like other cache failures, a failed save is only reported.
+func (r *vcsResolver) Flush() {
+	f, ok := r.cache.(interface{ Flush() error })
+	if !ok {
+		return
+	}
+	if err := f.Flush(); err != nil {
+		warnf("VCS cache update failed: %v", err)
+	}
+}
+
+// Resolve returns the VCS root of project and how it was found. Concurrent
+// calls for the same project wait for a single lookup.
+func (r *vcsResolver) Resolve(project string) (string, string, error) {
+	r.mu.Lock()
+	l, ok := r.lookups[project]
+	if !ok {
+		l = &vcsLookup{done: make(chan struct{})}
+		r.lookups[project] = l
+		r.mu.Unlock()
+
+		l.root, l.source, l.err = r.lookup(project)
+		close(l.done)
+	} else {
+		r.mu.Unlock()
+		<-l.done
+	}
+	return l.root, l.source, l.err
+}
+
+// lookup consults the cache before detecting the VCS root, unless refresh is
+// set. Cache failures are reported but never fail the merge.
+func (r *vcsResolver) lookup(project string) (string, string, error) {
+	if r.cache != nil && !r.refresh {
+		root, found, err := r.cache.Get(project)
+		if err != nil {
+			warnf("VCS cache lookup for %s failed: %v", project, err)
+		} else if found {
+			return root, "VCS cache", nil
+		}
+	}
+
+	root, source, err := r.detect(project)
+	if err != nil {
+		return "", "", err
+	}
+	if r.cache != nil {
+		if err := r.cache.Set(project, root); err != nil {
+			warnf("VCS cache update for %s failed: %v", project, err)
+		}
+	}
+	return root, source, nil
+}
+
+// detect detects the VCS root of project, giving up after the timeout. The
+// request itself cannot be canceled and is left to finish in the background.
+func (r *vcsResolver) detect(project string) (string, string, error) {
+	if r.timeout <= 0 {
+		return merge.DetectVCSRoot(project)
+	}
+	type result struct {
+		root, source string
+		err          error
+	}
+	done := make(chan result, 1)
+	go func() {
+		root, source, err := merge.DetectVCSRoot(project)
+		done <- result{root, source, err}
+	}()
+	select {
+	case res := <-done:
+		return res.root, res.source, res.err
+	case <-time.After(r.timeout):
+		return "", "", fmt.Errorf("VCS root detection for %s timed out after %v", project, r.timeout)
+	}
+}
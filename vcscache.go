@@ -0,0 +1,399 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vcsCache persists VCS root lookups across runs. An empty root is a valid
+// cached value for projects without a go-import meta tag. Enrichment
+// results are not stored here: GitHub metadata and deps.dev insights go
+// stale within days, and license texts have their own --license-text-cache.
+type vcsCache interface {
+	Get(project string) (root string, found bool, err error)
+	Set(project, root string) error
+}
+
+// newVCSCache returns the cache backend for location, which is a
+// redis:// or http(s):// URL or the path of a JSON file.
+func newVCSCache(location string) (vcsCache, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VCS cache %q: %v", location, err)
+	}
+	switch u.Scheme {
+	case "redis":
+		return newRedisCache(u)
+	case "http", "https":
+		return &httpCache{base: strings.TrimSuffix(location, "/"), client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case "file":
+		return newFileCache(u.Path)
+	case "":
+		return newFileCache(location)
+	default:
+		return nil, fmt.Errorf("unsupported VCS cache %q, must be a redis:// or http(s):// URL or a file", location)
+	}
+}
+
+// fileCache keeps all entries in a JSON object in a local file. Updates are
+// kept in memory until Flush rewrites the file.
+type fileCache struct {
+	filename string
+
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry
+	dirty   bool
+}
+
+// fileCacheEntry records when the root was resolved, so stale entries can
+// be removed by cache gc.
+type fileCacheEntry struct {
+	Root     string    `json:"root"`
+	Resolved time.Time `json:"resolved"`
+}
+
+func newFileCache(filename string) (*fileCache, error) {
+	if filename == "~" || strings.HasPrefix(filename, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		filename = filepath.Join(home, filename[1:])
+	}
+	return &fileCache{filename: filename}, nil
+}
+
+// load reads the file on first use. A missing file is an empty cache.
+func (c *fileCache) load() error {
+	if c.entries != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.filename)
+	if os.IsNotExist(err) {
+		c.entries = map[string]fileCacheEntry{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries := map[string]fileCacheEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse VCS cache %s: %v", c.filename, err)
+	}
+	c.entries = entries
+	return nil
+}
+
+func (c *fileCache) Get(project string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return "", false, err
+	}
+	e, found := c.entries[project]
+	return e.Root, found, nil
+}
+
+func (c *fileCache) Set(project, root string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.entries[project] = fileCacheEntry{Root: root, Resolved: time.Now().UTC()}
+	c.dirty = true
+	return nil
+}
+
+// Flush writes the file if entries were set since it was last written.
+func (c *fileCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// gc removes the entries resolved before cutoff, unless dryRun is set, and
+// returns how many were removed and kept and the size of the file without
+// them.
+func (c *fileCache) gc(cutoff time.Time, dryRun bool) (int, int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return 0, 0, 0, err
+	}
+	kept := map[string]fileCacheEntry{}
+	for project, e := range c.entries {
+		if !e.Resolved.Before(cutoff) {
+			kept[project] = e
+		}
+	}
+	removed := len(c.entries) - len(kept)
+	data, err := MarshalJson(kept)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if removed > 0 && !dryRun {
+		c.entries = kept
+		if err := c.save(); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return removed, len(kept), int64(len(data)), nil
+}
+
+func (c *fileCache) save() error {
+	data, err := MarshalJson(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0755); err != nil {
+		return err
+	}
+	// replace the file in one step, so concurrent runs never read a
+	// truncated cache
+	tmp, err := ioutil.TempFile(filepath.Dir(c.filename), filepath.Base(c.filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// httpCache stores entries as plain text documents below a base URL using
+// GET and PUT, which works with most generic HTTP cache services.
+type httpCache struct {
+	base   string
+	client *http.Client
+}
+
+func (c *httpCache) url(project string) string {
+	return c.base + "/" + url.PathEscape(project)
+}
+
+func (c *httpCache) Get(project string) (string, bool, error) {
+	resp, err := c.client.Get(c.url(project))
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("GET %s: %s", c.url(project), resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+func (c *httpCache) Set(project, root string) error {
+	req, err := http.NewRequest(http.MethodPut, c.url(project), strings.NewReader(root))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", c.url(project), resp.Status)
+	}
+	return nil
+}
+
+const redisKeyPrefix = "bom-merger:vcs:"
+
+// redisCache is a minimal RESP client supporting the few commands needed
+// to share the cache through Redis.
+type redisCache struct {
+	mu   sync.Mutex
+	addr string
+	user string
+	auth string
+	db   int
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newRedisCache(u *url.URL) (*redisCache, error) {
+	c := &redisCache{addr: u.Host}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.user = u.User.Username()
+		c.auth, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+		c.db = n
+	}
+	return c, nil
+}
+
+func (c *redisCache) Get(project string) (string, bool, error) {
+	reply, err := c.do("GET", redisKeyPrefix+project)
+	if err != nil {
+		return "", false, err
+	}
+	if reply == nil {
+		return "", false, nil
+	}
+	return *reply, true, nil
+}
+
+func (c *redisCache) Set(project, root string) error {
+	_, err := c.do("SET", redisKeyPrefix+project, root)
+	return err
+}
+
+func (c *redisCache) do(args ...string) (*string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.command(args...)
+	if err != nil {
+		// drop the connection, the next call reconnects
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+func (c *redisCache) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	// redis 6 ACL users authenticate with both, older servers with the
+	// password only
+	auth := []string{"AUTH", c.auth}
+	if c.user != "" {
+		auth = []string{"AUTH", c.user, c.auth}
+	}
+	if c.user != "" || c.auth != "" {
+		if _, err := c.command(auth...); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) command(args ...string) (*string, error) {
+	_ = c.conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		s := line[1:]
+		return &s, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		s := string(data[:n])
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
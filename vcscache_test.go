@@ -0,0 +1,106 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeRedis accepts a single connection, records the commands it receives
+// and answers each with +OK.
+func fakeRedis(t *testing.T) (addr string, commands <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan []string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(ch)
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				arg, _ := r.ReadString('\n')
+				args[i] = strings.TrimSuffix(arg, "\r\n")
+			}
+			ch <- args
+			conn.Write([]byte("+OK\r\n"))
+		}
+	}()
+	return l.Addr().String(), ch
+}
+
+func TestRedisCacheAuth(t *testing.T) {
+	cases := []struct {
+		userinfo string
+		want     []string
+	}{
+		{"", nil},
+		{":secret@", []string{"AUTH", "secret"}},
+		{"bom:secret@", []string{"AUTH", "bom", "secret"}},
+	}
+	for _, c := range cases {
+		addr, commands := fakeRedis(t)
+		u, err := url.Parse("redis://" + c.userinfo + addr + "/2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache, err := newRedisCache(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Set("example.com/x", "https://example.com/x"); err != nil {
+			t.Fatalf("%q: Set: %v", c.userinfo, err)
+		}
+		cache.conn.Close()
+
+		var got [][]string
+		for cmd := range commands {
+			got = append(got, cmd)
+		}
+		want := [][]string{
+			{"SELECT", "2"},
+			{"SET", redisKeyPrefix + "example.com/x", "https://example.com/x"},
+		}
+		if c.want != nil {
+			want = append([][]string{c.want}, want...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: commands = %q, want %q", c.userinfo, got, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runVerify re-merges the inputs with the regular merge flags and compares
+// the result with a published bom.json. It reports whether they drifted.
+func runVerify(args []string) (bool, error) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.AddFlagSet(flag.CommandLine)
+	published := fs.String("published", "", "Path to the published bom.json to verify")
+	_ = fs.Parse(args)
+	if *published == "" || len(opts.In) == 0 {
+		return false, errors.New("usage: bom-merger verify --published=bom.json --in=DIR [merge flags]")
+	}
+
+	data, err := ioutil.ReadFile(*published)
+	if err != nil {
+		return false, err
+	}
+	want, err := readBOMProjects(*published)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse published BOM %s: %v", *published, err)
+	}
+
+	res, err := newResources()
+	if err != nil {
+		return false, err
+	}
+	m := newMerger(opts, res)
+	if err := m.merge(); err != nil {
+		return false, err
+	}
+	reg, err := m.outputRegistry(m.bom, merge.HighestConfidence)
+	if err != nil {
+		return false, err
+	}
+
+	produced, err := roundTrip(reg.Projects(), publishedFormat(data))
+	if err != nil {
+		return false, err
+	}
+	got := map[string]merge.Project{}
+	for _, p := range produced {
+		got[outputKey(p)] = normalizeForVerify(p)
+	}
+	drift := false
+	seen := map[string]bool{}
+	for _, p := range want {
+		key := outputKey(p)
+		seen[key] = true
+		g, ok := got[key]
+		switch {
+		case !ok:
+			fmt.Printf("- %s: published but not produced by inputs\n", key)
+			drift = true
+		case !reflect.DeepEqual(g, normalizeForVerify(p)):
+			fmt.Printf("~ %s: published entry differs from inputs\n", key)
+			drift = true
+		}
+	}
+	for _, p := range produced {
+		if !seen[outputKey(p)] {
+			fmt.Printf("+ %s: produced by inputs but not published\n", outputKey(p))
+			drift = true
+		}
+	}
+
+	if drift {
+		fmt.Fprintf(os.Stderr, "%s does not match the inputs\n", *published)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s matches the inputs (%d entries)\n", *published, len(want))
+	}
+	return drift, nil
+}
+
+// publishedFormat returns the --format a published BOM was written in. YAML
+// is read like the native format, whose fields it keeps.
+func publishedFormat(data []byte) string {
+	if isSPDXTagValue(data) {
+		return formatSPDXTV
+	}
+	var doc struct {
+		cdxBOM
+		spdxDocument
+	}
+	if err := json.Unmarshal(data, &doc); err == nil {
+		switch {
+		case doc.BOMFormat == "CycloneDX":
+			return formatCycloneDX
+		case doc.SPDXVersion != "":
+			return formatSPDX
+		}
+	}
+	return formatNative
+}
+
+// roundTrip writes projects in format and reads them back, so they lose the
+// fields the format can not express just like the published BOM did.
+func roundTrip(projects []merge.Project, format string) ([]merge.Project, error) {
+	if format == formatNative {
+		return projects, nil
+	}
+	data, err := encodeBOM(projects, format, false)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseBOM("merged BOM", data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]merge.Project, len(doc.Projects))
+	for i, p := range doc.Projects {
+		out[i] = merge.NormalizeLicenses(p)
+	}
+	return out, nil
+}
+
+func outputKey(p merge.Project) string {
+	if p.Key != "" {
+		return p.Key
+	}
+	return p.Project
+}
+
+// normalizeForVerify clears fields that legitimately differ between runs,
+// such as the input file paths errors were aggregated from, and the
+// volatile ones recorded at the time of the run: the history of the
+// project and the deps.dev scorecard and dependents.
+func normalizeForVerify(p merge.Project) merge.Project {
+	p.IntroducedIn, p.IntroducedAt = "", ""
+	p.Scorecard, p.Dependents = 0, 0
+	if len(p.Errors) > 0 {
+		errs := make([]merge.ErrorRecord, len(p.Errors))
+		for i, e := range p.Errors {
+			e.Sources = nil
+			errs[i] = e
+		}
+		p.Errors = errs
+	}
+	if len(p.Labels) == 0 {
+		p.Labels = nil
+	}
+	if len(p.Aliases) == 0 {
+		p.Aliases = nil
+	}
+	if len(p.Licenses) == 0 {
+		p.Licenses = nil
+	}
+	return p
+}
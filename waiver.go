@@ -0,0 +1,131 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+// waiverDate is the format of the expires date of a waiver. A waiver is
+// valid through the end of that day, UTC.
+const waiverDate = "2006-01-02"
+
+// waiverEntry is an element of the waivers file. Unlike an override, a
+// waiver keeps the detected license and only exempts the project from the
+// policy checks.
+type waiverEntry struct {
+	Project string `json:"project"`
+	merge.Waiver
+}
+
+// readWaivers reads a waivers file, usually waivers.yaml. Files named .json
+// or .jsonc are read as JSON with comments and trailing commas instead.
+func readWaivers(filename string) ([]waiverEntry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".jsonc":
+		data = stripJSONC(data)
+	default:
+		if data, err = yamlToJSON(filename, data); err != nil {
+			return nil, fmt.Errorf("failed to parse waivers file %s: %v", filename, err)
+		}
+	}
+	var waivers []waiverEntry
+	if err := json.Unmarshal(data, &waivers); err != nil {
+		return nil, fmt.Errorf("failed to parse waivers file %s: %v", filename, err)
+	}
+	for i, w := range waivers {
+		if w.Project == "" || w.ApprovedBy == "" || w.Reason == "" {
+			return nil, fmt.Errorf("waiver %d in %s: project, approvedBy and reason are required", i, filename)
+		}
+		if w.Expires != "" {
+			if _, err := time.Parse(waiverDate, w.Expires); err != nil {
+				return nil, fmt.Errorf("waiver %d in %s: invalid expires date %q, must be YYYY-MM-DD", i, filename, w.Expires)
+			}
+		}
+		if w.License != "" {
+			waivers[i].License = merge.CanonicalID(w.License)
+		}
+	}
+	return waivers, nil
+}
+
+func (w waiverEntry) expired(now time.Time) bool {
+	if w.Expires == "" {
+		return false
+	}
+	t, _ := time.Parse(waiverDate, w.Expires)
+	return !now.UTC().Before(t.AddDate(0, 0, 1))
+}
+
+func (w waiverEntry) matches(p merge.Project) bool {
+	if w.Project != p.Project {
+		return false
+	}
+	if w.License == "" {
+		return true
+	}
+	for _, lic := range p.Licenses {
+		if strings.EqualFold(lic.Type, w.License) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyWaivers attaches the matching waiver to the entries of reg. Expired
+// waivers are reported and not honored.
+func (m *merger) applyWaivers(reg *merge.Registry, waivers []waiverEntry) {
+	_ = reg.Each(func(p merge.Project) error {
+		for _, w := range waivers {
+			if !w.matches(p) {
+				continue
+			}
+			if w.expired(time.Now()) {
+				warnf("waiver of %s approved by %s expired on %s", p.Project, w.ApprovedBy, w.Expires)
+				m.auditf("waiver", p.Project, "expired on %s, approved by %s: %s", w.Expires, w.ApprovedBy, w.Reason)
+				m.expiredWaivers++
+				continue
+			}
+			waiver := w.Waiver
+			p.Waiver = &waiver
+			reg.Set(p)
+			m.tracef(p.Project, "waived by %s %s: %s", w.ApprovedBy, waivedUntil(w.Expires), w.Reason)
+			m.auditf("waiver", p.Project, "honored, approved by %s %s: %s", w.ApprovedBy, waivedUntil(w.Expires), w.Reason)
+			m.waived++
+			break
+		}
+		return nil
+	})
+}
+
+func waivedUntil(expires string) string {
+	if expires == "" {
+		return "until revoked"
+	}
+	return "until " + expires
+}
@@ -0,0 +1,93 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/appscodelabs/bom-merger/pkg/merge"
+)
+
+func TestReadWaivers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-waivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := []waiverEntry{
+		{Project: "github.com/x/y", Waiver: merge.Waiver{License: "GPL-2.0", ApprovedBy: "legal@example.com", Reason: "LEGAL-123", Expires: "2025-12-31"}},
+		{Project: "github.com/x/z", Waiver: merge.Waiver{ApprovedBy: "legal@example.com", Reason: "internal tool"}},
+	}
+	files := map[string]string{
+		"waivers.yaml": `# only while the project is detected as GPL-2.0
+- project: github.com/x/y
+  license: GPL-2.0
+  approvedBy: legal@example.com
+  reason: LEGAL-123
+  expires: 2025-12-31
+- {project: github.com/x/z, approvedBy: legal@example.com, reason: internal tool}
+`,
+		"waivers.json": `[
+  // only while the project is detected as GPL-2.0
+  {"project": "github.com/x/y", "license": "GPL-2.0", "approvedBy": "legal@example.com", "reason": "LEGAL-123", "expires": "2025-12-31"},
+  {"project": "github.com/x/z", "approvedBy": "legal@example.com", "reason": "internal tool"},
+]`,
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readWaivers(filename)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: waivers = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestReadWaiversInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bom-waivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for content, want := range map[string]string{
+		"- project: github.com/x/y\n  reason: LEGAL-123\n":                                 "approvedBy and reason are required",
+		"- project: github.com/x/y\n  approvedBy: a\n  reason: b\n  expires: 31.12.2025\n": "invalid expires date",
+		"- project: github.com/x/y\n   approvedBy: a\n":                                    "waivers.yaml:2:",
+		"project: github.com/x/y\n":                                                        "failed to parse waivers file",
+	} {
+		filename := filepath.Join(dir, "waivers.yaml")
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readWaivers(filename); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("readWaivers(%q) = %v, want an error containing %q", content, err, want)
+		}
+	}
+}
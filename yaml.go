@@ -0,0 +1,807 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// BOM-merger reads and writes YAML by converting it to and from JSON with
+// the same schema. The converter supports the YAML that BOM tooling emits:
+// block and flow collections, plain and quoted scalars, literal and folded
+// block scalars, comments and multiple documents. Anchors, aliases, tags
+// and complex keys are rejected.
+
+const (
+	formatYAML      = "yaml"
+	inputFormatAuto = "auto"
+)
+
+// validateInputFormat checks --input-format.
+func validateInputFormat(format string) error {
+	switch format {
+	case "", inputFormatAuto, "json", formatYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid input format %q, must be auto, json or yaml", format)
+}
+
+// yamlInput reports whether the fragment source is read as YAML.
+func (m *merger) yamlInput(source string) bool {
+	switch m.opts.InputFormat {
+	case "", inputFormatAuto:
+		return isYAMLFile(source)
+	}
+	return m.opts.InputFormat == formatYAML
+}
+
+// isYAMLFile reports whether filename is read as YAML with
+// --input-format=auto.
+func isYAMLFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// yamlToJSON converts the YAML documents of data to JSON, one value per
+// document, so a document of two sequences reads like a legacy fragment.
+func yamlToJSON(filename string, data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for _, doc := range splitYAMLDocuments(string(data)) {
+		p := &yamlParser{lines: doc.lines}
+		p.skipBlank()
+		if p.eof() {
+			continue
+		}
+		v, err := p.parseNode(-1)
+		if err == nil {
+			p.skipBlank()
+			if !p.eof() {
+				err = p.errorf("unexpected content")
+			}
+		}
+		if err != nil {
+			line := doc.start + p.pos + 1
+			if p.pos >= len(p.lines) {
+				line = doc.start + len(p.lines)
+			}
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		out.WriteString(v)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+type yamlDocument struct {
+	start int
+	lines []string
+}
+
+// splitYAMLDocuments splits data at --- and ... markers.
+func splitYAMLDocuments(data string) []yamlDocument {
+	data = strings.TrimPrefix(strings.Replace(data, "\r\n", "\n", -1), "\ufeff")
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	var docs []yamlDocument
+	cur := yamlDocument{}
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- #") || trimmed == "..." {
+			docs = append(docs, cur)
+			cur = yamlDocument{start: i + 1}
+			continue
+		}
+		if strings.HasPrefix(line, "%") && len(cur.lines) == 0 {
+			// directives like %YAML 1.2
+			cur.start = i + 1
+			continue
+		}
+		cur.lines = append(cur.lines, line)
+	}
+	return append(docs, cur)
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(format, args...)
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.lines)
+}
+
+// skipBlank moves past empty and comment lines.
+func (p *yamlParser) skipBlank() {
+	for !p.eof() {
+		if s := strings.TrimSpace(p.lines[p.pos]); s != "" && !strings.HasPrefix(s, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+// current returns the indentation and the content of the current line,
+// without a trailing comment.
+func (p *yamlParser) current() (int, string, error) {
+	line := p.lines[p.pos]
+	content := strings.TrimLeft(line, " ")
+	indent := len(line) - len(content)
+	if strings.HasPrefix(content, "\t") {
+		return 0, "", p.errorf("tabs can not be used for indentation")
+	}
+	return indent, stripYAMLComment(content), nil
+}
+
+// parseNode parses the block node starting at the current line, which must
+// be indented more than parent.
+func (p *yamlParser) parseNode(parent int) (string, error) {
+	p.skipBlank()
+	if p.eof() {
+		return "null", nil
+	}
+	indent, content, err := p.current()
+	if err != nil {
+		return "", err
+	}
+	if indent <= parent {
+		return "null", nil
+	}
+	switch {
+	case isYAMLSequenceItem(content):
+		return p.parseSequence(indent)
+	case yamlKeyEnd(content) >= 0:
+		return p.parseMapping(indent)
+	}
+	return p.parseValue(indent, content, parent)
+}
+
+func isYAMLSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+func (p *yamlParser) parseSequence(indent int) (string, error) {
+	var items []string
+	for {
+		p.skipBlank()
+		if p.eof() {
+			break
+		}
+		i, content, err := p.current()
+		if err != nil {
+			return "", err
+		}
+		if i != indent || !isYAMLSequenceItem(content) {
+			if i > indent {
+				return "", p.errorf("bad indentation of a sequence entry")
+			}
+			break
+		}
+		rest := strings.TrimLeft(content[1:], " ")
+		var item string
+		if rest == "" {
+			p.pos++
+			item, err = p.parseNode(indent)
+		} else {
+			// parse the rest as a node of its own, indented by its column
+			line := p.lines[p.pos]
+			col := len(line) - len(strings.TrimLeft(line[indent+1:], " "))
+			p.lines[p.pos] = strings.Repeat(" ", col) + line[col:]
+			item, err = p.parseNode(indent)
+		}
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+	return "[" + strings.Join(items, ",") + "]", nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (string, error) {
+	var fields []string
+	for {
+		p.skipBlank()
+		if p.eof() {
+			break
+		}
+		i, content, err := p.current()
+		if err != nil {
+			return "", err
+		}
+		if i != indent {
+			if i > indent {
+				return "", p.errorf("bad indentation of a mapping entry")
+			}
+			break
+		}
+		end := yamlKeyEnd(content)
+		if end < 0 {
+			if isYAMLSequenceItem(content) {
+				break
+			}
+			return "", p.errorf("expected a mapping entry")
+		}
+		key, err := yamlScalar(strings.TrimSpace(content[:end]), true)
+		if err != nil {
+			return "", err
+		}
+		rest := strings.TrimSpace(content[end+1:])
+
+		var value string
+		if rest == "" {
+			p.pos++
+			p.skipBlank()
+			value = "null"
+			if !p.eof() {
+				next, nextContent, err := p.current()
+				if err != nil {
+					return "", err
+				}
+				switch {
+				case next > indent:
+					value, err = p.parseNode(indent)
+				case next == indent && isYAMLSequenceItem(nextContent):
+					// a sequence may be indented like its key
+					value, err = p.parseSequence(indent)
+				}
+				if err != nil {
+					return "", err
+				}
+			}
+		} else if value, err = p.parseValue(indent, rest, indent); err != nil {
+			return "", err
+		}
+		fields = append(fields, key+":"+value)
+	}
+	return "{" + strings.Join(fields, ",") + "}", nil
+}
+
+// parseValue parses the value content of the current line, which may
+// continue on the following lines indented more than parent.
+func (p *yamlParser) parseValue(indent int, content string, parent int) (string, error) {
+	switch {
+	case content == "":
+		p.pos++
+		return "null", nil
+	case content[0] == '|' || content[0] == '>':
+		return p.parseBlockScalar(content, parent)
+	case content[0] == '[' || content[0] == '{':
+		text := content
+		for depth := flowDepth(content); depth > 0; {
+			p.pos++
+			if p.eof() {
+				return "", p.errorf("unterminated flow collection")
+			}
+			next := stripYAMLComment(strings.TrimSpace(p.lines[p.pos]))
+			depth += flowDepth(next)
+			text += " " + next
+		}
+		p.pos++
+		f := &yamlFlow{s: text}
+		v, err := f.value()
+		if err != nil {
+			return "", err
+		}
+		if f.skipSpace(); f.i < len(f.s) {
+			return "", p.errorf("unexpected %q after flow collection", f.s[f.i:])
+		}
+		return v, nil
+	case content[0] == '&' || content[0] == '*' || content[0] == '!':
+		return "", p.errorf("anchors, aliases and tags are not supported")
+	case content[0] == '"' || content[0] == '\'':
+		p.pos++
+		return yamlScalar(content, false)
+	}
+	// plain scalars continue on more indented lines, folded with spaces
+	text := content
+	for p.pos++; !p.eof(); p.pos++ {
+		line := p.lines[p.pos]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || len(line)-len(strings.TrimLeft(line, " ")) <= parent {
+			break
+		}
+		if yamlKeyEnd(trimmed) >= 0 {
+			return "", p.errorf("bad indentation of a mapping entry")
+		}
+		text += " " + stripYAMLComment(trimmed)
+	}
+	return yamlScalar(text, false)
+}
+
+var blockHeader = regexp.MustCompile(`^([|>])([-+]?)([1-9]?)([-+]?)$`)
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar.
+func (p *yamlParser) parseBlockScalar(header string, parent int) (string, error) {
+	m := blockHeader.FindStringSubmatch(strings.TrimSpace(header))
+	if m == nil {
+		return "", p.errorf("invalid block scalar header %q", header)
+	}
+	literal, chomp := m[1] == "|", m[2]+m[4]
+	contentIndent := -1
+	if m[3] != "" {
+		contentIndent = parent + 1 + int(m[3][0]-'1')
+		if parent < 0 {
+			contentIndent = int(m[3][0] - '0')
+		}
+	}
+
+	var lines []string
+	for p.pos++; !p.eof(); p.pos++ {
+		line := p.lines[p.pos]
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if contentIndent < 0 {
+			if indent <= parent {
+				break
+			}
+			contentIndent = indent
+		}
+		if indent < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+	var text string
+	if literal {
+		text = strings.Join(body, "\n")
+	} else {
+		// lines are folded into spaces, except around empty and more
+		// indented lines
+		for i, line := range body {
+			switch {
+			case line == "":
+				text += "\n"
+			case i == 0 || body[i-1] == "":
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(body[i-1], " "):
+				text += "\n"
+			default:
+				text += " "
+			}
+			text += line
+		}
+	}
+	switch {
+	case len(body) == 0:
+	case chomp == "-":
+	case chomp == "+":
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return jsonString(text), nil
+}
+
+// yamlKeyEnd returns the index of the colon ending the key of a block
+// mapping entry, or -1 if content is no mapping entry.
+func yamlKeyEnd(content string) int {
+	if content == "" || strings.ContainsRune("[{#|>-", rune(content[0])) && !(content[0] == '-' && len(content) > 1 && content[1] != ' ') {
+		return -1
+	}
+	i := 0
+	if content[0] == '"' || content[0] == '\'' {
+		end := quotedEnd(content)
+		if end < 0 {
+			return -1
+		}
+		i = end
+	}
+	for ; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// quotedEnd returns the index after the quoted scalar content starts with.
+func quotedEnd(content string) int {
+	q := content[0]
+	for i := 1; i < len(content); i++ {
+		switch {
+		case q == '"' && content[i] == '\\':
+			i++
+		case q == '\'' && content[i] == '\'' && i+1 < len(content) && content[i+1] == '\'':
+			i++
+		case content[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a comment, which starts with # at the beginning
+// or after a space, outside of quotes.
+func stripYAMLComment(content string) string {
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(content) && content[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", content[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || content[i-1] == ' ' || content[i-1] == '\t'):
+			return strings.TrimRight(content[:i], " \t")
+		}
+	}
+	return strings.TrimRight(content, " \t")
+}
+
+// flowDepth returns the change of nesting of flow collections in s.
+func flowDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// yamlFlow parses a flow collection like [a, "b"] or {a: 1}.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (string, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return "", fmt.Errorf("unexpected end of flow collection")
+	}
+	switch c := f.s[f.i]; c {
+	case '[', '{':
+		f.i++
+		closing := byte(']')
+		if c == '{' {
+			closing = '}'
+		}
+		var items []string
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == closing {
+				f.i++
+				break
+			}
+			item, err := f.value()
+			if err != nil {
+				return "", err
+			}
+			if c == '{' {
+				f.skipSpace()
+				if f.i == len(f.s) || f.s[f.i] != ':' {
+					return "", fmt.Errorf("expected : after key %s in flow mapping", item)
+				}
+				f.i++
+				if !strings.HasPrefix(item, `"`) {
+					item = jsonString(item)
+				}
+				value, err := f.value()
+				if err != nil {
+					return "", err
+				}
+				item += ":" + value
+			}
+			items = append(items, item)
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == ',' {
+				f.i++
+			} else if f.i == len(f.s) || f.s[f.i] != closing {
+				return "", fmt.Errorf("expected , or %c in flow collection", closing)
+			}
+		}
+		if c == '{' {
+			return "{" + strings.Join(items, ",") + "}", nil
+		}
+		return "[" + strings.Join(items, ",") + "]", nil
+	case '"', '\'':
+		end := quotedEnd(f.s[f.i:])
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted scalar")
+		}
+		v, err := yamlScalar(f.s[f.i:f.i+end], false)
+		f.i += end
+		return v, err
+	}
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) &&
+		!(f.s[f.i] == ':' && (f.i+1 == len(f.s) || strings.ContainsRune(" ,]}", rune(f.s[f.i+1])))) {
+		f.i++
+	}
+	return yamlScalar(strings.TrimSpace(f.s[start:f.i]), false)
+}
+
+var (
+	yamlNull  = regexp.MustCompile(`^(~|null|Null|NULL)?$`)
+	yamlBool  = regexp.MustCompile(`^(true|True|TRUE|false|False|FALSE)$`)
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+)
+
+// yamlScalar converts a plain or quoted scalar to JSON. Keys are always
+// strings, plain scalars are resolved by the YAML 1.2 core schema.
+func yamlScalar(s string, key bool) (string, error) {
+	if s == "" && !key {
+		return "null", nil
+	}
+	switch s[0] {
+	case '"':
+		v, err := unquoteYAMLDouble(s)
+		return jsonString(v), err
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return "", fmt.Errorf("unterminated quoted scalar %s", s)
+		}
+		return jsonString(strings.Replace(s[1:len(s)-1], "''", "'", -1)), nil
+	case '&', '*', '!':
+		return "", fmt.Errorf("anchors, aliases and tags are not supported")
+	case '?':
+		if len(s) == 1 || s[1] == ' ' {
+			return "", fmt.Errorf("complex mapping keys are not supported")
+		}
+	}
+	if key {
+		return jsonString(s), nil
+	}
+	switch {
+	case yamlNull.MatchString(s):
+		return "null", nil
+	case yamlBool.MatchString(s):
+		return strings.ToLower(s), nil
+	case yamlInt.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10), nil
+		}
+		fallthrough
+	case yamlFloat.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case yamlHex.MatchString(s), yamlOct.MatchString(s):
+		n, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+	return jsonString(s), nil
+}
+
+// unquoteYAMLDouble decodes a double quoted scalar, whose escapes are a
+// superset of those of JSON.
+func unquoteYAMLDouble(s string) (string, error) {
+	if len(s) < 2 || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("unterminated quoted scalar %s", s)
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("invalid escape at the end of %q", s)
+		}
+		simple := map[byte]string{
+			'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+			'e': "\x1b", ' ': " ", '"': `"`, '/': "/", '\\': `\`, 'N': "\u0085", '_': " ", 'L': " ", 'P': " ",
+		}
+		if r, ok := simple[s[i]]; ok {
+			b.WriteString(r)
+			continue
+		}
+		size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if size == 0 || i+size >= len(s) {
+			return "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+		n, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape \\%s", s[i:i+1+size])
+		}
+		b.WriteRune(rune(n))
+		i += size
+	}
+	return b.String(), nil
+}
+
+// jsonString quotes s as a JSON string, which is a valid double quoted YAML
+// scalar as well.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// jsonToYAML converts a JSON document to YAML in block style, keeping the
+// order of object keys.
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	root, err := readYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	writeYAMLNode(&b, root, 0)
+	return []byte(b.String()), nil
+}
+
+// yamlNode is a JSON value with the keys of objects in order.
+type yamlNode struct {
+	scalar string // the YAML form of a scalar
+	object bool
+	keys   []string
+	values []*yamlNode
+}
+
+func (n *yamlNode) collection() bool {
+	return n.scalar == ""
+}
+
+func readYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		n := &yamlNode{object: v == '{'}
+		for decoder.More() {
+			if n.object {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, yamlString(key.(string)))
+			}
+			value, err := readYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		if len(n.values) == 0 {
+			n.scalar = "[]"
+			if n.object {
+				n.scalar = "{}"
+			}
+		}
+		return n, nil
+	case string:
+		return &yamlNode{scalar: yamlString(v)}, nil
+	case json.Number:
+		return &yamlNode{scalar: v.String()}, nil
+	case bool:
+		return &yamlNode{scalar: strconv.FormatBool(v)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// writeYAMLNode writes n indented by indent. Sequences of a mapping are
+// indented like their key.
+func writeYAMLNode(w io.StringWriter, n *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+	if !n.collection() {
+		_, _ = w.WriteString(pad + n.scalar + "\n")
+		return
+	}
+	for i, v := range n.values {
+		prefix := pad + "- "
+		if n.object {
+			prefix = pad + n.keys[i] + ":"
+		}
+		switch {
+		case !v.collection():
+			if n.object {
+				prefix += " "
+			}
+			_, _ = w.WriteString(prefix + v.scalar + "\n")
+		case n.object && v.object:
+			_, _ = w.WriteString(prefix + "\n")
+			writeYAMLNode(w, v, indent+2)
+		case n.object:
+			_, _ = w.WriteString(prefix + "\n")
+			writeYAMLNode(w, v, indent)
+		default:
+			// the first line of the item follows the dash
+			var item strings.Builder
+			writeYAMLNode(&item, v, indent+2)
+			_, _ = w.WriteString(prefix + item.String()[indent+2:])
+		}
+	}
+}
+
+// yamlString returns s as a plain scalar if it reads back as the same
+// string, and double quoted otherwise.
+func yamlString(s string) string {
+	if s == "" || !utf8.ValidString(s) || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\n\t\r\"'\\#&*!|>%@`{}[],") ||
+		strings.Contains(s, ": ") || strings.HasSuffix(s, ":") || strings.ContainsRune("-?:", rune(s[0])) {
+		return jsonString(s)
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r >= 0x80 {
+			return jsonString(s)
+		}
+	}
+	if v, err := yamlScalar(s, false); err != nil || v != jsonString(s) {
+		return jsonString(s)
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+/*
+Copyright AppsCode Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameJSON reports whether a and b hold the same JSON values, one per line.
+func sameJSON(t *testing.T, a, b string) bool {
+	t.Helper()
+	decode := func(s string) []interface{} {
+		var out []interface{}
+		d := json.NewDecoder(strings.NewReader(s))
+		for d.More() {
+			var v interface{}
+			if err := d.Decode(&v); err != nil {
+				t.Fatalf("invalid JSON %q: %v", s, err)
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+	return reflect.DeepEqual(decode(a), decode(b))
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{"scalars", "a: 1\nb: 1.5\nc: true\nd: null\ne: ~\nf: text\ng: 0x1f\n",
+			`{"a": 1, "b": 1.5, "c": true, "d": null, "e": null, "f": "text", "g": 31}`},
+		{"quoted", "a: \"1\"\nb: 'it''s'\nc: \"tab\\tnew\\nline\"\nd: 'true'\ne: \"# not a comment\"\n",
+			`{"a": "1", "b": "it's", "c": "tab\tnew\nline", "d": "true", "e": "# not a comment"}`},
+		{"comments", "# header\na: x # trailing\nb: x#y\n", `{"a": "x", "b": "x#y"}`},
+		{"nested maps", "a:\n  b:\n    c: 1\n  d: 2\n", `{"a": {"b": {"c": 1}, "d": 2}}`},
+		{"nested sequences", "- - 1\n  - 2\n- - 3\n", `[[1, 2], [3]]`},
+		{"sequence of maps", "- project: x\n  licenses:\n  - type: MIT\n    confidence: 0.9\n- project: y\n",
+			`[{"project": "x", "licenses": [{"type": "MIT", "confidence": 0.9}]}, {"project": "y"}]`},
+		{"sequence indented under key", "a:\n  - 1\n  - 2\n", `{"a": [1, 2]}`},
+		{"flow collections", "a: [1, \"b, c\", {d: e}]\nf: {}\n", `{"a": [1, "b, c", {"d": "e"}], "f": {}}`},
+		{"literal block", "a: |\n  line 1\n  line 2\nb: x\n", `{"a": "line 1\nline 2\n", "b": "x"}`},
+		{"literal block strip", "a: |-\n  line 1\n\n  line 2\n", `{"a": "line 1\n\nline 2"}`},
+		{"folded block", "a: >\n  folded\n  text\n\n  para\n", `{"a": "folded text\npara\n"}`},
+		{"multi-line plain", "a: one\n  two\n", `{"a": "one two"}`},
+		{"documents", "---\n- a\n---\n- b\n...\n", "[\"a\"]\n[\"b\"]"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := yamlToJSON("test.yaml", []byte(c.yaml))
+			if err != nil {
+				t.Fatalf("yamlToJSON: %v", err)
+			}
+			if !sameJSON(t, string(got), c.json) {
+				t.Errorf("yamlToJSON(%q) = %s, want %s", c.yaml, got, c.json)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"anchor", "a: &x 1\n", "test.yaml:1: anchors, aliases and tags are not supported"},
+		{"alias", "a: 1\nb: *x\n", "test.yaml:2: anchors, aliases and tags are not supported"},
+		{"complex key", "? a\n: b\n", "test.yaml:2:"},
+		{"unterminated single quote", "a: 'x\n", "test.yaml:1: unterminated quoted scalar"},
+		{"unterminated double quote", "a: \"x\n", "test.yaml:1:"},
+		{"unterminated flow", "a: [1, 2\n", "test.yaml:1:"},
+		{"bad indentation", "a:\n  b: 1\n c: 2\n", "test.yaml:3:"},
+		{"mixed collection", "a: 1\n- b\n", "test.yaml:2:"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := yamlToJSON("test.yaml", []byte(c.yaml))
+			if err == nil {
+				t.Fatalf("yamlToJSON(%q) succeeded, want error %q", c.yaml, c.err)
+			}
+			if !strings.Contains(err.Error(), c.err) {
+				t.Errorf("yamlToJSON(%q) = %v, want error containing %q", c.yaml, err, c.err)
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	cases := []string{
+		`[]`,
+		`{}`,
+		`[{"project": "github.com/spf13/pflag", "version": "v1.0.5", "licenses": [{"type": "BSD-3-Clause", "confidence": 0.96}]}]`,
+		`{"projects": [{"project": "x", "labels": {}, "aliases": []}], "errors": [{"project": "y", "error": "not found"}]}`,
+		`[[1, [2, [3]]], {"a": {"b": {"c": null}}}]`,
+		`{"multi": "line 1\nline 2\n", "trailing": "no newline\nat end", "blank": "a\n\nb"}`,
+	}
+	for _, in := range cases {
+		y, err := jsonToYAML([]byte(in))
+		if err != nil {
+			t.Fatalf("jsonToYAML(%s): %v", in, err)
+		}
+		out, err := yamlToJSON("test.yaml", y)
+		if err != nil {
+			t.Fatalf("yamlToJSON of\n%s: %v", y, err)
+		}
+		if !sameJSON(t, string(out), in) {
+			t.Errorf("round trip of %s through\n%s= %s", in, y, out)
+		}
+	}
+}
+
+func TestYAMLStringQuoting(t *testing.T) {
+	cases := []struct {
+		in     string
+		quoted bool
+	}{
+		{"github.com/spf13/pflag", false},
+		{"MIT", false},
+		{"v1.0.5", false},
+		{"", true},
+		{"true", true},
+		{"No", false}, // not a boolean in YAML 1.2
+		{"null", true},
+		{"~", true},
+		{"123", true},
+		{"1.5", true},
+		{"0x1f", true},
+		{"key: value", true},
+		{"ends with colon:", true},
+		{"- item", true},
+		{"# comment", true},
+		{"a #b", true},
+		{" padded", true},
+		{"it's", true},
+		{"[flow]", true},
+		{"line\nbreak", true},
+		{"ünïcode", true},
+	}
+	for _, c := range cases {
+		got := yamlString(c.in)
+		if quoted := strings.HasPrefix(got, `"`); quoted != c.quoted {
+			t.Errorf("yamlString(%q) = %s, quoted %v, want %v", c.in, got, quoted, c.quoted)
+		}
+		back, err := yamlToJSON("test.yaml", []byte("a: "+got+"\n"))
+		if err != nil {
+			t.Fatalf("yamlToJSON of yamlString(%q) = %s: %v", c.in, got, err)
+		}
+		if want := `{"a":` + jsonString(c.in) + "}"; !sameJSON(t, string(back), want) {
+			t.Errorf("yamlString(%q) = %s reads back as %s", c.in, got, back)
+		}
+	}
+}